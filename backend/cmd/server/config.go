@@ -0,0 +1,22 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"kage/backend/internal/app"
+)
+
+const configUsage = "usage: server config print [yaml|toml]"
+
+// runConfig implements the config subcommand; print writes the effective configuration with secrets redacted.
+func runConfig(cfg app.Config, args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "print" || len(args) > 2 {
+		return errors.New(configUsage)
+	}
+	format := ""
+	if len(args) == 2 {
+		format = args[1]
+	}
+	return app.WriteConfig(out, cfg, format)
+}
@@ -2,49 +2,105 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"kage/backend/internal/app"
+	"kage/backend/internal/logging"
 )
 
 func main() {
-	logger := log.New(os.Stdout, "backend ", log.LstdFlags)
+	level := new(slog.LevelVar)
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
 
-	// 1.- Load configuration, construct dependencies, and build the HTTP server.
-	cfg, err := app.LoadConfig()
+	// 1.- Load configuration from defaults, the config file, the environment and flags, in that order.
+	src, args, err := app.ParseFlags(os.Args[0], os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	cfg, err := app.LoadConfig(src)
 	if err != nil {
-		logger.Fatalf("load config: %v", err)
+		fatal(logger, "load config", err)
+	}
+
+	if len(args) > 0 {
+		// 2.- Run a one-off subcommand instead of serving.
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		switch args[0] {
+		case "migrate":
+			err = runMigrate(ctx, cfg, logger, args[1:], os.Stdout)
+		case "config":
+			err = runConfig(cfg, args[1:], os.Stdout)
+		default:
+			err = fmt.Errorf("unknown command %q; usage: server [flags] [migrate ... | config print [yaml|toml]]", args[0])
+		}
+		if err != nil {
+			fatal(logger, args[0], err)
+		}
+		return
 	}
 
-	application, err := app.Build(cfg, logger)
+	application, err := app.Build(cfg, logger, level)
 	if err != nil {
-		logger.Fatalf("build application: %v", err)
+		fatal(logger, "build application", err)
 	}
 
-	srv := &http.Server{Addr: ":" + cfg.HTTPPort, Handler: application.Engine}
+	srv := &http.Server{
+		Addr:              ":" + cfg.HTTP.Port,
+		Handler:           application.Engine,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
 
 	go func() {
-		// 2.- Start the HTTP listener and report fatal boot errors.
+		// 3.- Start the HTTP listener and report fatal boot errors.
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatalf("server error: %v", err)
+			fatal(logger, "server error", err)
+		}
+	}()
+
+	// 4.- Re-read the configuration on SIGHUP or when the config file changes, without restarting.
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	reloader := app.NewReloader(src, cfg, application.ApplyConfig, logger)
+	go func() {
+		if err := reloader.Watch(reloadCtx, hup); err != nil {
+			logger.Warn("config file watch disabled, SIGHUP still reloads", "error", err)
+			for range hup {
+				_ = reloader.Reload(reloadCtx)
+			}
 		}
 	}()
 
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	<-sigs
+	stopReload()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Printf("shutdown error: %v", err)
+		logger.Error("shutdown error", "error", err)
 	}
 	if err := application.Cleanup(ctx); err != nil {
-		logger.Printf("cleanup error: %v", err)
+		logger.Error("cleanup error", "error", err)
 	}
 }
+
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"kage/backend/internal/app"
+	"kage/backend/internal/migrate"
+)
+
+const migrateUsage = "usage: server migrate up | down [steps] | status | unlock"
+
+// runMigrate implements the migrate subcommand against the configured database.
+func runMigrate(ctx context.Context, cfg app.Config, logger *slog.Logger, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if cfg.DB.DSN == "" {
+		return errors.New("db.dsn (BACKEND_DB_DSN) is required to migrate")
+	}
+
+	// 1.- Connect and load the embedded migrations for the DSN's dialect.
+	db, d, err := app.OpenDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrations, err := migrate.Load(migrate.Embedded, migrate.Dir(d))
+	if err != nil {
+		return err
+	}
+	m := migrate.New(db, migrations, migrate.WithDialect(d), migrate.WithLogger(logger))
+
+	// 2.- Dispatch the action.
+	switch args[0] {
+	case "up":
+		ran, err := m.Up(ctx)
+		fmt.Fprintf(out, "applied %d migration(s)\n", len(ran))
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("down: steps must be a positive integer, got %q", args[1])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		fmt.Fprintf(out, "reverted %d migration(s)\n", len(reverted))
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(out, statuses)
+	case "unlock":
+		return m.Unlock(ctx)
+	}
+	return fmt.Errorf("unknown migrate action %q; %s", args[0], migrateUsage)
+}
+
+func printStatus(out io.Writer, statuses []migrate.Status) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, st := range statuses {
+		state, appliedAt := "pending", "-"
+		if st.Applied {
+			state, appliedAt = "applied", st.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		if st.Modified {
+			state = "modified"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
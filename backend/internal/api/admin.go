@@ -0,0 +1,89 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/logging"
+)
+
+// adminRoles may call the /admin endpoints, evaluate bids and act on any trip.
+var adminRoles = []string{auth.RoleOps, auth.RoleService}
+
+// LogLevelDTO reads and writes the process log threshold.
+type LogLevelDTO struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// WithLogLevel serves GET and PUT /admin/log-level, adjusting level at runtime.
+func WithLogLevel(level *slog.LevelVar) ServerOption {
+	return func(s *Server) { s.logLevel = level }
+}
+
+// WithLogger writes administrative changes to logger instead of slog.Default.
+func WithLogger(logger *slog.Logger) ServerOption {
+	return func(s *Server) { s.logger = logging.OrDefault(logger) }
+}
+
+func (s *Server) adminOperations() []operation {
+	if s.logLevel == nil {
+		return nil
+	}
+	return []operation{
+		{
+			Method: http.MethodGet, Path: "/admin/log-level", ID: "getLogLevel", Tag: "admin", Auth: true, Roles: adminRoles,
+			Summary: "Report the current log level",
+			Status:  http.StatusOK, Response: LogLevelDTO{},
+			Errors:  []int{http.StatusUnauthorized, http.StatusForbidden},
+			Handler: s.handleGetLogLevel,
+		},
+		{
+			Method: http.MethodPut, Path: "/admin/log-level", ID: "setLogLevel", Tag: "admin", Auth: true, Roles: adminRoles,
+			Summary: "Change the log level (debug, info, warn, error) without restarting",
+			Body:    LogLevelDTO{}, Status: http.StatusOK, Response: LogLevelDTO{},
+			Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+			Handler: s.handleSetLogLevel,
+		},
+	}
+}
+
+func (s *Server) handleGetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, LogLevelDTO{Level: strings.ToLower(s.logLevel.Level().String())})
+}
+
+func (s *Server) handleSetLogLevel(c *gin.Context) {
+	var body LogLevelDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, err.Error())
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(body.Level)); err != nil {
+		abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, "level must be debug, info, warn or error")
+		return
+	}
+	previous := s.logLevel.Level()
+	s.logLevel.Set(level)
+	s.logger.LogAttrs(c.Request.Context(), slog.LevelWarn, "log level changed",
+		slog.String("from", previous.String()), slog.String("to", level.String()))
+	s.handleGetLogLevel(c)
+}
+
+// requireRole aborts with a 403 problem unless the authenticated principal holds one of roles.
+func requireRole(roles []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(principalKey)
+		if !ok {
+			// 1.- Authentication is disabled, so there is no principal to restrict.
+			return
+		}
+		if !slices.Contains(roles, value.(auth.Principal).Role) {
+			abortWithStatus(c, http.StatusForbidden, CodeForbidden, "role not allowed to call this endpoint")
+		}
+	}
+}
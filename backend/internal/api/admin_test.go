@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/trip"
+)
+
+func TestAdminLogLevel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validator := auth.NewValidator("top-secret")
+	level := new(slog.LevelVar)
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), validator, WithLogLevel(level))
+	router := gin.New()
+	server.RegisterRoutes(router)
+	opsToken := validator.Issue(auth.Principal{ID: "ops-1", Role: auth.RoleOps}, time.Minute)
+	riderToken := validator.Issue(auth.Principal{ID: "r1", Role: auth.RoleRider}, time.Minute)
+
+	do := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/admin/log-level", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	// 1.- Riders may not touch the log level.
+	if res := do(http.MethodPut, riderToken, `{"level":"debug"}`); res.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 got %d", res.Code)
+	}
+
+	// 2.- Ops principals read and change it.
+	res := do(http.MethodGet, opsToken, "")
+	if res.Code != http.StatusOK || !bytes.Contains(res.Body.Bytes(), []byte(`"info"`)) {
+		t.Fatalf("expected info level got %d %s", res.Code, res.Body)
+	}
+	res = do(http.MethodPut, opsToken, `{"level":"debug"}`)
+	var got LogLevelDTO
+	if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil || res.Code != http.StatusOK || got.Level != "debug" {
+		t.Fatalf("expected debug level got %d %s", res.Code, res.Body)
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Fatalf("expected level var updated got %v", level.Level())
+	}
+
+	// 3.- Unknown levels are rejected and leave the threshold alone.
+	if res := do(http.MethodPut, "Bearer top-secret", `{"level":"verbose"}`); res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", res.Code)
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Fatalf("expected level unchanged got %v", level.Level())
+	}
+}
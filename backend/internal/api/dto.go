@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"kage/backend/internal/chat"
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/trip"
+)
+
+// errInvalidDuration occurs when a duration is neither integer seconds nor an ISO-8601 duration.
+var errInvalidDuration = errors.New("duration must be non-negative integer seconds or an ISO-8601 duration such as PT15M")
+
+var (
+	isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+	amountPattern      = regexp.MustCompile(`^\d+(?:\.\d+)?$`)
+	currencyPattern    = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// Duration is a time.Duration on the wire: encoded as integer seconds and decoded
+// from integer seconds or an ISO-8601 duration such as "PT1H30M".
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as whole seconds.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(time.Duration(d).Round(time.Second)/time.Second), 10)), nil
+}
+
+// UnmarshalJSON accepts integer seconds or an ISO-8601 duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return errInvalidDuration
+		}
+		parsed, err := parseISODuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+	seconds, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || seconds < 0 || seconds > math.MaxInt64/int64(time.Second) {
+		return errInvalidDuration
+	}
+	*d = Duration(time.Duration(seconds) * time.Second)
+	return nil
+}
+
+// parseISODuration handles the day and time components of ISO-8601; years and months are rejected as ambiguous.
+func parseISODuration(s string) (time.Duration, error) {
+	m := isoDurationPattern.FindStringSubmatch(s)
+	if m == nil || s == "P" || s[len(s)-1] == 'T' {
+		return 0, errInvalidDuration
+	}
+	var total float64
+	for i, unit := range []float64{24 * 3600, 3600, 60, 1} {
+		if m[i+1] == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, errInvalidDuration
+		}
+		total += v * unit
+	}
+	if total*float64(time.Second) > math.MaxInt64 {
+		return 0, errInvalidDuration
+	}
+	return time.Duration(total * float64(time.Second)), nil
+}
+
+// Money is a decimal amount with its ISO 4217 currency code.
+type Money struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// NewMoney formats the amount with two decimal places.
+func NewMoney(amount float64, currency string) Money {
+	return Money{Amount: strconv.FormatFloat(amount, 'f', 2, 64), Currency: currency}
+}
+
+// validate parses the amount and checks the currency, reporting problems under prefix.
+func (m Money) validate(prefix string) (float64, []contracts.FieldError) {
+	var fields []contracts.FieldError
+	amount, err := strconv.ParseFloat(m.Amount, 64)
+	if !amountPattern.MatchString(m.Amount) || err != nil {
+		fields = append(fields, contracts.FieldError{Field: prefix + "amount", Reason: "must be a non-negative decimal string"})
+	}
+	if !currencyPattern.MatchString(m.Currency) {
+		fields = append(fields, contracts.FieldError{Field: prefix + "currency", Reason: "must be a three-letter ISO 4217 code"})
+	}
+	return amount, fields
+}
+
+// BidRequestDTO is the wire form of contracts.BidRequest.
+type BidRequestDTO struct {
+	RiderID   string   `json:"rider_id"`
+	TripID    string   `json:"trip_id" binding:"required"`
+	Latitude  float64  `json:"latitude" binding:"required"`
+	Longitude float64  `json:"longitude" binding:"required"`
+	MaxETA    Duration `json:"max_eta"`
+	MaxPrice  *Money   `json:"max_price,omitempty"`
+}
+
+// ToDomain validates the request and maps it onto contracts.BidRequest.
+func (d BidRequestDTO) ToDomain() (contracts.BidRequest, error) {
+	invalid := &contracts.ValidationError{}
+	c, err := contracts.NormalizeCoordinate(d.Latitude, d.Longitude, "latitude", "longitude")
+	collectFields(invalid, err)
+	req := contracts.BidRequest{
+		RiderID:   d.RiderID,
+		TripID:    d.TripID,
+		Latitude:  c.Lat,
+		Longitude: c.Lon,
+		MaxETA:    time.Duration(d.MaxETA),
+	}
+	if d.MaxPrice != nil {
+		amount, fields := d.MaxPrice.validate("max_price.")
+		invalid.Fields = append(invalid.Fields, fields...)
+		req.MaxPrice = amount
+	}
+	if len(invalid.Fields) > 0 {
+		return contracts.BidRequest{}, invalid
+	}
+	return req, nil
+}
+
+// BidDTO is the wire form of contracts.Bid.
+type BidDTO struct {
+	ID        string     `json:"id" binding:"required"`
+	DriverID  string     `json:"driver_id"`
+	TripID    string     `json:"trip_id"`
+	Price     Money      `json:"price" binding:"required"`
+	Latitude  float64    `json:"latitude" binding:"required"`
+	Longitude float64    `json:"longitude" binding:"required"`
+	ETA       Duration   `json:"eta"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ToDomain validates the bid against the evaluation currency and maps it onto contracts.Bid.
+func (d BidDTO) ToDomain(currency string) (contracts.Bid, error) {
+	invalid := &contracts.ValidationError{}
+	c, err := contracts.NormalizeCoordinate(d.Latitude, d.Longitude, "latitude", "longitude")
+	collectFields(invalid, err)
+	price, fields := d.Price.validate("price.")
+	invalid.Fields = append(invalid.Fields, fields...)
+	if len(fields) == 0 && currency != "" && d.Price.Currency != currency {
+		invalid.Fields = append(invalid.Fields, contracts.FieldError{Field: "price.currency", Reason: "must match the request currency " + currency})
+	}
+	if len(invalid.Fields) > 0 {
+		return contracts.Bid{}, invalid
+	}
+	bid := contracts.Bid{
+		ID:        d.ID,
+		DriverID:  d.DriverID,
+		TripID:    d.TripID,
+		Price:     price,
+		Latitude:  c.Lat,
+		Longitude: c.Lon,
+		ETA:       time.Duration(d.ETA),
+	}
+	if d.ExpiresAt != nil {
+		bid.ExpiresAt = *d.ExpiresAt
+	}
+	return bid, nil
+}
+
+// BidFromDomain maps a bid onto its wire form, pricing it in currency.
+func BidFromDomain(b contracts.Bid, currency string) BidDTO {
+	dto := BidDTO{
+		ID:        b.ID,
+		DriverID:  b.DriverID,
+		TripID:    b.TripID,
+		Price:     NewMoney(b.Price, currency),
+		Latitude:  b.Latitude,
+		Longitude: b.Longitude,
+		ETA:       Duration(b.ETA),
+	}
+	if !b.ExpiresAt.IsZero() {
+		expires := b.ExpiresAt.UTC()
+		dto.ExpiresAt = &expires
+	}
+	return dto
+}
+
+// EvaluationRequestDTO documents the body accepted by /bids/evaluate.
+type EvaluationRequestDTO struct {
+	Request BidRequestDTO `json:"request" binding:"required"`
+	Bids    []BidDTO      `json:"bids" binding:"required"`
+}
+
+// EvaluationResponseDTO wraps the winning bid of /bids/evaluate.
+type EvaluationResponseDTO struct {
+	Winner BidDTO `json:"winner"`
+}
+
+// TripMetricsDTO is the wire form of trip.Metrics.
+type TripMetricsDTO struct {
+	TripID      string    `json:"trip_id"`
+	TotalActive Duration  `json:"total_active"`
+	TotalPaused Duration  `json:"total_paused"`
+	StartedAt   time.Time `json:"started_at"`
+	ZoneIDs     []string  `json:"zone_ids,omitempty"`
+}
+
+// TripMetricsFromDomain maps trip metrics onto their wire form.
+func TripMetricsFromDomain(tripID string, m trip.Metrics) TripMetricsDTO {
+	return TripMetricsDTO{
+		TripID:      tripID,
+		TotalActive: Duration(m.TotalActive),
+		TotalPaused: Duration(m.TotalPaused),
+		StartedAt:   m.StartedAt.UTC(),
+		ZoneIDs:     m.ZoneIDs,
+	}
+}
+
+// collectFields appends the field errors carried by err, if any.
+func collectFields(into *contracts.ValidationError, err error) {
+	var verr *contracts.ValidationError
+	if errors.As(err, &verr) {
+		into.Fields = append(into.Fields, verr.Fields...)
+	}
+}
+
+// TripActionDTO is the body accepted by /trips/:id/state.
+type TripActionDTO struct {
+	Action string `json:"action" binding:"required"`
+}
+
+// ChatHistoryDTO is one page of a trip's chat history.
+type ChatHistoryDTO struct {
+	TripID   string         `json:"trip_id"`
+	Messages []chat.Message `json:"messages"`
+}
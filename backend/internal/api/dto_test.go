@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/trip"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func assertGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write golden: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s mismatch\n got: %s\nwant: %s", path, got, want)
+	}
+}
+
+func TestDTOGoldenFiles(t *testing.T) {
+	started := time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("CST", -6*3600))
+	bid := contracts.Bid{
+		ID: "bid-1", DriverID: "driver-a", TripID: "trip-1", Price: 42.5,
+		Latitude: 19.4326, Longitude: -99.1332, ETA: 7*time.Minute + 30*time.Second, ExpiresAt: started.Add(time.Hour),
+	}
+
+	assertGolden(t, "evaluation_response", EvaluationResponseDTO{Winner: BidFromDomain(bid, "MXN")})
+	assertGolden(t, "trip_metrics", TripMetricsFromDomain("trip-1", trip.Metrics{
+		TotalActive: 25*time.Minute + 400*time.Millisecond, TotalPaused: 90 * time.Second, StartedAt: started, ZoneIDs: []string{"cdmx"},
+	}))
+	assertGolden(t, "problem", Problem{
+		Type: "urn:kage:problem:invalid_input", Title: "Bad Request", Status: 400, Code: CodeInvalidInput,
+		Instance: "/api/v1/bids/evaluate", Errors: []contracts.FieldError{{Field: "bids[0]", Reason: errInvalidDuration.Error()}},
+	})
+}
+
+func TestBidRequestDTOFromGoldenInput(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "bid_request_input.json"))
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+	var dto BidRequestDTO
+	if err := json.Unmarshal(data, &dto); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	req, err := dto.ToDomain()
+	if err != nil {
+		t.Fatalf("to domain: %v", err)
+	}
+	expected := contracts.BidRequest{RiderID: "rider-1", TripID: "trip-1", Latitude: 19.4326, Longitude: -99.1332, MaxETA: 20 * time.Minute, MaxPrice: 120.75}
+	if req != expected {
+		t.Fatalf("expected %+v got %+v", expected, req)
+	}
+}
+
+func TestDurationDecoding(t *testing.T) {
+	valid := map[string]time.Duration{
+		`900`:         15 * time.Minute,
+		`0`:           0,
+		`"PT15M"`:     15 * time.Minute,
+		`"P1DT2H"`:    26 * time.Hour,
+		`"PT1M30.5S"`: 90*time.Second + 500*time.Millisecond,
+		`"P2D"`:       48 * time.Hour,
+	}
+	for input, expected := range valid {
+		var d Duration
+		if err := json.Unmarshal([]byte(input), &d); err != nil || time.Duration(d) != expected {
+			t.Fatalf("%s: expected %v got %v (%v)", input, expected, time.Duration(d), err)
+		}
+	}
+	for _, input := range []string{`-1`, `1.5`, `"15m"`, `"P"`, `"PT"`, `"P1Y"`, `"PT-5M"`, `true`} {
+		var d Duration
+		if err := json.Unmarshal([]byte(input), &d); err == nil {
+			t.Fatalf("%s: expected error", input)
+		}
+	}
+}
+
+func TestMoneyValidation(t *testing.T) {
+	dto := BidDTO{ID: "b1", Price: Money{Amount: "12,50", Currency: "usd"}}
+	_, err := dto.ToDomain("USD")
+	invalid, ok := err.(*contracts.ValidationError)
+	if !ok || len(invalid.Fields) != 2 || invalid.Fields[0].Field != "price.amount" || invalid.Fields[1].Field != "price.currency" {
+		t.Fatalf("expected amount and currency errors got %v", err)
+	}
+
+	dto.Price = Money{Amount: "12.50", Currency: "EUR"}
+	if _, err := dto.ToDomain("USD"); err == nil {
+		t.Fatalf("expected currency mismatch error")
+	}
+	bid, err := BidDTO{ID: "b1", Price: NewMoney(12.5, "USD")}.ToDomain("USD")
+	if err != nil || bid.Price != 12.5 {
+		t.Fatalf("expected 12.5 got %v (%v)", bid.Price, err)
+	}
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIPath serves the generated OpenAPI document.
+const OpenAPIPath = "/api/openapi.json"
+
+// operation describes one REST endpoint for both Gin registration and the OpenAPI document.
+type operation struct {
+	Method  string
+	Path    string
+	ID      string
+	Summary string
+	Tag     string
+	Auth    bool
+	// Roles restricts an authenticated operation to principals holding one of them.
+	Roles    []string
+	Query    []queryParam
+	Body     interface{}
+	Status   int
+	Response interface{}
+	// Statuses lists further codes that also answer with Response, such as a failing readiness report.
+	Statuses    []int
+	ContentType string
+	Errors      []int
+	Handler     gin.HandlerFunc
+}
+
+// queryParam documents and validates a query string parameter.
+type queryParam struct {
+	Name        string
+	Description string
+	Schema      *Schema
+}
+
+// Schema is the subset of JSON Schema used by the generated document and the request validator.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// Document is the served OpenAPI 3.1 document.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       map[string]string                `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Components holds shared schemas and security schemes.
+type Components struct {
+	Schemas         map[string]*Schema     `json:"schemas"`
+	SecuritySchemes map[string]interface{} `json:"securitySchemes"`
+}
+
+// Operation is one method entry under a path item.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *Body                 `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Body is a request body keyed by media type.
+type Body struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is one documented status of an operation.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType wraps the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// buildDocument renders the operations mounted under APIPrefix plus their deprecated aliases and the unversioned extras.
+func buildDocument(versioned, unversioned []operation) *Document {
+	gen := &schemaGenerator{schemas: make(map[string]*Schema)}
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    map[string]string{"title": "Kage Backend API", "version": "1.0.0"},
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: gen.schemas,
+			SecuritySchemes: map[string]interface{}{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+	add := func(path string, op operation, deprecated bool) {
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(map[string]*Operation)
+			doc.Paths[path] = item
+		}
+		entry := gen.operation(op)
+		if deprecated {
+			entry.Deprecated = true
+			entry.OperationID += "Legacy"
+		}
+		item[strings.ToLower(op.Method)] = entry
+	}
+	for _, op := range versioned {
+		add(openAPIPath(APIPrefix+op.Path), op, false)
+		add(openAPIPath(op.Path), op, true)
+	}
+	for _, op := range unversioned {
+		add(openAPIPath(op.Path), op, false)
+	}
+	return doc
+}
+
+// openAPIPath converts Gin's ":id" parameters into OpenAPI's "{id}" form.
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+func (g *schemaGenerator) operation(op operation) *Operation {
+	out := &Operation{OperationID: op.ID, Summary: op.Summary, Responses: make(map[string]*Response)}
+	if op.Tag != "" {
+		out.Tags = []string{op.Tag}
+	}
+	if op.Auth {
+		out.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+
+	// 1.- Path parameters come from the route template; query parameters from the operation.
+	for _, segment := range strings.Split(op.Path, "/") {
+		if strings.HasPrefix(segment, ":") {
+			out.Parameters = append(out.Parameters, Parameter{Name: segment[1:], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	for _, q := range op.Query {
+		out.Parameters = append(out.Parameters, Parameter{Name: q.Name, In: "query", Description: q.Description, Schema: q.Schema})
+	}
+	if op.Body != nil {
+		out.RequestBody = &Body{Required: true, Content: map[string]*MediaType{
+			"application/json": {Schema: g.schemaFor(reflect.TypeOf(op.Body))},
+		}}
+	}
+
+	// 2.- Document the success body and every problem status the handler can emit.
+	success := &Response{Description: http.StatusText(op.Status)}
+	if op.Response != nil {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success.Content = map[string]*MediaType{contentType: {Schema: g.schemaFor(reflect.TypeOf(op.Response))}}
+	}
+	out.Responses[strconv.Itoa(op.Status)] = success
+	for _, status := range op.Statuses {
+		out.Responses[strconv.Itoa(status)] = &Response{Description: http.StatusText(status), Content: success.Content}
+	}
+	problem := g.schemaFor(reflect.TypeOf(Problem{}))
+	errs := op.Errors
+	if op.Body != nil {
+		errs = append(append([]int(nil), errs...), http.StatusRequestEntityTooLarge)
+	}
+	for _, status := range errs {
+		out.Responses[strconv.Itoa(status)] = &Response{
+			Description: http.StatusText(status),
+			Content:     map[string]*MediaType{problemContentType: {Schema: problem}},
+		}
+	}
+	return out
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(Duration(0))
+	moneyType    = reflect.TypeOf(Money{})
+)
+
+// schemaFor maps a Go type onto a schema, registering named structs as components.
+func (g *schemaGenerator) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{OneOf: []*Schema{
+			{Type: "integer", Minimum: floatPtr(0), Description: "whole seconds"},
+			{Type: "string", Format: "duration", Pattern: isoDurationPattern.String()},
+		}}
+	case moneyType:
+		g.schemas["Money"] = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"amount":   {Type: "string", Pattern: amountPattern.String(), Description: "decimal amount"},
+				"currency": {Type: "string", Pattern: currencyPattern.String(), Description: "ISO 4217 code"},
+			},
+			Required: []string{"amount", "currency"},
+		}
+		return &Schema{Ref: "#/components/schemas/Money"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaFor(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Array:
+		n := t.Len()
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem()), MinItems: &n, MaxItems: &n}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+	return &Schema{}
+}
+
+func (g *schemaGenerator) structRef(t reflect.Type) *Schema {
+	name := strings.TrimSuffix(t.Name(), "DTO")
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := g.schemas[name]; ok {
+		return ref
+	}
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.schemas[name] = s
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaFor(f.Type)
+		if f.Tag.Get("binding") == "required" && !strings.Contains(opts, "omit") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return ref
+}
+
+func floatPtr(v float64) *float64 { return &v }
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/chat"
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/health"
+	"kage/backend/internal/heatmap"
+	"kage/backend/internal/trip"
+	"kage/backend/internal/webhook"
+)
+
+func newSpecRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), auth.NewValidator("top-secret"),
+		WithChatStore(chat.NewMemoryStore()), WithHeatmap(heatmap.NewAggregator()), WithHealth(health.NewRegistry()), WithLogLevel(new(slog.LevelVar)),
+		WithWebhooks(webhook.NewMemoryStore()))
+	router := gin.New()
+	server.RegisterRoutes(router)
+	return router
+}
+
+func TestEveryRouteHasSpecEntry(t *testing.T) {
+	// 1.- Fetch the served document.
+	router := newSpecRouter(t)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", res.Code)
+	}
+	var doc Document
+	if err := json.Unmarshal(res.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Fatalf("unexpected version %q", doc.OpenAPI)
+	}
+
+	// 2.- Every registered route must be documented; legacy aliases must be flagged deprecated.
+	for _, route := range router.Routes() {
+		entry, ok := doc.Paths[openAPIPath(route.Path)][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("route %s %s has no OpenAPI entry", route.Method, route.Path)
+			continue
+		}
+		_, legacy := doc.Paths[openAPIPath(APIPrefix+route.Path)]
+		if entry.Deprecated != legacy {
+			t.Errorf("route %s %s: deprecated=%v", route.Method, route.Path, entry.Deprecated)
+		}
+	}
+
+	// 3.- Component schemas carry the wire constraints the validator relies on.
+	bid := doc.Components.Schemas["Bid"]
+	if bid == nil || bid.Properties["price"].Ref != "#/components/schemas/Money" || strings.Join(bid.Required, ",") != "id,latitude,longitude,price" {
+		t.Fatalf("unexpected Bid schema %+v", bid)
+	}
+	if eta := bid.Properties["eta"]; len(eta.OneOf) != 2 {
+		t.Fatalf("durations should accept seconds or ISO-8601: %+v", eta)
+	}
+}
+
+func TestRequestValidationAgainstSpec(t *testing.T) {
+	router := newSpecRouter(t)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer top-secret")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+	fields := func(res *httptest.ResponseRecorder) []contracts.FieldError {
+		var problem Problem
+		if err := json.Unmarshal(res.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("decode problem: %v", err)
+		}
+		return problem.Errors
+	}
+
+	// 1.- Missing members, wrong types and bad patterns are all reported with their paths.
+	res := do(http.MethodPost, "/api/v1/bids/evaluate", `{"request":{"latitude":"north","longitude":1},
+		"bids":[{"id":"b1","latitude":1,"longitude":1,"eta":"soon","price":{"amount":"1,5","currency":"usd"}}]}`)
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", res.Code)
+	}
+	got := fields(res)
+	want := []string{"bids[0].eta", "bids[0].price.amount", "bids[0].price.currency", "request.trip_id", "request.latitude"}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected field errors %+v", got)
+	}
+	for i, f := range got {
+		if f.Field != want[i] {
+			t.Fatalf("field %d: want %s got %+v", i, want[i], got)
+		}
+	}
+
+	// 2.- Non-JSON bodies and out-of-range query parameters are rejected before the handler.
+	if res := do(http.MethodPost, "/api/v1/trips/t1/state", `action=start`); res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed body got %d", res.Code)
+	}
+	if res := do(http.MethodPost, "/api/v1/trips/t1/state", `{}`); res.Code != http.StatusBadRequest || fields(res)[0].Field != "action" {
+		t.Fatalf("expected missing action to be reported, got %d %s", res.Code, res.Body.String())
+	}
+	if res := do(http.MethodGet, "/api/v1/trips/t1/messages?limit=0", ""); res.Code != http.StatusBadRequest || fields(res)[0].Field != "limit" {
+		t.Fatalf("expected limit rejected, got %d %s", res.Code, res.Body.String())
+	}
+	oversized := `{"action":"start","padding":"` + strings.Repeat("x", maxBodyBytes) + `"}`
+	if res := do(http.MethodPost, "/api/v1/trips/t1/state", oversized); res.Code != http.StatusRequestEntityTooLarge || !strings.Contains(res.Body.String(), CodePayloadTooLarge) {
+		t.Fatalf("expected 413 problem for oversized body, got %d %s", res.Code, res.Body.String())
+	}
+
+	// 3.- Valid input still reaches the handler.
+	if res := do(http.MethodGet, "/api/v1/trips/t1/messages?limit=5&after=2025-01-01T00:00:00Z", ""); res.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d %s", res.Code, res.Body.String())
+	}
+}
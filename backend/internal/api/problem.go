@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/heatmap"
+	"kage/backend/internal/trip"
+	"kage/backend/internal/webhook"
+)
+
+// Stable machine-readable error codes carried in every problem response.
+const (
+	CodeInvalidInput       = "invalid_input"
+	CodePayloadTooLarge    = "payload_too_large"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeNoBidsAccepted     = "no_bids_accepted"
+	CodeInvalidTransition  = "invalid_transition"
+	CodeUnknownAction      = "unknown_action"
+	CodeOutsideServiceArea = "outside_service_area"
+	CodeNoPickupZone       = "no_pickup_zone"
+	CodeEvaluationTimeout  = "evaluation_timeout"
+	CodeUpstreamTimeout    = "upstream_timeout"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+)
+
+const problemContentType = "application/problem+json"
+
+// errUnknownAction occurs when a trip state change names an unsupported action.
+var errUnknownAction = errors.New("unknown action")
+
+// Problem is an RFC 7807 error document extended with a stable code and field errors.
+type Problem struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Code     string                 `json:"code"`
+	Errors   []contracts.FieldError `json:"errors,omitempty"`
+}
+
+// problemStatus maps domain errors to their HTTP status and code; unknown errors become 500s.
+func problemStatus(err error) (int, string) {
+	var invalid *contracts.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		return http.StatusBadRequest, CodeInvalidInput
+	case errors.Is(err, trip.ErrInvalidTransition):
+		return http.StatusConflict, CodeInvalidTransition
+	case errors.Is(err, errUnknownAction):
+		return http.StatusBadRequest, CodeUnknownAction
+	case errors.Is(err, bidding.ErrEvaluationTimeout):
+		return http.StatusGatewayTimeout, CodeEvaluationTimeout
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, CodeUpstreamTimeout
+	case errors.Is(err, bidding.ErrOutsideServiceArea):
+		return http.StatusUnprocessableEntity, CodeOutsideServiceArea
+	case errors.Is(err, bidding.ErrNoPickupZone):
+		return http.StatusUnprocessableEntity, CodeNoPickupZone
+	case errors.Is(err, heatmap.ErrInvalidResolution):
+		return http.StatusBadRequest, CodeInvalidInput
+	case errors.Is(err, webhook.ErrNotFound):
+		return http.StatusNotFound, CodeNotFound
+	}
+	return http.StatusInternalServerError, CodeInternal
+}
+
+// abortWithError writes the problem document matching err and stops the handler chain.
+func abortWithError(c *gin.Context, err error) {
+	status, code := problemStatus(err)
+	p := Problem{Status: status, Code: code, Detail: err.Error()}
+	var invalid *contracts.ValidationError
+	if errors.As(err, &invalid) {
+		p.Errors = invalid.Fields
+	}
+	if status == http.StatusInternalServerError {
+		// 1.- Keep internal failure details out of client responses.
+		p.Detail = ""
+	}
+	abortWithProblem(c, p)
+}
+
+// abortWithStatus writes a problem document with an explicit status, code, and detail.
+func abortWithStatus(c *gin.Context, status int, code, detail string) {
+	abortWithProblem(c, Problem{Status: status, Code: code, Detail: detail})
+}
+
+func abortWithProblem(c *gin.Context, p Problem) {
+	p.Type = "urn:kage:problem:" + p.Code
+	p.Title = http.StatusText(p.Status)
+	p.Instance = c.Request.URL.Path
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/trip"
+)
+
+func TestProblemStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{trip.ErrInvalidTransition, http.StatusConflict, CodeInvalidTransition},
+		{fmt.Errorf("wrapped: %w", bidding.ErrEvaluationTimeout), http.StatusGatewayTimeout, CodeEvaluationTimeout},
+		{fmt.Errorf("save accepted bid: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, CodeUpstreamTimeout},
+		{bidding.ErrOutsideServiceArea, http.StatusUnprocessableEntity, CodeOutsideServiceArea},
+		{bidding.ErrNoPickupZone, http.StatusUnprocessableEntity, CodeNoPickupZone},
+		{&contracts.ValidationError{}, http.StatusBadRequest, CodeInvalidInput},
+		{errUnknownAction, http.StatusBadRequest, CodeUnknownAction},
+		{errors.New("database down"), http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tc := range tests {
+		status, code := problemStatus(tc.err)
+		if status != tc.status || code != tc.code {
+			t.Fatalf("%v: expected %d/%s got %d/%s", tc.err, tc.status, tc.code, status, code)
+		}
+	}
+}
+
+func TestVersionedRoutesAndProblems(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), auth.NewValidator("top-secret"))
+	router := gin.New()
+	server.RegisterRoutes(router)
+
+	do := func(path, action string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(`{"action":"`+action+`"}`)))
+		req.Header.Set("Authorization", "Bearer top-secret")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	// 1.- The versioned route works without deprecation headers.
+	res := do("/api/v1/trips/trip-v/state", "start")
+	if res.Code != http.StatusNoContent || res.Header().Get("Deprecation") != "" {
+		t.Fatalf("expected 204 without deprecation, got %d %v", res.Code, res.Header())
+	}
+
+	// 2.- The legacy alias still works but announces its successor.
+	res = do("/trips/trip-v/state", "pause")
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 got %d", res.Code)
+	}
+	if res.Header().Get("Deprecation") != "true" || res.Header().Get("Link") != `</api/v1/trips/trip-v/state>; rel="successor-version"` {
+		t.Fatalf("missing deprecation headers: %v", res.Header())
+	}
+
+	// 3.- Invalid transitions surface as 409 problem documents with a stable code.
+	res = do("/api/v1/trips/trip-v/state", "start")
+	if res.Code != http.StatusConflict {
+		t.Fatalf("expected 409 got %d", res.Code)
+	}
+	if ct := res.Header().Get("Content-Type"); ct != problemContentType {
+		t.Fatalf("expected problem content type got %q", ct)
+	}
+	var problem Problem
+	if err := json.Unmarshal(res.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if problem.Code != CodeInvalidTransition || problem.Status != http.StatusConflict || problem.Instance != "/api/v1/trips/trip-v/state" {
+		t.Fatalf("unexpected problem %+v", problem)
+	}
+
+	// 4.- Authentication failures use the same error model.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trips/trip-v/metrics", nil)
+	unauth := httptest.NewRecorder()
+	router.ServeHTTP(unauth, req)
+	if unauth.Code != http.StatusUnauthorized || unauth.Header().Get("Content-Type") != problemContentType {
+		t.Fatalf("expected 401 problem got %d %q", unauth.Code, unauth.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/ratelimit"
+)
+
+// anyRoute is the single bucket route of the per-IP limiter, shared by every API call.
+const anyRoute = "*"
+
+// WithRateLimiter throttles every API route the limiter has a limit for, per principal or client IP.
+// Limits name routes without APIPrefix, such as "/bids/evaluate", and cover the legacy alias too.
+func WithRateLimiter(limiter *ratelimit.Limiter) ServerOption {
+	return func(s *Server) { s.limiter = limiter }
+}
+
+// WithIPRateLimiter throttles authenticated API calls per client IP before credentials are checked,
+// so a flood of bad tokens is refused without verifying each one. Its default limit applies to all routes.
+func WithIPRateLimiter(limiter *ratelimit.Limiter) ServerOption {
+	return func(s *Server) { s.perIP = limiter }
+}
+
+// limitRoute names the bucket of the matched route; the versioned path and its alias share one.
+func limitRoute(c *gin.Context) string {
+	return strings.TrimPrefix(c.FullPath(), APIPrefix)
+}
+
+// rateLimit spends a token of the caller's bucket for the route.
+func (s *Server) rateLimit(c *gin.Context) {
+	key := "ip:" + c.ClientIP()
+	if value, ok := c.Get(principalKey); ok {
+		key = "principal:" + value.(auth.Principal).ID
+	}
+	s.throttle(c, s.limiter, limitRoute(c), key)
+}
+
+// rateLimitIP spends a token of the client IP's bucket shared by every route.
+func (s *Server) rateLimitIP(c *gin.Context) {
+	s.throttle(c, s.perIP, anyRoute, "ip:"+c.ClientIP())
+}
+
+// throttle takes a token from limiter and answers 429 once the bucket is empty.
+// Store failures let the request through so a Redis outage does not take the API down.
+func (s *Server) throttle(c *gin.Context, limiter *ratelimit.Limiter, route, key string) {
+	d, limited, err := limiter.Take(c.Request.Context(), route, key)
+	if err != nil {
+		s.logger.WarnContext(c.Request.Context(), "rate limiter unavailable", "error", err)
+		return
+	}
+	if !limited {
+		return
+	}
+
+	// 1.- Advertise the bucket on every response so clients can pace themselves.
+	c.Header("RateLimit-Limit", strconv.Itoa(d.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	c.Header("RateLimit-Reset", ceilSeconds(d.Reset))
+	if d.Allowed {
+		return
+	}
+	c.Header("Retry-After", ceilSeconds(d.RetryAfter))
+	abortWithStatus(c, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded, retry later")
+}
+
+// ceilSeconds renders d as whole seconds, rounding up so clients never retry too early.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/ratelimit"
+	"kage/backend/internal/trip"
+)
+
+func TestRateLimitPerPrincipalAndRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validator := auth.NewValidator("top-secret")
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(),
+		ratelimit.WithRoute("/trips/:id/metrics", ratelimit.Limit{Rate: 0.5, Burst: 1}))
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), validator, WithRateLimiter(limiter))
+	router := gin.New()
+	server.RegisterRoutes(router)
+	alice := validator.Issue(auth.Principal{ID: "alice", Role: auth.RoleRider}, time.Minute)
+	bob := validator.Issue(auth.Principal{ID: "bob", Role: auth.RoleRider}, time.Minute)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", token)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	// 1.- The first call spends the only token and advertises the bucket.
+	res := get("/api/v1/trips/t1/metrics", alice)
+	if res.Code == http.StatusTooManyRequests || res.Header().Get("RateLimit-Limit") != "1" || res.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("unexpected first response %d %v", res.Code, res.Header())
+	}
+
+	// 2.- The second call from the same principal is refused with a retry hint.
+	res = get("/api/v1/trips/t2/metrics", alice)
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") != "2" || res.Header().Get("Content-Type") != problemContentType {
+		t.Fatalf("expected 429 problem with Retry-After got %d %v", res.Code, res.Header())
+	}
+
+	// 3.- The legacy alias draws from the same bucket, while other principals and routes are unaffected.
+	if res := get("/trips/t1/metrics", alice); res.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the legacy alias to share the versioned bucket got %d", res.Code)
+	}
+	if res := get("/trips/t1/metrics", bob); res.Code == http.StatusTooManyRequests {
+		t.Fatalf("expected a separate bucket per principal")
+	}
+	if res := get("/api/v1/trips/t1/state", alice); res.Code == http.StatusTooManyRequests || res.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("expected route without a configured limit to pass got %d", res.Code)
+	}
+}
+
+func TestIPRateLimitRunsBeforeAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	perIP := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.WithDefault(ratelimit.Limit{Rate: 0.5, Burst: 2}))
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), auth.NewValidator("top-secret"), WithIPRateLimiter(perIP))
+	router := gin.New()
+	server.RegisterRoutes(router)
+	get := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", token)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	// 1.- Bad tokens spend the client's IP tokens on the versioned path and its alias alike.
+	if code := get("/api/v1/trips/t1/metrics", "Bearer forged"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 got %d", code)
+	}
+	if code := get("/trips/t2/metrics", "Bearer forged"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 got %d", code)
+	}
+
+	// 2.- Once the bucket is empty the client is refused before its credentials are checked.
+	if code := get("/trips/t1/metrics", "Bearer top-secret"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 before authentication got %d", code)
+	}
+
+	// 3.- Probes are never throttled by IP.
+	if code := get("/health", ""); code != http.StatusOK {
+		t.Fatalf("expected unthrottled health probe got %d", code)
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that did not resolve to a registered route.
+const unmatchedRoute = "unmatched"
+
+// Recorder receives HTTP instrumentation.
+type Recorder interface {
+	ObserveRequest(method, route string, status int, elapsed time.Duration)
+}
+
+// WithRecorder times every request on the engine, labelled by route template and status.
+func WithRecorder(r Recorder) ServerOption {
+	return func(s *Server) { s.recorder = r }
+}
+
+// instrument reports the latency of the request once the chain has finished.
+func (s *Server) instrument(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	s.recorder.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+}
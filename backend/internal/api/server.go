@@ -1,107 +1,333 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"kage/backend/internal/auth"
 	"kage/backend/internal/bidding"
+	"kage/backend/internal/chat"
 	"kage/backend/internal/contracts"
+	"kage/backend/internal/geo"
+	"kage/backend/internal/health"
+	"kage/backend/internal/heatmap"
+	"kage/backend/internal/logging"
+	"kage/backend/internal/ratelimit"
 	"kage/backend/internal/trip"
+	"kage/backend/internal/webhook"
 )
 
 // Server bundles HTTP handlers for the backend APIs.
 type Server struct {
-	arbiter *bidding.Arbiter
-	trips   *trip.Manager
-	auth    *auth.Validator
+	arbiter  *bidding.Arbiter
+	trips    *trip.Manager
+	auth     *auth.Validator
+	chat     chat.Store
+	heatmap  *heatmap.Aggregator
+	health   *health.Registry
+	recorder Recorder
+	logLevel *slog.LevelVar
+	logger   *slog.Logger
+	limiter  *ratelimit.Limiter
+	perIP    *ratelimit.Limiter
+	webhooks webhook.Store
+	spec     *Document
+}
+
+// ServerOption mutates Server configuration.
+type ServerOption func(*Server)
+
+// WithChatStore exposes trip chat history through the REST API.
+func WithChatStore(store chat.Store) ServerOption {
+	return func(s *Server) { s.chat = store }
+}
+
+// WithHeatmap records demand signals and serves them through /geo/heatmap.
+func WithHeatmap(aggregator *heatmap.Aggregator) ServerOption {
+	return func(s *Server) { s.heatmap = aggregator }
+}
+
+// WithHealth serves /livez and /readyz, the latter running every registered checker.
+func WithHealth(registry *health.Registry) ServerOption {
+	return func(s *Server) { s.health = registry }
 }
 
 // NewServer constructs a Server instance.
-func NewServer(arbiter *bidding.Arbiter, trips *trip.Manager, validator *auth.Validator) *Server {
-	return &Server{arbiter: arbiter, trips: trips, auth: validator}
+func NewServer(arbiter *bidding.Arbiter, trips *trip.Manager, validator *auth.Validator, opts ...ServerOption) *Server {
+	s := &Server{arbiter: arbiter, trips: trips, auth: validator, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// RegisterRoutes configures Gin routes for REST endpoints.
-func (s *Server) RegisterRoutes(router *gin.Engine) {
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+// APIPrefix is the mount point of the current REST API version.
+const APIPrefix = "/api/v1"
 
-	router.POST("/bids/evaluate", func(c *gin.Context) {
-		if err := s.requireAuth(c); err != nil {
-			return
+// RegisterRoutes configures Gin routes for REST endpoints and serves their OpenAPI document.
+// With a Recorder configured, the timing middleware also covers routes mounted on the engine afterwards.
+func (s *Server) RegisterRoutes(router *gin.Engine) {
+	if s.recorder != nil {
+		router.Use(s.instrument)
+	}
+	ops := s.operations()
+	if s.limiter != nil || s.perIP != nil {
+		for i := range ops {
+			ops[i].Errors = append(ops[i].Errors[:len(ops[i].Errors):len(ops[i].Errors)], http.StatusTooManyRequests)
 		}
+	}
+	extras := []operation{
+		{Method: http.MethodGet, Path: "/health", ID: "health", Summary: "Liveness probe", Tag: "system", Status: http.StatusOK, Response: map[string]string{}, Handler: func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		}},
+		{Method: http.MethodGet, Path: OpenAPIPath, ID: "openapi", Summary: "This OpenAPI document", Tag: "system", Status: http.StatusOK, Response: map[string]interface{}{}, Handler: func(c *gin.Context) {
+			c.JSON(http.StatusOK, s.spec)
+		}},
+	}
+	if s.health != nil {
+		extras = append(extras,
+			operation{Method: http.MethodGet, Path: "/livez", ID: "livez", Summary: "Process liveness; never consults dependencies", Tag: "system", Status: http.StatusOK, Response: map[string]string{}, Handler: func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+			}},
+			operation{Method: http.MethodGet, Path: "/readyz", ID: "readyz", Summary: "Readiness with a report per dependency check", Tag: "system", Status: http.StatusOK, Statuses: []int{http.StatusServiceUnavailable}, Response: health.Report{}, Handler: s.handleReadyz},
+		)
+	}
+	s.spec = buildDocument(ops, extras)
+	for _, op := range extras {
+		router.Handle(op.Method, op.Path, op.Handler)
+	}
 
-		var payload struct {
-			Request contracts.BidRequest `json:"request"`
-			Bids    []contracts.Bid      `json:"bids"`
-		}
-		if err := c.ShouldBindJSON(&payload); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
+	// 1.- Mount the versioned API and keep the unversioned paths as deprecated aliases.
+	s.registerAPI(router.Group(APIPrefix), ops)
+	s.registerAPI(router.Group("", deprecated), ops)
+}
 
-		// 1.- Evaluate incoming bids against rider constraints and capture the winner.
-		winner, ok, err := s.arbiter.RankAndSelect(c.Request.Context(), payload.Request, payload.Bids)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+// operations lists the REST endpoints; both routing and the OpenAPI document derive from it.
+func (s *Server) operations() []operation {
+	ops := []operation{
+		{
+			Method: http.MethodPost, Path: "/bids/evaluate", ID: "evaluateBids", Tag: "bidding", Auth: true, Roles: adminRoles,
+			Summary: "Rank driver bids for a rider request and accept the winner",
+			Body:    EvaluationRequestDTO{}, Status: http.StatusOK, Response: EvaluationResponseDTO{},
+			Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusGatewayTimeout},
+			Handler: s.handleEvaluate,
+		},
+		{
+			Method: http.MethodPost, Path: "/trips/:id/state", ID: "changeTripState", Tag: "trips", Auth: true,
+			Summary: "Apply a lifecycle action (start, pause, resume, cancel, complete) to a trip",
+			Body:    TripActionDTO{}, Status: http.StatusNoContent,
+			Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict},
+			Handler: s.handleTripState,
+		},
+		{
+			Method: http.MethodGet, Path: "/trips/:id/metrics", ID: "getTripMetrics", Tag: "trips", Auth: true,
+			Summary: "Report active and paused durations of a trip",
+			Status:  http.StatusOK, Response: TripMetricsDTO{},
+			Errors:  []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+			Handler: s.handleTripMetrics,
+		},
+	}
+	if s.chat != nil {
+		ops = append(ops, operation{
+			Method: http.MethodGet, Path: "/trips/:id/messages", ID: "listTripMessages", Tag: "chat", Auth: true,
+			Summary: "Page through the chat history of a trip",
+			Query: []queryParam{
+				{Name: "after", Description: "return messages received after this instant", Schema: &Schema{Type: "string", Format: "date-time"}},
+				{Name: "limit", Description: "page size", Schema: &Schema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(500)}},
+			},
+			Status: http.StatusOK, Response: ChatHistoryDTO{},
+			Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+			Handler: s.handleChatHistory,
+		})
+	}
+	if s.heatmap != nil {
+		ops = append(ops, operation{
+			Method: http.MethodGet, Path: "/geo/heatmap", ID: "getDemandHeatmap", Tag: "geo", Auth: true,
+			Summary: "Render decayed demand per geohash cell as GeoJSON",
+			Query: []queryParam{
+				{Name: "bbox", Description: "minLat,minLon,maxLat,maxLon", Schema: &Schema{Type: "string"}},
+				{Name: "resolution", Description: "geohash precision", Schema: &Schema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(12)}},
+			},
+			Status: http.StatusOK, Response: heatmap.FeatureCollection{}, ContentType: "application/geo+json",
+			Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized},
+			Handler: s.handleHeatmap,
+		})
+	}
+	ops = append(ops, s.webhookOperations()...)
+	return append(ops, s.adminOperations()...)
+}
+
+func (s *Server) registerAPI(r gin.IRoutes, ops []operation) {
+	validator := &specValidator{schemas: s.spec.Components.Schemas}
+	for _, op := range ops {
+		// 1.- Authenticate before validating so anonymous callers learn nothing about the schema.
+		var chain []gin.HandlerFunc
+		if op.Auth && s.perIP != nil {
+			chain = append(chain, s.rateLimitIP)
 		}
-		if !ok {
-			c.JSON(http.StatusNotFound, gin.H{"error": "no bids accepted"})
-			return
+		if op.Auth {
+			chain = append(chain, s.authenticate)
 		}
-
-		// 2.- Return the accepted bid to the caller.
-		c.JSON(http.StatusOK, gin.H{"winner": winner})
-	})
-
-	router.POST("/trips/:id/state", func(c *gin.Context) {
-		if err := s.requireAuth(c); err != nil {
-			return
+		if len(op.Roles) > 0 {
+			chain = append(chain, requireRole(op.Roles))
 		}
-		action := struct {
-			Action string `json:"action"`
-		}{}
-		if err := c.ShouldBindJSON(&action); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+		if s.limiter != nil {
+			chain = append(chain, s.rateLimit)
 		}
-		if err := s.handleTripAction(c, c.Param("id"), action.Action); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+		if strings.HasPrefix(op.Path, "/trips/:id") {
+			chain = append(chain, tagTrip, s.requireParticipant)
 		}
-		c.Status(http.StatusNoContent)
-	})
+		spec := s.spec.Paths[openAPIPath(APIPrefix+op.Path)][strings.ToLower(op.Method)]
+		chain = append(chain, validator.validateRequest(spec), op.Handler)
+		r.Handle(op.Method, op.Path, chain...)
+	}
+}
+
+// deprecated marks legacy unversioned routes and points clients at their successor.
+func deprecated(c *gin.Context) {
+	c.Header("Deprecation", "true")
+	c.Header("Link", "<"+APIPrefix+c.Request.URL.Path+`>; rel="successor-version"`)
+}
+
+func (s *Server) handleEvaluate(c *gin.Context) {
+	payload, err := bindEvaluation(c)
+	var invalid *contracts.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		abortWithError(c, err)
+		return
+	case err != nil:
+		abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, err.Error())
+		return
+	}
 
-	router.GET("/trips/:id/metrics", func(c *gin.Context) {
-		if err := s.requireAuth(c); err != nil {
+	if s.heatmap != nil {
+		s.heatmap.RecordRequest(payload.Request)
+	}
+
+	// 1.- Evaluate incoming bids against rider constraints and capture the winner.
+	winner, ok, err := s.arbiter.RankAndSelect(c.Request.Context(), payload.Request, payload.Bids)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !ok {
+		abortWithStatus(c, http.StatusNotFound, CodeNoBidsAccepted, "no bids satisfied the request constraints")
+		return
+	}
+
+	// 2.- Record who rides and drives the trip, tag its pickup zones and return the accepted bid to the caller.
+	s.trips.AssignParticipants(payload.Request.TripID, contracts.TripParticipants{RiderID: payload.Request.RiderID, DriverID: winner.DriverID})
+	if zoneIDs, err := s.arbiter.PickupZones(payload.Request); err == nil && len(zoneIDs) > 0 {
+		s.trips.TagZones(payload.Request.TripID, zoneIDs)
+	}
+	c.JSON(http.StatusOK, EvaluationResponseDTO{Winner: BidFromDomain(winner, payload.Currency)})
+}
+
+func (s *Server) handleTripState(c *gin.Context) {
+	var action TripActionDTO
+	if err := c.ShouldBindJSON(&action); err != nil {
+		abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, err.Error())
+		return
+	}
+	if err := s.handleTripAction(c, c.Param("id"), action.Action); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if action.Action == "start" && s.heatmap != nil {
+		s.heatmap.RecordTripStart(c.Param("id"))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) handleTripMetrics(c *gin.Context) {
+	metrics, ok := s.trips.MetricsFor(c.Param("id"))
+	if !ok {
+		abortWithStatus(c, http.StatusNotFound, CodeNotFound, "trip not found")
+		return
+	}
+	c.JSON(http.StatusOK, TripMetricsFromDomain(c.Param("id"), metrics))
+}
+
+func (s *Server) handleChatHistory(c *gin.Context) {
+	// 1.- Parse the optional RFC 3339 cursor and page size.
+	var after time.Time
+	if v := c.Query("after"); v != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, "after must be an RFC 3339 timestamp")
 			return
 		}
-		metrics, ok := s.trips.MetricsFor(c.Param("id"))
-		if !ok {
-			c.JSON(http.StatusNotFound, gin.H{"error": "trip not found"})
+		after = parsed
+	}
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, "limit must be between 1 and 500")
 			return
 		}
-		c.JSON(http.StatusOK, metrics)
-	})
+		limit = n
+	}
+
+	// 2.- Load the history page for the trip.
+	messages, err := s.chat.History(c.Request.Context(), c.Param("id"), after, limit)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if messages == nil {
+		messages = []chat.Message{}
+	}
+	c.JSON(http.StatusOK, ChatHistoryDTO{TripID: c.Param("id"), Messages: messages})
 }
 
-func (s *Server) requireAuth(c *gin.Context) error {
+// authenticate resolves the bearer principal or aborts with a 401 problem.
+func (s *Server) authenticate(c *gin.Context) {
 	if s.auth == nil {
-		return nil
+		return
 	}
-	token := c.GetHeader("Authorization")
-	if err := s.auth.ValidateToken(token); err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return err
+	principal, err := s.auth.Authenticate(c.GetHeader("Authorization"))
+	if err != nil {
+		abortWithStatus(c, http.StatusUnauthorized, CodeUnauthorized, "missing or invalid credentials")
+		return
 	}
-	return nil
+	c.Set(principalKey, principal)
+	c.Request = c.Request.WithContext(logging.WithPrincipal(c.Request.Context(), principal.ID))
 }
 
+// tagTrip labels logs written while serving a trip route with its id.
+func tagTrip(c *gin.Context) {
+	c.Request = c.Request.WithContext(logging.WithTripID(c.Request.Context(), c.Param("id")))
+}
+
+// requireParticipant aborts with a 403 problem unless the principal rides or drives the trip.
+// Service and ops principals act on any trip.
+func (s *Server) requireParticipant(c *gin.Context) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return
+	}
+	principal := value.(auth.Principal)
+	if slices.Contains(adminRoles, principal.Role) {
+		return
+	}
+	if p, ok := s.trips.Participants(c.Request.Context(), c.Param("id")); !ok || !p.Includes(principal.ID) {
+		abortWithStatus(c, http.StatusForbidden, CodeForbidden, "principal is not a participant of this trip")
+	}
+}
+
+const principalKey = "principal"
+
 func (s *Server) handleTripAction(c *gin.Context, tripID, action string) error {
 	switch action {
 	case "start":
@@ -115,6 +341,112 @@ func (s *Server) handleTripAction(c *gin.Context, tripID, action string) error {
 	case "complete":
 		return s.trips.CompleteTrip(c.Request.Context(), tripID)
 	default:
-		return errors.New("unknown action")
+		return errUnknownAction
+	}
+}
+
+// evaluationPayload is the decoded body accepted by /bids/evaluate.
+type evaluationPayload struct {
+	Request  contracts.BidRequest
+	Bids     []contracts.Bid
+	Currency string
+}
+
+// bindEvaluation decodes the request and each bid separately so that every
+// invalid field is reported at once, qualified with its position in the body.
+func bindEvaluation(c *gin.Context) (evaluationPayload, error) {
+	var raw struct {
+		Request json.RawMessage   `json:"request"`
+		Bids    []json.RawMessage `json:"bids"`
+	}
+	if err := c.ShouldBindJSON(&raw); err != nil {
+		return evaluationPayload{}, err
+	}
+
+	invalid := &contracts.ValidationError{}
+	collect := func(prefix string, err error) bool {
+		var verr *contracts.ValidationError
+		switch {
+		case errors.As(err, &verr):
+			invalid.Fields = append(invalid.Fields, verr.WithPrefix(prefix+".").Fields...)
+		case err != nil:
+			invalid.Fields = append(invalid.Fields, contracts.FieldError{Field: prefix, Reason: err.Error()})
+		}
+		return err == nil
+	}
+
+	// 1.- Decode the wire forms; the request's max price fixes the currency, else the first bid does.
+	var request BidRequestDTO
+	requestOK := len(raw.Request) == 0 || collect("request", json.Unmarshal(raw.Request, &request))
+	bids := make([]BidDTO, len(raw.Bids))
+	bidsOK := make([]bool, len(raw.Bids))
+	for i, bid := range raw.Bids {
+		bidsOK[i] = collect(fmt.Sprintf("bids[%d]", i), json.Unmarshal(bid, &bids[i]))
+	}
+	var payload evaluationPayload
+	if request.MaxPrice != nil {
+		payload.Currency = request.MaxPrice.Currency
+	} else if len(bids) > 0 {
+		payload.Currency = bids[0].Price.Currency
+	}
+
+	// 2.- Map onto domain types, collecting validation failures from every element.
+	if requestOK {
+		req, err := request.ToDomain()
+		collect("request", err)
+		payload.Request = req
+	}
+	payload.Bids = make([]contracts.Bid, len(bids))
+	for i, dto := range bids {
+		if !bidsOK[i] {
+			continue
+		}
+		bid, err := dto.ToDomain(payload.Currency)
+		collect(fmt.Sprintf("bids[%d]", i), err)
+		payload.Bids[i] = bid
+	}
+	if len(invalid.Fields) > 0 {
+		return evaluationPayload{}, invalid
+	}
+	return payload, nil
+}
+
+func (s *Server) handleReadyz(c *gin.Context) {
+	report := s.health.Run(c.Request.Context())
+	status := http.StatusOK
+	if !report.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+func (s *Server) handleHeatmap(c *gin.Context) {
+	// 1.- Parse the optional bounding box and the geohash resolution.
+	var bbox *geo.BoundingBox
+	if v := c.Query("bbox"); v != "" {
+		box, err := geo.ParseBoundingBox(v)
+		if err != nil {
+			abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, err.Error())
+			return
+		}
+		bbox = &box
+	}
+	resolution := 6
+	if v := c.Query("resolution"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, "resolution must be an integer geohash precision")
+			return
+		}
+		resolution = n
+	}
+
+	// 2.- Roll the decayed counts up and render them as GeoJSON.
+	cells, err := s.heatmap.Snapshot(bbox, resolution)
+	if err != nil {
+		abortWithError(c, err)
+		return
 	}
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, heatmap.GeoJSON(cells))
 }
@@ -4,16 +4,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 
 	"kage/backend/internal/auth"
 	"kage/backend/internal/bidding"
+	"kage/backend/internal/chat"
 	"kage/backend/internal/contracts"
+	"kage/backend/internal/dialect"
+	"kage/backend/internal/health"
+	"kage/backend/internal/heatmap"
+	"kage/backend/internal/tracing"
 	"kage/backend/internal/trip"
 )
 
@@ -37,14 +45,16 @@ func TestEvaluateBidsAuthorized(t *testing.T) {
 	router := gin.New()
 	server.RegisterRoutes(router)
 
+	expires := time.Now().Add(time.Hour)
+	maxPrice := NewMoney(60, "USD")
 	payload := struct {
-		Request contracts.BidRequest `json:"request"`
-		Bids    []contracts.Bid      `json:"bids"`
+		Request BidRequestDTO `json:"request"`
+		Bids    []BidDTO      `json:"bids"`
 	}{
-		Request: contracts.BidRequest{TripID: "trip-1", RiderID: "rider-1", Latitude: 1.0, Longitude: 2.0, MaxPrice: 60, MaxETA: 45 * time.Minute},
-		Bids: []contracts.Bid{
-			{ID: "bid-1", TripID: "trip-1", DriverID: "driver-a", Price: 50, Latitude: 1.01, Longitude: 2.01, ETA: 30 * time.Minute, ExpiresAt: time.Now().Add(time.Hour)},
-			{ID: "bid-2", TripID: "trip-1", DriverID: "driver-b", Price: 70, Latitude: 1.02, Longitude: 2.02, ETA: 35 * time.Minute, ExpiresAt: time.Now().Add(time.Hour)},
+		Request: BidRequestDTO{TripID: "trip-1", RiderID: "rider-1", Latitude: 1.0, Longitude: 2.0, MaxPrice: &maxPrice, MaxETA: Duration(45 * time.Minute)},
+		Bids: []BidDTO{
+			{ID: "bid-1", TripID: "trip-1", DriverID: "driver-a", Price: NewMoney(50, "USD"), Latitude: 1.01, Longitude: 2.01, ETA: Duration(30 * time.Minute), ExpiresAt: &expires},
+			{ID: "bid-2", TripID: "trip-1", DriverID: "driver-b", Price: NewMoney(70, "USD"), Latitude: 1.02, Longitude: 2.02, ETA: Duration(35 * time.Minute), ExpiresAt: &expires},
 		},
 	}
 	body, err := json.Marshal(payload)
@@ -63,17 +73,21 @@ func TestEvaluateBidsAuthorized(t *testing.T) {
 	if res.Code != http.StatusOK {
 		t.Fatalf("expected 200 got %d", res.Code)
 	}
-	var envelope struct {
-		Winner contracts.Bid `json:"winner"`
-	}
+	var envelope EvaluationResponseDTO
 	if err := json.Unmarshal(res.Body.Bytes(), &envelope); err != nil {
 		t.Fatalf("decode response: %v", err)
 	}
 	if envelope.Winner.ID != "bid-1" {
 		t.Fatalf("unexpected winner: %s", envelope.Winner.ID)
 	}
-	if len(repo.saved) != 1 {
-		t.Fatalf("expected winner persisted, got %d saves", len(repo.saved))
+	if envelope.Winner.Price != NewMoney(50, "USD") || envelope.Winner.ETA != Duration(30*time.Minute) {
+		t.Fatalf("unexpected winner wire values: %+v", envelope.Winner)
+	}
+	if len(repo.saved) != 1 || repo.saved[0].RiderID != "rider-1" {
+		t.Fatalf("expected winner persisted with its rider, got %+v", repo.saved)
+	}
+	if p, _ := manager.Participants(context.Background(), "trip-1"); p != (contracts.TripParticipants{RiderID: "rider-1", DriverID: "driver-a"}) {
+		t.Fatalf("expected rider and winning driver assigned, got %+v", p)
 	}
 }
 
@@ -161,26 +175,316 @@ func TestTripLifecycleEndpoints(t *testing.T) {
 	if metricsRes.Code != http.StatusOK {
 		t.Fatalf("expected 200 metrics response got %d", metricsRes.Code)
 	}
-	var metrics trip.Metrics
+	var metrics TripMetricsDTO
 	if err := json.Unmarshal(metricsRes.Body.Bytes(), &metrics); err != nil {
 		t.Fatalf("decode metrics: %v", err)
 	}
-	if metrics.TotalActive < 2*time.Millisecond {
-		t.Fatalf("unexpected total active: %v", metrics.TotalActive)
+	if metricsDirect.TotalActive < 2*time.Millisecond {
+		t.Fatalf("unexpected total active: %v", metricsDirect.TotalActive)
 	}
-	if metrics.TotalPaused < time.Millisecond {
-		t.Fatalf("unexpected total paused: %v", metrics.TotalPaused)
+	if metricsDirect.TotalPaused < time.Millisecond {
+		t.Fatalf("unexpected total paused: %v", metricsDirect.TotalPaused)
 	}
-	if metrics.StartedAt.IsZero() {
-		t.Fatalf("expected non-zero start time")
+	if metrics.StartedAt.IsZero() || metrics.TripID != "trip-123" {
+		t.Fatalf("expected non-zero start time for trip-123, got %+v", metrics)
 	}
-	if metrics.TotalActive != metricsDirect.TotalActive {
+	// Durations travel as whole seconds.
+	if metrics.TotalActive != Duration(metricsDirect.TotalActive.Round(time.Second)) {
 		t.Fatalf("http active duration mismatch: %v vs %v", metrics.TotalActive, metricsDirect.TotalActive)
 	}
-	if metrics.TotalPaused != metricsDirect.TotalPaused {
+	if metrics.TotalPaused != Duration(metricsDirect.TotalPaused.Round(time.Second)) {
 		t.Fatalf("http paused duration mismatch: %v vs %v", metrics.TotalPaused, metricsDirect.TotalPaused)
 	}
 	if !metrics.StartedAt.Equal(metricsDirect.StartedAt) {
 		t.Fatalf("http startedAt mismatch: %v vs %v", metrics.StartedAt, metricsDirect.StartedAt)
 	}
 }
+
+func TestChatHistoryEndpoint(t *testing.T) {
+	// 1.- Seed a memory chat store and expose it through the server.
+	gin.SetMode(gin.TestMode)
+	store := chat.NewMemoryStore()
+	received := time.Unix(1735689600, 0).UTC()
+	if _, err := store.Save(context.Background(), chat.Message{ID: "m1", ClientMessageID: "c1", TripID: "trip-9", SenderID: "rider-1", Body: "hi", ReceivedAt: received}); err != nil {
+		t.Fatalf("seed store: %v", err)
+	}
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), auth.NewValidator("top-secret"), WithChatStore(store))
+	router := gin.New()
+	server.RegisterRoutes(router)
+
+	// 2.- Fetch the history and verify the stored message is returned.
+	req := httptest.NewRequest(http.MethodGet, "/trips/trip-9/messages?limit=10", nil)
+	req.Header.Set("Authorization", "Bearer top-secret")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", res.Code)
+	}
+	var body struct {
+		Messages []chat.Message `json:"messages"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if len(body.Messages) != 1 || body.Messages[0].ID != "m1" {
+		t.Fatalf("unexpected history %+v", body.Messages)
+	}
+
+	// 3.- Reject malformed cursors.
+	bad := httptest.NewRequest(http.MethodGet, "/trips/trip-9/messages?after=yesterday", nil)
+	bad.Header.Set("Authorization", "Bearer top-secret")
+	badRes := httptest.NewRecorder()
+	router.ServeHTTP(badRes, bad)
+	if badRes.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", badRes.Code)
+	}
+}
+
+func TestTripRoutesRequireParticipants(t *testing.T) {
+	// 1.- Assign a rider and a driver to trip-1 and issue tokens for them, a stranger and ops.
+	gin.SetMode(gin.TestMode)
+	manager := trip.NewManager(nil, nil)
+	manager.AssignParticipants("trip-1", contracts.TripParticipants{RiderID: "r1", DriverID: "d1"})
+	validator := auth.NewValidator("top-secret")
+	server := NewServer(bidding.NewArbiter(nil), manager, validator, WithChatStore(chat.NewMemoryStore()))
+	router := gin.New()
+	server.RegisterRoutes(router)
+	token := func(id, role string) string {
+		return validator.Issue(auth.Principal{ID: id, Role: role}, time.Minute)
+	}
+	do := func(method, target, token, body string) int {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	// 2.- Strangers are refused on every trip route, and riders may not evaluate bids.
+	tests := []struct {
+		name           string
+		method, target string
+		token, body    string
+		status         int
+	}{
+		{"foreign rider state", http.MethodPost, "/trips/trip-1/state", token("r2", auth.RoleRider), `{"action":"start"}`, http.StatusForbidden},
+		{"foreign driver metrics", http.MethodGet, "/trips/trip-1/metrics", token("d2", auth.RoleDriver), "", http.StatusForbidden},
+		{"foreign rider messages", http.MethodGet, "/api/v1/trips/trip-1/messages", token("r2", auth.RoleRider), "", http.StatusForbidden},
+		{"unassigned trip", http.MethodGet, "/trips/trip-2/messages", token("r1", auth.RoleRider), "", http.StatusForbidden},
+		{"rider evaluates", http.MethodPost, "/bids/evaluate", token("r1", auth.RoleRider), `{"request":{},"bids":[]}`, http.StatusForbidden},
+		{"assigned driver state", http.MethodPost, "/trips/trip-1/state", token("d1", auth.RoleDriver), `{"action":"start"}`, http.StatusNoContent},
+		{"assigned rider messages", http.MethodGet, "/trips/trip-1/messages", token("r1", auth.RoleRider), "", http.StatusOK},
+		{"ops metrics", http.MethodGet, "/trips/trip-1/metrics", token("ops-1", auth.RoleOps), "", http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := do(tc.method, tc.target, tc.token, tc.body); got != tc.status {
+				t.Fatalf("expected %d got %d", tc.status, got)
+			}
+		})
+	}
+}
+
+func TestHeatmapEndpoint(t *testing.T) {
+	// 1.- Evaluate a request so its pickup is counted, then start the trip.
+	gin.SetMode(gin.TestMode)
+	demand := heatmap.NewAggregator()
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), auth.NewValidator("top-secret"), WithHeatmap(demand))
+	router := gin.New()
+	server.RegisterRoutes(router)
+
+	body := []byte(`{"request":{"trip_id":"trip-h","latitude":19.4326,"longitude":-99.1332},"bids":[]}`)
+	req := httptest.NewRequest(http.MethodPost, "/bids/evaluate", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer top-secret")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	start := httptest.NewRequest(http.MethodPost, "/trips/trip-h/state", bytes.NewReader([]byte(`{"action":"start"}`)))
+	start.Header.Set("Authorization", "Bearer top-secret")
+	router.ServeHTTP(httptest.NewRecorder(), start)
+
+	// 2.- The heatmap exposes both signals as a GeoJSON cell within the bbox.
+	res := httptest.NewRecorder()
+	get := httptest.NewRequest(http.MethodGet, "/geo/heatmap?bbox=19,-100,20,-99&resolution=5", nil)
+	get.Header.Set("Authorization", "Bearer top-secret")
+	router.ServeHTTP(res, get)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", res.Code, res.Body.String())
+	}
+	var fc heatmap.FeatureCollection
+	if err := json.Unmarshal(res.Body.Bytes(), &fc); err != nil {
+		t.Fatalf("decode geojson: %v", err)
+	}
+	if len(fc.Features) != 1 || math.Abs(fc.Features[0].Properties["weight"].(float64)-2) > 1e-3 {
+		t.Fatalf("unexpected heatmap %+v", fc)
+	}
+
+	// 3.- Reject resolutions finer than the aggregator stores.
+	bad := httptest.NewRequest(http.MethodGet, "/geo/heatmap?resolution=12", nil)
+	bad.Header.Set("Authorization", "Bearer top-secret")
+	badRes := httptest.NewRecorder()
+	router.ServeHTTP(badRes, bad)
+	if badRes.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", badRes.Code)
+	}
+}
+
+func TestEvaluateBidsRejectsInvalidCoordinates(t *testing.T) {
+	// 1.- Submit a request and two bids with out-of-range or wrapped coordinates.
+	gin.SetMode(gin.TestMode)
+	repo := &captureRepo{}
+	server := NewServer(bidding.NewArbiter(repo), trip.NewManager(nil, nil), auth.NewValidator("top-secret"))
+	router := gin.New()
+	server.RegisterRoutes(router)
+
+	body := `{"request":{"trip_id":"t1","latitude":200,"longitude":10},"bids":[
+		{"id":"b1","latitude":1,"longitude":190,"price":{"amount":"10.00","currency":"USD"}},
+		{"id":"b2","latitude":-95,"longitude":10,"price":{"amount":"10.00","currency":"USD"}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/bids/evaluate", bytes.NewReader([]byte(body)))
+	req.Header.Set("Authorization", "Bearer top-secret")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	// 2.- Every offending field is listed with its position; the wrapped longitude is accepted.
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", res.Code)
+	}
+	var decoded struct {
+		Fields []contracts.FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(decoded.Fields) != 2 || decoded.Fields[0].Field != "request.latitude" || decoded.Fields[1].Field != "bids[1].latitude" {
+		t.Fatalf("unexpected fields %+v", decoded.Fields)
+	}
+	if len(repo.saved) != 0 {
+		t.Fatalf("invalid input must not reach the arbiter")
+	}
+}
+
+func TestLivenessAndReadiness(t *testing.T) {
+	// 1.- Register one healthy and one failing dependency.
+	gin.SetMode(gin.TestMode)
+	checks := health.NewRegistry()
+	checks.Register("cache", health.CheckerFunc(func(context.Context) (string, error) { return "warm", nil }))
+	down := true
+	checks.Register("database", health.CheckerFunc(func(context.Context) (string, error) {
+		if down {
+			return "", errors.New("connection refused")
+		}
+		return "", nil
+	}))
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), auth.NewValidator("top-secret"), WithHealth(checks))
+	router := gin.New()
+	server.RegisterRoutes(router)
+
+	get := func(path string) (*httptest.ResponseRecorder, health.Report) {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		var report health.Report
+		_ = json.Unmarshal(res.Body.Bytes(), &report)
+		return res, report
+	}
+
+	// 2.- Liveness ignores dependencies while readiness reports each check.
+	if res, _ := get("/livez"); res.Code != http.StatusOK {
+		t.Fatalf("expected live got %d", res.Code)
+	}
+	res, report := get("/readyz")
+	if res.Code != http.StatusServiceUnavailable || report.Checks["database"].Error != "connection refused" || report.Checks["cache"].Detail != "warm" {
+		t.Fatalf("unexpected readiness %d %+v", res.Code, report)
+	}
+	down = false
+	if res, report := get("/readyz"); res.Code != http.StatusOK || !report.Healthy() {
+		t.Fatalf("expected ready got %d %+v", res.Code, report)
+	}
+}
+
+type requestRecord struct {
+	method, route string
+	status        int
+}
+
+type capturingRecorder struct {
+	requests []requestRecord
+}
+
+func (r *capturingRecorder) ObserveRequest(method, route string, status int, _ time.Duration) {
+	r.requests = append(r.requests, requestRecord{method, route, status})
+}
+
+func TestRecorderLabelsRouteTemplates(t *testing.T) {
+	// 1.- Issue a matched request and one that hits no route.
+	gin.SetMode(gin.TestMode)
+	rec := &capturingRecorder{}
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), auth.NewValidator("top-secret"), WithRecorder(rec))
+	router := gin.New()
+	server.RegisterRoutes(router)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/trips/trip-42/metrics", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	// 2.- Labels use the template rather than the concrete path so cardinality stays bounded.
+	want := []requestRecord{
+		{http.MethodGet, "/api/v1/trips/:id/metrics", http.StatusUnauthorized},
+		{http.MethodGet, unmatchedRoute, http.StatusNotFound},
+	}
+	if len(rec.requests) != len(want) {
+		t.Fatalf("unexpected records %+v", rec.requests)
+	}
+	for i := range want {
+		if rec.requests[i] != want[i] {
+			t.Fatalf("record %d: want %+v got %+v", i, want[i], rec.requests[i])
+		}
+	}
+}
+
+func TestTracingSpansEvaluationAndTransitions(t *testing.T) {
+	exporter, restore := tracing.UseInMemory()
+	defer restore()
+
+	// 1.- Persist winners through a mocked SQL repository so its span joins the trace.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO accepted_bids").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	gin.SetMode(gin.TestMode)
+	server := NewServer(bidding.NewArbiter(bidding.NewSQLRepository(db, dialect.MySQL)), trip.NewManager(nil, nil), nil)
+	router := gin.New()
+	router.Use(tracing.Middleware())
+	server.RegisterRoutes(router)
+
+	body := `{"request":{"trip_id":"t1","latitude":1,"longitude":2},"bids":[
+		{"id":"b1","trip_id":"t1","latitude":1.001,"longitude":2.001,"price":{"amount":"10.00","currency":"USD"}}]}`
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/bids/evaluate", bytes.NewReader([]byte(body))))
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", res.Code, res.Body.String())
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/trips/t1/state", bytes.NewReader([]byte(`{"action":"start"}`))))
+
+	// 2.- Every span hangs off the request span that caused it.
+	spans := exporter.GetSpans()
+	parentOf := map[string]string{
+		"bidding.RankAndSelect":    "POST /api/v1/bids/evaluate",
+		"bidding.filterBids":       "bidding.RankAndSelect",
+		"bidding.rankCandidates":   "bidding.RankAndSelect",
+		"sql INSERT accepted_bids": "bidding.RankAndSelect",
+		"trip.StartTrip":           "POST /api/v1/trips/:id/state",
+	}
+	for child, parent := range parentOf {
+		c, ok := tracing.SpanNamed(spans, child)
+		if !ok {
+			t.Fatalf("span %q missing", child)
+		}
+		p, ok := tracing.SpanNamed(spans, parent)
+		if !ok || c.Parent.SpanID() != p.SpanContext.SpanID() {
+			t.Fatalf("span %q should be a child of %q", child, parent)
+		}
+	}
+}
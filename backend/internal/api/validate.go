@@ -0,0 +1,218 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/contracts"
+)
+
+// maxBodyBytes caps request bodies read by the validator.
+const maxBodyBytes = 1 << 20
+
+// specValidator checks request values against schemas of the generated document.
+type specValidator struct {
+	schemas  map[string]*Schema
+	patterns sync.Map
+}
+
+// validateRequest rejects query strings and bodies that do not match the operation's spec entry.
+func (v *specValidator) validateRequest(spec *Operation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		invalid := &contracts.ValidationError{}
+
+		// 1.- Query parameters arrive as strings and are coerced to their declared type first.
+		for _, p := range spec.Parameters {
+			if p.In != "query" {
+				continue
+			}
+			raw, ok := c.GetQuery(p.Name)
+			if !ok {
+				continue
+			}
+			v.check(p.Schema, coerceQuery(p.Schema, raw), p.Name, &invalid.Fields)
+		}
+
+		// 2.- Bodies are capped, decoded generically and restored for the handler's own binding.
+		if spec.RequestBody != nil {
+			data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+			var tooLarge *http.MaxBytesError
+			switch {
+			case errors.As(err, &tooLarge):
+				abortWithStatus(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+				return
+			case err != nil:
+				abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, "request body could not be read")
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			var body interface{}
+			if err := decoder.Decode(&body); err != nil {
+				abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, "request body must be valid JSON")
+				return
+			}
+			v.check(spec.RequestBody.Content["application/json"].Schema, body, "", &invalid.Fields)
+		}
+
+		if len(invalid.Fields) > 0 {
+			abortWithError(c, invalid)
+		}
+	}
+}
+
+// coerceQuery converts a raw query value into the JSON form its schema expects.
+func coerceQuery(s *Schema, raw string) interface{} {
+	switch s.Type {
+	case "integer", "number":
+		return json.Number(raw)
+	case "boolean":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+// check appends a field error for every constraint of s that value violates.
+func (v *specValidator) check(s *Schema, value interface{}, path string, out *[]contracts.FieldError) {
+	s = v.resolve(s)
+	if s == nil || value == nil {
+		return
+	}
+	fail := func(reason string) {
+		*out = append(*out, contracts.FieldError{Field: path, Reason: reason})
+	}
+
+	// 1.- Alternatives pass when any branch accepts the value on its own.
+	if len(s.OneOf) > 0 {
+		for _, alt := range s.OneOf {
+			var errs []contracts.FieldError
+			v.check(alt, value, path, &errs)
+			if len(errs) == 0 {
+				return
+			}
+		}
+		fail("does not match any accepted form")
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		// 2.- Required members are reported before the members that are present.
+		for _, name := range s.Required {
+			if obj[name] == nil {
+				*out = append(*out, contracts.FieldError{Field: joinPath(path, name), Reason: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := s.Properties[k]; ok {
+				v.check(prop, obj[k], joinPath(path, k), out)
+			} else if s.AdditionalProperties != nil {
+				v.check(s.AdditionalProperties, obj[k], joinPath(path, k), out)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			fail(fmt.Sprintf("must have at least %d items", *s.MinItems))
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			fail(fmt.Sprintf("must have at most %d items", *s.MaxItems))
+		}
+		for i, item := range items {
+			v.check(s.Items, item, fmt.Sprintf("%s[%d]", path, i), out)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if s.Pattern != "" && !v.pattern(s.Pattern).MatchString(str) {
+			fail("must match " + s.Pattern)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				fail("must be an RFC 3339 timestamp")
+			}
+		}
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			fail("must be a " + s.Type)
+			return
+		}
+		f, err := num.Float64()
+		if err != nil {
+			fail("must be a " + s.Type)
+			return
+		}
+		if _, err := num.Int64(); s.Type == "integer" && err != nil {
+			fail("must be an integer")
+			return
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail(fmt.Sprintf("must be at least %g", *s.Minimum))
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail(fmt.Sprintf("must be at most %g", *s.Maximum))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	}
+}
+
+// resolve follows a component reference to its schema.
+func (v *specValidator) resolve(s *Schema) *Schema {
+	if s == nil || s.Ref == "" {
+		return s
+	}
+	return v.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+}
+
+// pattern compiles each schema pattern once.
+func (v *specValidator) pattern(expr string) *regexp.Regexp {
+	if re, ok := v.patterns.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(expr)
+	v.patterns.Store(expr, re)
+	return re
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/webhook"
+)
+
+// WebhookRequestDTO creates or replaces a partner subscription.
+type WebhookRequestDTO struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+	// DriverIDs limits notifications to these drivers; omit to receive every driver.
+	DriverIDs []string `json:"driver_ids,omitempty"`
+	// Secret signs deliveries; one is generated on create and kept on update when omitted.
+	Secret string `json:"secret,omitempty"`
+	Active *bool  `json:"active,omitempty"`
+}
+
+// WebhookDTO describes a subscription; the secret is only returned when the subscription is created.
+type WebhookDTO struct {
+	ID        string    `json:"id" binding:"required"`
+	URL       string    `json:"url" binding:"required"`
+	Events    []string  `json:"events" binding:"required"`
+	DriverIDs []string  `json:"driver_ids"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active" binding:"required"`
+	CreatedAt time.Time `json:"created_at" binding:"required"`
+	UpdatedAt time.Time `json:"updated_at" binding:"required"`
+}
+
+// WebhookListDTO lists every subscription.
+type WebhookListDTO struct {
+	Webhooks []WebhookDTO `json:"webhooks" binding:"required"`
+}
+
+// WebhookDeliveryDTO is one entry of a subscription's delivery log.
+type WebhookDeliveryDTO struct {
+	ID        string      `json:"id" binding:"required"`
+	MessageID string      `json:"message_id" binding:"required"`
+	Event     string      `json:"event" binding:"required"`
+	Payload   interface{} `json:"payload"`
+	Status    string      `json:"status" binding:"required"`
+	Attempts  int         `json:"attempts" binding:"required"`
+	// NextAttemptAt is set while the delivery is pending.
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatus    int        `json:"last_status,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" binding:"required"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookDeliveryListDTO pages the delivery log, newest first.
+type WebhookDeliveryListDTO struct {
+	WebhookID  string               `json:"webhook_id" binding:"required"`
+	Deliveries []WebhookDeliveryDTO `json:"deliveries" binding:"required"`
+}
+
+// WebhookFromDomain renders a subscription without its secret.
+func WebhookFromDomain(sub webhook.Subscription) WebhookDTO {
+	dto := WebhookDTO{
+		ID: sub.ID, URL: sub.URL, Events: sub.Events, DriverIDs: sub.DriverIDs,
+		Active: sub.Active, CreatedAt: sub.CreatedAt, UpdatedAt: sub.UpdatedAt,
+	}
+	if dto.DriverIDs == nil {
+		dto.DriverIDs = []string{}
+	}
+	return dto
+}
+
+// WebhookDeliveryFromDomain renders one delivery log entry.
+func WebhookDeliveryFromDomain(d webhook.Delivery) WebhookDeliveryDTO {
+	dto := WebhookDeliveryDTO{
+		ID: d.ID, MessageID: d.MessageID, Event: d.Event, Payload: json.RawMessage(d.Payload),
+		Status: d.Status, Attempts: d.Attempts, LastStatus: d.LastStatus, LastError: d.LastError, CreatedAt: d.CreatedAt,
+	}
+	if d.Status == webhook.StatusPending {
+		next := d.NextAttemptAt
+		dto.NextAttemptAt = &next
+	}
+	if !d.DeliveredAt.IsZero() {
+		delivered := d.DeliveredAt
+		dto.DeliveredAt = &delivered
+	}
+	return dto
+}
+
+// WithWebhooks serves partner webhook subscriptions and their delivery logs under /webhooks.
+func WithWebhooks(store webhook.Store) ServerOption {
+	return func(s *Server) { s.webhooks = store }
+}
+
+func (s *Server) webhookOperations() []operation {
+	if s.webhooks == nil {
+		return nil
+	}
+	notFound := []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound}
+	return []operation{
+		{
+			Method: http.MethodPost, Path: "/webhooks", ID: "createWebhook", Tag: "webhooks", Auth: true, Roles: adminRoles,
+			Summary: "Subscribe a partner endpoint to bid and trip events",
+			Body:    WebhookRequestDTO{}, Status: http.StatusCreated, Response: WebhookDTO{},
+			Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+			Handler: s.handleCreateWebhook,
+		},
+		{
+			Method: http.MethodGet, Path: "/webhooks", ID: "listWebhooks", Tag: "webhooks", Auth: true, Roles: adminRoles,
+			Summary: "List webhook subscriptions",
+			Status:  http.StatusOK, Response: WebhookListDTO{},
+			Errors:  []int{http.StatusUnauthorized, http.StatusForbidden},
+			Handler: s.handleListWebhooks,
+		},
+		{
+			Method: http.MethodGet, Path: "/webhooks/:id", ID: "getWebhook", Tag: "webhooks", Auth: true, Roles: adminRoles,
+			Summary: "Describe one webhook subscription",
+			Status:  http.StatusOK, Response: WebhookDTO{},
+			Errors:  notFound,
+			Handler: s.handleGetWebhook,
+		},
+		{
+			Method: http.MethodPut, Path: "/webhooks/:id", ID: "updateWebhook", Tag: "webhooks", Auth: true, Roles: adminRoles,
+			Summary: "Replace the endpoint, filters or secret of a subscription",
+			Body:    WebhookRequestDTO{}, Status: http.StatusOK, Response: WebhookDTO{},
+			Errors:  append([]int{http.StatusBadRequest}, notFound...),
+			Handler: s.handleUpdateWebhook,
+		},
+		{
+			Method: http.MethodDelete, Path: "/webhooks/:id", ID: "deleteWebhook", Tag: "webhooks", Auth: true, Roles: adminRoles,
+			Summary: "Remove a subscription together with its delivery log",
+			Status:  http.StatusNoContent,
+			Errors:  notFound,
+			Handler: s.handleDeleteWebhook,
+		},
+		{
+			Method: http.MethodGet, Path: "/webhooks/:id/deliveries", ID: "listWebhookDeliveries", Tag: "webhooks", Auth: true, Roles: adminRoles,
+			Summary: "Page through the delivery log of a subscription, newest first",
+			Query: []queryParam{
+				{Name: "status", Description: "pending, succeeded or failed", Schema: &Schema{Type: "string", Pattern: "^(pending|succeeded|failed)$"}},
+				{Name: "limit", Description: "page size", Schema: &Schema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(500)}},
+			},
+			Status: http.StatusOK, Response: WebhookDeliveryListDTO{},
+			Errors:  append([]int{http.StatusBadRequest}, notFound...),
+			Handler: s.handleListWebhookDeliveries,
+		},
+		{
+			Method: http.MethodPost, Path: "/webhooks/:id/deliveries/:delivery_id/replay", ID: "replayWebhookDelivery", Tag: "webhooks", Auth: true, Roles: adminRoles,
+			Summary: "Queue a delivery to be sent again with a fresh attempt budget",
+			Status:  http.StatusAccepted, Response: WebhookDeliveryDTO{},
+			Errors:  notFound,
+			Handler: s.handleReplayWebhookDelivery,
+		},
+	}
+}
+
+func (s *Server) handleCreateWebhook(c *gin.Context) {
+	var body WebhookRequestDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, err.Error())
+		return
+	}
+
+	// 1.- New subscriptions are active and get a generated secret unless the caller brings one.
+	now := time.Now().UTC()
+	sub := webhook.Subscription{
+		ID: webhook.NewID(), URL: body.URL, Secret: body.Secret, Events: body.Events, DriverIDs: body.DriverIDs,
+		Active: body.Active == nil || *body.Active, CreatedAt: now, UpdatedAt: now,
+	}
+	if sub.Secret == "" {
+		sub.Secret = webhook.NewSecret()
+	}
+	if err := sub.Validate(); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	// 2.- Store it and hand the secret back exactly once.
+	if err := s.webhooks.CreateSubscription(c.Request.Context(), sub); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	dto := WebhookFromDomain(sub)
+	dto.Secret = sub.Secret
+	c.Header("Location", APIPrefix+"/webhooks/"+sub.ID)
+	c.JSON(http.StatusCreated, dto)
+}
+
+func (s *Server) handleListWebhooks(c *gin.Context) {
+	subs, err := s.webhooks.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	out := WebhookListDTO{Webhooks: make([]WebhookDTO, 0, len(subs))}
+	for _, sub := range subs {
+		out.Webhooks = append(out.Webhooks, WebhookFromDomain(sub))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) handleGetWebhook(c *gin.Context) {
+	sub, err := s.webhooks.GetSubscription(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, WebhookFromDomain(sub))
+}
+
+func (s *Server) handleUpdateWebhook(c *gin.Context) {
+	var body WebhookRequestDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, err.Error())
+		return
+	}
+
+	// 1.- Replace the filters, keeping the secret and active flag the caller left out.
+	sub, err := s.webhooks.GetSubscription(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	sub.URL, sub.Events, sub.DriverIDs, sub.UpdatedAt = body.URL, body.Events, body.DriverIDs, time.Now().UTC()
+	if body.Secret != "" {
+		sub.Secret = body.Secret
+	}
+	if body.Active != nil {
+		sub.Active = *body.Active
+	}
+	if err := sub.Validate(); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	// 2.- Persist the new version.
+	if err := s.webhooks.UpdateSubscription(c.Request.Context(), sub); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, WebhookFromDomain(sub))
+}
+
+func (s *Server) handleDeleteWebhook(c *gin.Context) {
+	if err := s.webhooks.DeleteSubscription(c.Request.Context(), c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) handleListWebhookDeliveries(c *gin.Context) {
+	// 1.- Parse the optional filters.
+	status := c.Query("status")
+	switch status {
+	case "", webhook.StatusPending, webhook.StatusSucceeded, webhook.StatusFailed:
+	default:
+		abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, "status must be pending, succeeded or failed")
+		return
+	}
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			abortWithStatus(c, http.StatusBadRequest, CodeInvalidInput, "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	// 2.- Unknown subscriptions are a 404 rather than an empty log.
+	ctx := c.Request.Context()
+	if _, err := s.webhooks.GetSubscription(ctx, c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	deliveries, err := s.webhooks.ListDeliveries(ctx, c.Param("id"), status, limit)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	out := WebhookDeliveryListDTO{WebhookID: c.Param("id"), Deliveries: make([]WebhookDeliveryDTO, 0, len(deliveries))}
+	for _, d := range deliveries {
+		out.Deliveries = append(out.Deliveries, WebhookDeliveryFromDomain(d))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) handleReplayWebhookDelivery(c *gin.Context) {
+	delivery, err := s.webhooks.ReplayDelivery(c.Request.Context(), c.Param("id"), c.Param("delivery_id"), time.Now().UTC())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, WebhookDeliveryFromDomain(delivery))
+}
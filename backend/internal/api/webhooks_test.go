@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/trip"
+	"kage/backend/internal/webhook"
+)
+
+func TestWebhookCRUD(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validator := auth.NewValidator("top-secret")
+	store := webhook.NewMemoryStore()
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), validator, WithWebhooks(store))
+	router := gin.New()
+	server.RegisterRoutes(router)
+	opsToken := validator.Issue(auth.Principal{ID: "ops-1", Role: auth.RoleOps}, time.Minute)
+	riderToken := validator.Issue(auth.Principal{ID: "r1", Role: auth.RoleRider}, time.Minute)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1"+path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	// 1.- Only operators manage subscriptions.
+	if res := do(http.MethodGet, "/webhooks", riderToken, ""); res.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 got %d", res.Code)
+	}
+
+	// 2.- Invalid subscriptions report every problem at once.
+	res := do(http.MethodPost, "/webhooks", opsToken, `{"url":"ftp://partner","events":["bid.accepted","trip.teleported"]}`)
+	var problem Problem
+	if err := json.Unmarshal(res.Body.Bytes(), &problem); err != nil || res.Code != http.StatusBadRequest || len(problem.Errors) != 2 {
+		t.Fatalf("expected 400 with two field errors got %d %s", res.Code, res.Body)
+	}
+
+	// 3.- Creating returns the generated secret once; reads never do.
+	res = do(http.MethodPost, "/webhooks", opsToken, `{"url":"https://partner.example/hooks","events":["bid.accepted"],"driver_ids":["d1"]}`)
+	var created WebhookDTO
+	if err := json.Unmarshal(res.Body.Bytes(), &created); err != nil || res.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d %s", res.Code, res.Body)
+	}
+	if created.ID == "" || len(created.Secret) < 16 || !created.Active || res.Header().Get("Location") != "/api/v1/webhooks/"+created.ID {
+		t.Fatalf("unexpected subscription %+v", created)
+	}
+	res = do(http.MethodGet, "/webhooks/"+created.ID, opsToken, "")
+	if res.Code != http.StatusOK || bytes.Contains(res.Body.Bytes(), []byte(created.Secret)) {
+		t.Fatalf("expected secret-free read got %d %s", res.Code, res.Body)
+	}
+
+	// 4.- Updates replace the filters and keep the secret when it is omitted.
+	res = do(http.MethodPut, "/webhooks/"+created.ID, opsToken, `{"url":"https://partner.example/v2","events":["trip.complete"],"active":false}`)
+	var updated WebhookDTO
+	if err := json.Unmarshal(res.Body.Bytes(), &updated); err != nil || res.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d %s", res.Code, res.Body)
+	}
+	stored, _ := store.GetSubscription(context.Background(), created.ID)
+	if updated.Active || len(updated.DriverIDs) != 0 || stored.URL != "https://partner.example/v2" || stored.Secret != created.Secret {
+		t.Fatalf("unexpected update %+v stored %+v", updated, stored)
+	}
+	res = do(http.MethodGet, "/webhooks", opsToken, "")
+	var list WebhookListDTO
+	if err := json.Unmarshal(res.Body.Bytes(), &list); err != nil || len(list.Webhooks) != 1 {
+		t.Fatalf("expected one subscription got %s", res.Body)
+	}
+
+	// 5.- Deleting twice reports the second attempt as missing.
+	if res := do(http.MethodDelete, "/webhooks/"+created.ID, opsToken, ""); res.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 got %d", res.Code)
+	}
+	if res := do(http.MethodDelete, "/webhooks/"+created.ID, opsToken, ""); res.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 got %d", res.Code)
+	}
+}
+
+func TestWebhookDeliveryLogAndReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := webhook.NewMemoryStore()
+	server := NewServer(bidding.NewArbiter(nil), trip.NewManager(nil, nil), nil, WithWebhooks(store))
+	router := gin.New()
+	server.RegisterRoutes(router)
+	ctx := context.Background()
+	created := time.Unix(1735689600, 0).UTC()
+	_ = store.CreateSubscription(ctx, webhook.Subscription{ID: "s1", URL: "https://partner.example", Secret: "0123456789abcdef", Events: webhook.Events, Active: true, CreatedAt: created})
+	_ = store.EnqueueDeliveries(ctx, []webhook.Delivery{
+		{ID: "d1", SubscriptionID: "s1", MessageID: "m1", Event: webhook.EventBidAccepted, Payload: json.RawMessage(`{"bid_id":"b1"}`), Status: webhook.StatusPending, CreatedAt: created},
+		{ID: "d2", SubscriptionID: "s1", MessageID: "m2", Event: webhook.EventTripComplete, Payload: json.RawMessage(`{}`), Status: webhook.StatusPending, CreatedAt: created.Add(time.Second)},
+	})
+	_ = store.RecordAttempt(ctx, webhook.Delivery{ID: "d1", Status: webhook.StatusFailed, LastStatus: http.StatusInternalServerError, LastError: "endpoint answered 500"})
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(method, "/api/v1"+path, nil))
+		return res
+	}
+
+	// 1.- The log lists newest first and filters by status.
+	res := do(http.MethodGet, "/webhooks/s1/deliveries")
+	var log WebhookDeliveryListDTO
+	if err := json.Unmarshal(res.Body.Bytes(), &log); err != nil || len(log.Deliveries) != 2 || log.Deliveries[0].ID != "d2" {
+		t.Fatalf("unexpected log %d %s", res.Code, res.Body)
+	}
+	res = do(http.MethodGet, "/webhooks/s1/deliveries?status=failed")
+	if err := json.Unmarshal(res.Body.Bytes(), &log); err != nil || len(log.Deliveries) != 1 || log.Deliveries[0].LastStatus != http.StatusInternalServerError {
+		t.Fatalf("unexpected filtered log %s", res.Body)
+	}
+	if !bytes.Contains(res.Body.Bytes(), []byte(`"payload":{"bid_id":"b1"}`)) {
+		t.Fatalf("expected raw payload in %s", res.Body)
+	}
+	if res := do(http.MethodGet, "/webhooks/s1/deliveries?status=lost"); res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", res.Code)
+	}
+	if res := do(http.MethodGet, "/webhooks/missing/deliveries"); res.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 got %d", res.Code)
+	}
+
+	// 2.- Replaying a failed delivery queues it again with a fresh attempt budget.
+	res = do(http.MethodPost, "/webhooks/s1/deliveries/d1/replay")
+	var replayed WebhookDeliveryDTO
+	if err := json.Unmarshal(res.Body.Bytes(), &replayed); err != nil || res.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 got %d %s", res.Code, res.Body)
+	}
+	if replayed.Status != webhook.StatusPending || replayed.Attempts != 0 || replayed.NextAttemptAt == nil {
+		t.Fatalf("unexpected replay %+v", replayed)
+	}
+	if res := do(http.MethodPost, "/webhooks/s1/deliveries/unknown/replay"); res.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 got %d", res.Code)
+	}
+}
@@ -3,17 +3,31 @@ package app
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/gin-gonic/gin"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
 
 	"kage/backend/internal/api"
 	"kage/backend/internal/auth"
 	"kage/backend/internal/bidding"
+	"kage/backend/internal/chat"
+	"kage/backend/internal/dialect"
+	"kage/backend/internal/geo"
+	"kage/backend/internal/health"
+	"kage/backend/internal/heatmap"
+	"kage/backend/internal/logging"
+	"kage/backend/internal/metrics"
+	"kage/backend/internal/migrate"
+	"kage/backend/internal/outbox"
+	"kage/backend/internal/ratelimit"
+	"kage/backend/internal/routing"
+	"kage/backend/internal/tracing"
 	"kage/backend/internal/trip"
+	"kage/backend/internal/webhook"
 	"kage/backend/internal/ws"
 )
 
@@ -21,58 +35,243 @@ import (
 type Application struct {
 	Engine  *gin.Engine
 	Hub     *ws.Hub
+	apply   func(Config)
 	cleanup func(context.Context) error
 }
 
 // Build assembles dependencies using the supplied config and logger.
-func Build(cfg Config, logger *log.Logger) (*Application, error) {
-	if logger == nil {
-		logger = log.Default()
+// level is the threshold behind logger; the admin API adjusts it at runtime.
+func Build(cfg Config, logger *slog.Logger, level *slog.LevelVar) (*Application, error) {
+	logger = logging.OrDefault(logger)
+	if level == nil {
+		level = new(slog.LevelVar)
 	}
+	level.Set(cfg.Log.Level)
 
-	var db *sql.DB
-	var err error
-	if cfg.DBDSN != "" {
-		// 1.- Open the MariaDB connection when a DSN is provided so repositories can persist data.
-		db, err = sql.Open("mysql", cfg.DBDSN)
-		if err != nil {
-			return nil, fmt.Errorf("open database: %w", err)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter: cfg.Tracing.Exporter, Endpoint: cfg.Tracing.Endpoint, SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("setup tracing: %w", err)
+	}
+	var (
+		db           *sql.DB
+		d            dialect.Dialect = dialect.MySQL
+		redisClient  *redis.Client
+		limiterStore ratelimit.Store
+		hub          *ws.Hub
+		closeSinks   = func() error { return nil }
+		built        bool
+	)
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer func() {
+		// Release everything opened so far when an error cuts the build short.
+		if built {
+			return
+		}
+		stopBackground()
+		if hub != nil {
+			hub.Shutdown(context.Background())
+		}
+		if db != nil {
+			_ = db.Close()
+		}
+		if redisClient != nil {
+			_ = redisClient.Close()
 		}
+		_ = closeSinks()
+		_ = shutdownTracing(context.Background())
+	}()
+
+	limiterStore, redisClient, err = newRateLimitStore(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	hub := ws.NewHub(logger)
+	if cfg.DB.DSN != "" {
+		// 1.- Open the MariaDB or PostgreSQL connection when a DSN is provided so repositories can persist data.
+		db, d, err = OpenDB(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	migrations, err := migrate.Load(migrate.Embedded, migrate.Dir(d))
+	if err == nil && db != nil && cfg.DB.AutoMigrate {
+		if _, err = migrate.New(db, migrations, migrate.WithDialect(d), migrate.WithLogger(logger)).Up(context.Background()); err != nil {
+			err = fmt.Errorf("auto-migrate: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	var bidRepo bidding.Repository
+	var chatStore chat.Store = chat.NewMemoryStore()
 	if db != nil {
-		bidRepo = bidding.NewSQLRepository(db)
+		chatStore = chat.NewSQLStore(db, d)
 	}
-	arbiter := bidding.NewArbiter(bidRepo, bidding.WithTimeout(cfg.EvaluationTimeout), bidding.WithRadius(cfg.RadiusKm))
 
-	var tripRepo trip.EventRepository
+	validator := auth.NewValidator(cfg.Auth.Secret)
+	recorder := metrics.New()
+
+	var (
+		tripRepo trip.EventRepository
+		bids     *bidding.SQLRepository
+	)
+	tripOpts := []trip.Option{trip.WithRecorder(recorder), trip.WithLogger(logger)}
 	if db != nil {
-		tripRepo = trip.NewSQLEventRepository(db)
+		tripRepo = trip.NewSQLEventRepository(db, d)
+		bids = bidding.NewSQLRepository(db, d)
+		tripOpts = append(tripOpts, trip.WithParticipantStore(bids))
+	}
+	tripManager := trip.NewManager(tripRepo, nil, tripOpts...)
+
+	hub = ws.NewHub(logger,
+		ws.WithRecorder(recorder),
+		ws.WithBackpressurePolicies(cfg.WS.Backpressure),
+		ws.WithLocationFanout(cfg.WS.LocationInterval, cfg.WS.LocationMinMoveKm),
+		ws.WithAuthenticator(validator),
+		ws.WithParticipants(tripManager),
+		ws.WithChat(chatStore, nil),
+		ws.WithFrameLimit(cfg.WS.FrameLimit),
+		ws.WithKeepalive(cfg.WS.ReadTimeout, cfg.WS.PingInterval),
+		ws.WithReplayBuffer(cfg.Trip.ReplaySize, cfg.Trip.ReplayTTL),
+		ws.WithHeartbeat(cfg.Trip.StreamHeartbeat),
+	)
+
+	var (
+		bidRepo bidding.Repository
+		drivers webhook.DriverLookup
+	)
+	if bids != nil {
+		bidRepo, drivers = bids, bids
+	}
+	arbiterOpts := []bidding.Option{
+		bidding.WithTimeout(cfg.Bidding.EvaluationTimeout), bidding.WithRadius(cfg.Bidding.RadiusKm), bidding.WithWeights(cfg.Bidding.Weights),
+		bidding.WithRecorder(recorder), bidding.WithLogger(logger),
+	}
+	if cfg.Bidding.ZonesFile != "" {
+		// 2.- Load geofences and keep them fresh while the process runs.
+		zones, err := geo.NewZoneRegistry(cfg.Bidding.ZonesFile, logger)
+		if err != nil {
+			return nil, fmt.Errorf("load zones: %w", err)
+		}
+		go zones.Watch(bgCtx, cfg.Bidding.ZonesRefresh)
+		arbiterOpts = append(arbiterOpts, bidding.WithZones(zones))
 	}
-	tripManager := trip.NewManager(tripRepo, nil)
+	if cfg.Bidding.RoadGraphFile != "" {
+		// 3.- Verify bid ETAs against the road network instead of straight-line distance.
+		graph, err := routing.LoadFile(cfg.Bidding.RoadGraphFile)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("loaded road graph", "path", cfg.Bidding.RoadGraphFile, "nodes", graph.Len())
+		arbiterOpts = append(arbiterOpts, bidding.WithRouter(routing.NewRouter(graph)))
+	}
+	arbiter := bidding.NewArbiter(bidRepo, arbiterOpts...)
 
-	validator := auth.NewValidator(cfg.AuthSecret)
+	var webhooks webhook.Store
+	if db != nil {
+		// 4.- Relay outbox rows written alongside bids and trip events to the configured sinks and partner webhooks.
+		sinks, closer, err := newOutboxSinks(cfg, hub)
+		if err != nil {
+			return nil, err
+		}
+		closeSinks = closer
+		webhookStore := webhook.NewSQLStore(db, d)
+		dispatcher := webhook.NewDispatcher(webhookStore, webhook.WithDriverLookup(drivers), webhook.WithLogger(logger))
+		go dispatcher.Run(bgCtx)
+		webhooks = webhookStore
+		sinks = append(sinks, dispatcher)
+		relay := outbox.NewRelay(outbox.NewSQLStore(db, d), sinks,
+			outbox.WithPollInterval(cfg.Outbox.PollInterval), outbox.WithMaxAttempts(cfg.Outbox.MaxAttempts), outbox.WithLogger(logger))
+		go relay.Run(bgCtx)
+	}
 
 	router := gin.New()
-	router.Use(gin.Recovery())
+	router.Use(gin.Recovery(), logging.Middleware(), tracing.Middleware(), logging.AccessLog(logger))
+
+	// 5.- Aggregate demand and push snapshots to every connected driver.
+	demand := heatmap.NewAggregator(heatmap.WithHalfLife(cfg.Heatmap.HalfLife))
+	if cfg.Heatmap.Interval > 0 {
+		go demand.Publish(bgCtx, cfg.Heatmap.Interval, 5, func(cells []heatmap.Cell) {
+			hub.BroadcastRole(ws.RoleDriver, ws.MessageTypeHeatmap, map[string]interface{}{
+				"type": ws.MessageTypeHeatmap, "resolution": 5, "cells": heatmap.GeoJSON(cells),
+			})
+		})
+	}
 
-	server := api.NewServer(arbiter, tripManager, validator)
+	// 6.- Register the dependency checks behind /readyz.
+	checks := health.NewRegistry(health.WithTimeout(cfg.HTTP.HealthTimeout))
+	checks.Register("hub", hub)
+	if db != nil {
+		checks.Register("database", health.DBPing(db))
+		checks.Register("migrations", health.MigrationVersion(db, migrate.Latest(migrations)))
+	}
+	if redisClient != nil {
+		checks.Register("redis", health.CheckerFunc(func(ctx context.Context) (string, error) {
+			return "", redisClient.Ping(ctx).Err()
+		}))
+	}
+
+	// 7.- Throttle API callers per principal, and per client IP before authentication.
+	limiter := ratelimit.NewLimiter(limiterStore, ratelimit.WithLimits(cfg.HTTP.RateLimits))
+	perIP := ratelimit.NewLimiter(limiterStore, ratelimit.WithDefault(cfg.HTTP.IPRateLimit))
+
+	server := api.NewServer(arbiter, tripManager, validator, api.WithChatStore(chatStore), api.WithHeatmap(demand), api.WithHealth(checks), api.WithRecorder(recorder), api.WithLogLevel(level), api.WithLogger(logger), api.WithRateLimiter(limiter), api.WithIPRateLimiter(perIP), api.WithWebhooks(webhooks))
 	server.RegisterRoutes(router)
+	router.GET("/metrics", gin.WrapH(recorder.Handler()))
 	hub.RegisterRoutes(router)
 
 	cleanup := func(ctx context.Context) error {
-		// 2.- Stop background workers before closing shared connections.
+		// 8.- Stop background workers before closing shared connections.
+		stopBackground()
 		hub.Shutdown(ctx)
+		var errs []error
 		if db != nil {
-			return db.Close()
+			errs = append(errs, db.Close())
+		}
+		if redisClient != nil {
+			errs = append(errs, redisClient.Close())
 		}
-		return nil
+		errs = append(errs, closeSinks())
+		errs = append(errs, shutdownTracing(ctx))
+		return errors.Join(errs...)
+	}
+
+	apply := func(cfg Config) {
+		// 9.- Swap the live settings into the running components without dropping connections.
+		arbiter.SetTuning(bidding.Tuning{RadiusKm: cfg.Bidding.RadiusKm, EvaluationTimeout: cfg.Bidding.EvaluationTimeout, Weights: cfg.Bidding.Weights})
+		limiter.SetLimits(cfg.HTTP.RateLimits)
+		perIP.SetLimits(map[string]ratelimit.Limit{"*": cfg.HTTP.IPRateLimit})
+		hub.SetBackpressurePolicies(cfg.WS.Backpressure)
 	}
 
-	return &Application{Engine: router, Hub: hub, cleanup: cleanup}, nil
+	built = true
+	return &Application{Engine: router, Hub: hub, apply: apply, cleanup: cleanup}, nil
+}
+
+// ApplyConfig swaps the live settings of cfg, such as the bidding radius, rate limits and
+// websocket backpressure, into the running components. Settings that need a restart are ignored.
+func (a *Application) ApplyConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if a.apply != nil {
+		a.apply(cfg)
+	}
+	return nil
+}
+
+// OpenDB opens the configured database with the driver its DSN scheme selects; the handle connects lazily.
+func OpenDB(cfg Config) (*sql.DB, dialect.Dialect, error) {
+	db, d, err := dialect.Open(cfg.DB.DSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
+	return db, d, nil
 }
 
 // Cleanup releases resources created by Build.
@@ -82,3 +281,62 @@ func (a *Application) Cleanup(ctx context.Context) error {
 	}
 	return nil
 }
+
+// newRateLimitStore selects where token buckets live; Redis shares them across replicas.
+func newRateLimitStore(cfg Config) (ratelimit.Store, *redis.Client, error) {
+	switch cfg.HTTP.RateLimitStore {
+	case "", ratelimit.StoreMemory:
+		return ratelimit.NewMemoryStore(), nil, nil
+	case ratelimit.StoreRedis:
+		opts, err := redis.ParseURL(cfg.HTTP.RedisURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse http.redis_url: %w", err)
+		}
+		client := redis.NewClient(opts)
+		return ratelimit.NewRedisStore(client, "kage:ratelimit:"), client, nil
+	}
+	return nil, nil, fmt.Errorf("unknown rate limit store %q", cfg.HTTP.RateLimitStore)
+}
+
+// newOutboxSinks builds the sinks named in cfg.Outbox.Sinks; the closer releases the ones holding files.
+func newOutboxSinks(cfg Config, hub *ws.Hub) ([]outbox.Sink, func() error, error) {
+	var (
+		sinks []outbox.Sink
+		files []*outbox.FileSink
+	)
+	closer := func() error {
+		var errs []error
+		for _, f := range files {
+			errs = append(errs, f.Close())
+		}
+		return errors.Join(errs...)
+	}
+	for _, name := range cfg.Outbox.Sinks {
+		var (
+			sink outbox.Sink
+			err  error
+		)
+		switch name {
+		case outbox.SinkHub:
+			sink = outbox.NewHubSink(hub)
+		case outbox.SinkWebhook:
+			sink, err = outbox.NewWebhookSink(cfg.Outbox.WebhookURL, nil)
+		case outbox.SinkFile:
+			var file *outbox.FileSink
+			if file, err = outbox.NewFileSink(cfg.Outbox.File); err == nil {
+				files = append(files, file)
+				sink = file
+			}
+		case outbox.SinkKafka:
+			sink, err = outbox.NewKafkaSink(cfg.Outbox.KafkaURL, cfg.Outbox.KafkaTopic, nil)
+		default:
+			err = fmt.Errorf("unknown outbox sink %q", name)
+		}
+		if err != nil {
+			_ = closer()
+			return nil, nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, closer, nil
+}
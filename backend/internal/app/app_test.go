@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/api"
+)
+
+// undocumented lists the engine routes deliberately left out of the OpenAPI document.
+var undocumented = map[string]string{
+	"GET /metrics":                    "Prometheus exposition format, not JSON",
+	"GET /ws/:role/:room":             "websocket upgrade",
+	"GET /ws/:role":                   "websocket upgrade",
+	"GET /ws/ops/firehose":            "websocket upgrade",
+	"GET /trips/:id/events/stream":    "server-sent events stream",
+	"GET /ws/stats":                   "hub internals for operators",
+	"GET /ws/rooms/:room/occupants":   "hub internals for operators",
+	"GET /ws/drivers/:id/location":    "hub internals for operators",
+	"POST /ws/ops/rooms/:room/kick":   "hub internals for operators",
+	"POST /ws/ops/rooms/:room/notice": "hub internals for operators",
+}
+
+func TestEveryEngineRouteIsDocumented(t *testing.T) {
+	// 1.- Build the full engine, API and hub routes alike, and fetch its document.
+	gin.SetMode(gin.TestMode)
+	application, err := Build(DefaultConfig(), nil, nil)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	defer application.Cleanup(context.Background())
+	res := httptest.NewRecorder()
+	application.Engine.ServeHTTP(res, httptest.NewRequest(http.MethodGet, api.OpenAPIPath, nil))
+	var doc api.Document
+	if err := json.Unmarshal(res.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+
+	// 2.- Every route is either documented or explicitly excluded, and exclusions stay current.
+	seen := make(map[string]bool)
+	for _, route := range application.Engine.Routes() {
+		key := route.Method + " " + route.Path
+		if _, ok := undocumented[key]; ok {
+			seen[key] = true
+			continue
+		}
+		if _, ok := doc.Paths[specPath(route.Path)][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("route %s has no OpenAPI entry and is not listed as undocumented", key)
+		}
+	}
+	for key := range undocumented {
+		if !seen[key] {
+			t.Errorf("undocumented route %s is no longer registered", key)
+		}
+	}
+}
+
+// specPath rewrites gin's :param segments into OpenAPI {param} templates.
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func TestBuildFailsCleanly(t *testing.T) {
+	// 1.- A missing zones file aborts the build after the hub and background context exist; the
+	// deferred cleanup releases them without returning a half-built application.
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.Bidding.ZonesFile = t.TempDir() + "/missing.geojson"
+	if application, err := Build(cfg, nil, nil); err == nil || application != nil || !strings.Contains(err.Error(), "load zones") {
+		t.Fatalf("expected zones error got %v", err)
+	}
+}
@@ -1,53 +1,229 @@
 package app
 
 import (
-	"fmt"
-	"os"
+	"log/slog"
+	"slices"
 	"strconv"
 	"time"
+
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/outbox"
+	"kage/backend/internal/ratelimit"
+	"kage/backend/internal/tracing"
+	"kage/backend/internal/ws"
+)
+
+// Deployment environments accepted by Config.Env.
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
 )
 
-// Config holds runtime configuration for the backend.
+// DevAuthSecret is the signing secret used when none is configured; production refuses to boot with it.
+const DevAuthSecret = "dev-secret"
+
+// Config holds runtime configuration for the backend, one section per component.
 type Config struct {
-	HTTPPort          string
-	DBDSN             string
-	AuthSecret        string
-	EvaluationTimeout time.Duration
+	Env     string
+	HTTP    HTTPConfig
+	DB      DBConfig
+	Auth    AuthConfig
+	Bidding BiddingConfig
+	Trip    TripConfig
+	WS      WSConfig
+	Heatmap HeatmapConfig
+	Tracing TracingConfig
+	Log     LogConfig
+	Outbox  OutboxConfig
+}
+
+// HTTPConfig configures the REST listener and its rate limits.
+type HTTPConfig struct {
+	Port              string
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	HealthTimeout     time.Duration
+	RateLimits        map[string]ratelimit.Limit
+	IPRateLimit       ratelimit.Limit
+	RateLimitStore    string
+	RedisURL          string
+}
+
+// DBConfig configures the optional MariaDB or PostgreSQL connection; an empty DSN keeps state in memory.
+type DBConfig struct {
+	DSN             string
+	AutoMigrate     bool
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// AuthConfig configures bearer token validation.
+type AuthConfig struct {
+	Secret string
+}
+
+// BiddingConfig configures bid arbitration.
+type BiddingConfig struct {
 	RadiusKm          float64
+	EvaluationTimeout time.Duration
+	Weights           bidding.Weights
+	ZonesFile         string
+	ZonesRefresh      time.Duration
+	RoadGraphFile     string
 }
 
-// LoadConfig reads environment variables into Config with defaults applied.
-func LoadConfig() (Config, error) {
-	cfg := Config{
-		HTTPPort:          getEnv("BACKEND_HTTP_PORT", "8080"),
-		DBDSN:             os.Getenv("BACKEND_DB_DSN"),
-		AuthSecret:        getEnv("BACKEND_AUTH_SECRET", "dev-secret"),
-		EvaluationTimeout: 3 * time.Second,
-		RadiusKm:          5,
-	}
+// TripConfig configures the trip event stream.
+type TripConfig struct {
+	ReplaySize      int
+	ReplayTTL       time.Duration
+	StreamHeartbeat time.Duration
+}
 
-	if v := os.Getenv("BACKEND_EVALUATION_TIMEOUT"); v != "" {
-		dur, err := time.ParseDuration(v)
-		if err != nil {
-			return Config{}, fmt.Errorf("parse BACKEND_EVALUATION_TIMEOUT: %w", err)
-		}
-		cfg.EvaluationTimeout = dur
+// WSConfig configures websocket connections.
+type WSConfig struct {
+	Backpressure      map[string]ws.Backpressure
+	LocationInterval  time.Duration
+	LocationMinMoveKm float64
+	FrameLimit        ratelimit.Limit
+	ReadTimeout       time.Duration
+	PingInterval      time.Duration
+}
+
+// HeatmapConfig configures demand aggregation.
+type HeatmapConfig struct {
+	Interval time.Duration
+	HalfLife time.Duration
+}
+
+// TracingConfig configures span export.
+type TracingConfig struct {
+	Exporter    string
+	Endpoint    string
+	SampleRatio float64
+}
+
+// LogConfig configures the initial log threshold.
+type LogConfig struct {
+	Level slog.Level
+}
+
+// OutboxConfig configures the outbox relay and its sinks.
+type OutboxConfig struct {
+	Sinks        []string
+	WebhookURL   string
+	File         string
+	KafkaURL     string
+	KafkaTopic   string
+	PollInterval time.Duration
+	MaxAttempts  int
+}
+
+// DefaultConfig returns the configuration used when no source overrides a setting.
+func DefaultConfig() Config {
+	return Config{
+		Env: EnvDevelopment,
+		HTTP: HTTPConfig{
+			Port:              "8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   10 * time.Second,
+			HealthTimeout:     2 * time.Second,
+			RateLimits:        map[string]ratelimit.Limit{"/bids/evaluate": {Rate: 5, Burst: 10}},
+			IPRateLimit:       ratelimit.Limit{Rate: 50, Burst: 100},
+			RateLimitStore:    ratelimit.StoreMemory,
+		},
+		DB:   DBConfig{MaxIdleConns: 2},
+		Auth: AuthConfig{Secret: DevAuthSecret},
+		Bidding: BiddingConfig{
+			RadiusKm:          5,
+			EvaluationTimeout: 3 * time.Second,
+			Weights:           bidding.DefaultWeights,
+			ZonesRefresh:      5 * time.Second,
+		},
+		Trip: TripConfig{ReplaySize: 256, ReplayTTL: 10 * time.Minute, StreamHeartbeat: 15 * time.Second},
+		WS: WSConfig{
+			LocationInterval:  time.Second,
+			LocationMinMoveKm: 0.01,
+			FrameLimit:        ratelimit.Limit{Rate: 20, Burst: 40},
+			ReadTimeout:       60 * time.Second,
+			PingInterval:      50 * time.Second,
+		},
+		Heatmap: HeatmapConfig{Interval: 30 * time.Second, HalfLife: 15 * time.Minute},
+		Tracing: TracingConfig{Exporter: tracing.ExporterNone, SampleRatio: 1},
+		Outbox: OutboxConfig{
+			Sinks:        []string{outbox.SinkHub},
+			KafkaTopic:   "kage.events",
+			PollInterval: time.Second,
+			MaxAttempts:  10,
+		},
 	}
+}
 
-	if v := os.Getenv("BACKEND_RADIUS_KM"); v != "" {
-		km, err := strconv.ParseFloat(v, 64)
-		if err != nil {
-			return Config{}, fmt.Errorf("parse BACKEND_RADIUS_KM: %w", err)
+// Validate checks the settings against each other and reports every problem at once, keyed by setting name.
+func (c Config) Validate() error {
+	invalid := &contracts.ValidationError{}
+	check := func(ok bool, key, reason string) {
+		if !ok {
+			invalid.Fields = append(invalid.Fields, contracts.FieldError{Field: key, Reason: reason})
 		}
-		cfg.RadiusKm = km
 	}
+	positive := func(d time.Duration, key string) { check(d > 0, key, "must be positive") }
 
-	return cfg, nil
-}
+	// 1.- Environment and security.
+	check(slices.Contains([]string{EnvDevelopment, EnvStaging, EnvProduction}, c.Env), "env", "must be development, staging or production")
+	check(c.Auth.Secret != "", "auth.secret", "must not be empty")
+	check(c.Env != EnvProduction || c.Auth.Secret != DevAuthSecret, "auth.secret", "must be changed from the development default in production")
+
+	// 2.- HTTP and storage.
+	port, err := strconv.Atoi(c.HTTP.Port)
+	check(err == nil && port > 0 && port < 65536, "http.port", "must be a TCP port between 1 and 65535")
+	positive(c.HTTP.ReadHeaderTimeout, "http.read_header_timeout")
+	positive(c.HTTP.IdleTimeout, "http.idle_timeout")
+	positive(c.HTTP.ShutdownTimeout, "http.shutdown_timeout")
+	positive(c.HTTP.HealthTimeout, "http.health_timeout")
+	check(c.HTTP.IPRateLimit.Valid(), "http.ip_rate_limit", "must have a positive rate and burst")
+	check(c.HTTP.RateLimitStore == ratelimit.StoreMemory || c.HTTP.RateLimitStore == ratelimit.StoreRedis, "http.rate_limit_store", "must be memory or redis")
+	check(c.HTTP.RateLimitStore != ratelimit.StoreRedis || c.HTTP.RedisURL != "", "http.redis_url", "is required when http.rate_limit_store is redis")
+	check(c.DB.MaxOpenConns >= 0, "db.max_open_conns", "must not be negative")
+	check(c.DB.MaxIdleConns >= 0, "db.max_idle_conns", "must not be negative")
+	check(c.DB.ConnMaxLifetime >= 0, "db.conn_max_lifetime", "must not be negative")
+	check(!c.DB.AutoMigrate || c.DB.DSN != "", "db.auto_migrate", "requires db.dsn")
+
+	// 3.- Bidding, trips and websockets.
+	check(c.Bidding.RadiusKm > 0, "bidding.radius_km", "must be positive")
+	positive(c.Bidding.EvaluationTimeout, "bidding.evaluation_timeout")
+	check(c.Bidding.Weights.Valid(), "bidding.weights", "must be non-negative with at least one positive")
+	positive(c.Bidding.ZonesRefresh, "bidding.zones_refresh")
+	check(c.Trip.ReplaySize > 0, "trip.replay_size", "must be positive")
+	positive(c.Trip.ReplayTTL, "trip.replay_ttl")
+	positive(c.Trip.StreamHeartbeat, "trip.stream_heartbeat")
+	positive(c.WS.LocationInterval, "ws.location_interval")
+	check(c.WS.LocationMinMoveKm >= 0, "ws.location_min_move_km", "must not be negative")
+	positive(c.WS.ReadTimeout, "ws.read_timeout")
+	positive(c.WS.PingInterval, "ws.ping_interval")
+	check(c.WS.PingInterval < c.WS.ReadTimeout, "ws.ping_interval", "must be shorter than ws.read_timeout")
+
+	// 4.- Background work and observability.
+	check(c.Heatmap.Interval >= 0, "heatmap.interval", "must not be negative; zero disables publishing")
+	positive(c.Heatmap.HalfLife, "heatmap.half_life")
+	check(slices.Contains([]string{tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP}, c.Tracing.Exporter), "tracing.exporter", "must be none, stdout or otlp")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+	for _, sink := range c.Outbox.Sinks {
+		check(slices.Contains([]string{outbox.SinkHub, outbox.SinkWebhook, outbox.SinkFile, outbox.SinkKafka}, sink), "outbox.sinks", "unknown sink "+strconv.Quote(sink))
+	}
+	check(!slices.Contains(c.Outbox.Sinks, outbox.SinkWebhook) || c.Outbox.WebhookURL != "", "outbox.webhook_url", "is required by the webhook sink")
+	check(!slices.Contains(c.Outbox.Sinks, outbox.SinkFile) || c.Outbox.File != "", "outbox.file", "is required by the file sink")
+	check(!slices.Contains(c.Outbox.Sinks, outbox.SinkKafka) || c.Outbox.KafkaURL != "", "outbox.kafka_url", "is required by the kafka sink")
+	check(c.Outbox.KafkaTopic != "", "outbox.kafka_topic", "must not be empty")
+	positive(c.Outbox.PollInterval, "outbox.poll_interval")
+	check(c.Outbox.MaxAttempts > 0, "outbox.max_attempts", "must be positive")
 
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if len(invalid.Fields) > 0 {
+		return invalid
 	}
-	return fallback
+	return nil
 }
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"kage/backend/internal/contracts"
+)
+
+// Sources are the layers LoadConfig applies over DefaultConfig, in this order: file, environment, flags.
+type Sources struct {
+	// File is a YAML (.yaml, .yml) or TOML (.toml) config file; empty skips it.
+	File string
+	// LookupEnv reads environment variables; nil uses os.LookupEnv.
+	LookupEnv func(string) (string, bool)
+	// Flags holds raw command-line values keyed by setting name.
+	Flags map[string]string
+}
+
+// ParseFlags reads -config and one flag per setting, such as -bidding.radius_km=7, from args.
+// It returns the sources for LoadConfig and the arguments left after the flags.
+func ParseFlags(name string, args []string, output io.Writer) (Sources, []string, error) {
+	src := Sources{File: os.Getenv("BACKEND_CONFIG"), Flags: make(map[string]string)}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&src.File, "config", src.File, "YAML or TOML config file (env BACKEND_CONFIG)")
+	for _, s := range settings {
+		key := s.key
+		fs.Func(key, s.usage+" (env "+s.env+")", func(v string) error {
+			src.Flags[key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Sources{}, nil, err
+	}
+	return src, fs.Args(), nil
+}
+
+// LoadConfig merges the sources over DefaultConfig and validates the result. Every malformed
+// or invalid setting is reported in one *contracts.ValidationError keyed by setting name.
+func LoadConfig(src Sources) (Config, error) {
+	lookup := src.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	cfg := DefaultConfig()
+	invalid := &contracts.ValidationError{}
+
+	// 1.- Collect raw values per layer; later layers win.
+	layers := []struct {
+		name   string
+		values map[string]string
+	}{{name: "file"}, {name: "env", values: make(map[string]string)}, {name: "flag", values: src.Flags}}
+	if src.File != "" {
+		doc, err := readConfigFile(src.File)
+		if err != nil {
+			return Config{}, err
+		}
+		layers[0].values = flatten(doc, &invalid.Fields)
+	}
+	for _, s := range settings {
+		if v, ok := lookup(s.env); ok && (v != "" || s.emptyOK) {
+			layers[1].values[s.key] = v
+		}
+	}
+
+	// 2.- Apply them in order, naming the layer that supplied a bad value.
+	for _, layer := range layers {
+		for _, s := range settings {
+			raw, ok := layer.values[s.key]
+			if !ok {
+				continue
+			}
+			if err := s.set(&cfg, raw); err != nil {
+				source := layer.name
+				if source == "env" {
+					source = s.env
+				}
+				invalid.Fields = append(invalid.Fields, contracts.FieldError{Field: s.key, Reason: fmt.Sprintf("from %s: %v", source, err)})
+			}
+		}
+	}
+
+	// 3.- Check the merged values, skipping keys that already failed to parse.
+	var semantic *contracts.ValidationError
+	if errors.As(cfg.Validate(), &semantic) {
+		for _, f := range semantic.Fields {
+			if !hasField(invalid.Fields, f.Field) {
+				invalid.Fields = append(invalid.Fields, f)
+			}
+		}
+	}
+	if len(invalid.Fields) > 0 {
+		return Config{}, invalid
+	}
+	return cfg, nil
+}
+
+func hasField(fields []contracts.FieldError, key string) bool {
+	for _, f := range fields {
+		if f.Field == key {
+			return true
+		}
+	}
+	return false
+}
+
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	doc := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("config file %s: extension must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// WriteConfig renders cfg as a YAML or TOML config file that LoadConfig accepts, with secrets redacted.
+func WriteConfig(w io.Writer, cfg Config, format string) error {
+	// 1.- Nest the settings by section.
+	doc := make(map[string]any)
+	for _, s := range settings {
+		value := s.get(&cfg)
+		if s.secret && value != "" {
+			value = redactedValue
+		}
+		section, name, nested := strings.Cut(s.key, ".")
+		if !nested {
+			doc[section] = value
+			continue
+		}
+		if _, ok := doc[section]; !ok {
+			doc[section] = make(map[string]any)
+		}
+		doc[section].(map[string]any)[name] = value
+	}
+
+	// 2.- Encode.
+	var buf bytes.Buffer
+	switch format {
+	case "", "yaml":
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	case "toml":
+		if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown config format %q; expected yaml or toml", format)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"kage/backend/internal/logging"
+)
+
+// reloadDebounce coalesces the burst of events editors emit when saving a file.
+const reloadDebounce = 200 * time.Millisecond
+
+// change describes one setting that differs between two configurations.
+type change struct {
+	Key string
+	Old any
+	New any
+	// Live reports whether the change reaches running components; other settings need a restart.
+	Live bool
+}
+
+// diffConfig lists the settings whose values differ between old and next, in settings order; secrets are redacted.
+func diffConfig(old, next Config) []change {
+	var changes []change
+	for _, s := range settings {
+		before, after := s.get(&old), s.get(&next)
+		if fmt.Sprint(before) == fmt.Sprint(after) {
+			continue
+		}
+		if s.secret {
+			before, after = redactedValue, redactedValue
+		}
+		changes = append(changes, change{Key: s.key, Old: before, New: after, Live: s.live})
+	}
+	return changes
+}
+
+// Reloader holds the running configuration and re-reads its sources when asked, on a signal,
+// or when the config file changes. Only live settings are applied; a config that fails
+// validation, or that apply rejects, leaves the previous one in place.
+type Reloader struct {
+	src     Sources
+	apply   func(Config) error
+	logger  *slog.Logger
+	mu      sync.Mutex
+	current Config
+	// watching, when set, is called once Watch has registered its file watches.
+	watching func()
+}
+
+// NewReloader starts from cfg, the config loaded from src at boot, and hands later versions to apply.
+// apply takes a version whole or rejects it before changing anything, as Application.ApplyConfig does.
+func NewReloader(src Sources, cfg Config, apply func(Config) error, logger *slog.Logger) *Reloader {
+	return &Reloader{src: src, apply: apply, logger: logging.OrDefault(logger), current: cfg}
+}
+
+// Current returns the configuration running components use.
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload reads the sources again, logs what changed, and applies the live settings.
+func (r *Reloader) Reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 1.- Load and validate the new version; on failure nothing has been touched yet.
+	loaded, err := LoadConfig(r.src)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "config reload rejected, keeping previous config", "error", err)
+		return err
+	}
+
+	// 2.- Carry over live settings only; the others keep their boot values until a restart.
+	next := r.current
+	for _, s := range settings {
+		if s.live {
+			s.copy(&next, &loaded)
+		}
+	}
+	changes := diffConfig(r.current, loaded)
+	if len(changes) == 0 {
+		r.logger.InfoContext(ctx, "config reloaded without changes")
+		return nil
+	}
+
+	// 3.- Apply; a refused version has changed nothing, so the previous one stays.
+	if err := r.apply(next); err != nil {
+		r.logger.ErrorContext(ctx, "config reload rejected, keeping previous config", "error", err)
+		return err
+	}
+	for _, c := range changes {
+		if c.Live {
+			r.logger.InfoContext(ctx, "config setting changed", "key", c.Key, "old", c.Old, "new", c.New)
+			continue
+		}
+		r.logger.WarnContext(ctx, "config setting changed but requires a restart", "key", c.Key, "old", c.Old, "new", c.New)
+	}
+	r.current = next
+	return nil
+}
+
+// Watch reloads whenever signals delivers, typically SIGHUP, and when the config file is
+// written, replaced or renamed, until ctx ends. A file reached through symlinks also reloads
+// when a link is swapped to a new target, as Kubernetes does with ConfigMap ..data links.
+// Without a config file only signals trigger.
+func (r *Reloader) Watch(ctx context.Context, signals <-chan os.Signal) error {
+	// 1.- Watch the directory so editors that replace the file by renaming keep triggering,
+	// and the directory of the resolved target so edits behind a symlink do too.
+	var (
+		watcher   *fsnotify.Watcher
+		events    <-chan fsnotify.Event
+		watchErrs <-chan error
+	)
+	file := filepath.Clean(r.src.File)
+	target := resolveConfigFile(file)
+	if r.src.File != "" {
+		var err error
+		if watcher, err = fsnotify.NewWatcher(); err != nil {
+			return fmt.Errorf("watch config: %w", err)
+		}
+		defer watcher.Close()
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			return fmt.Errorf("watch config %s: %w", r.src.File, err)
+		}
+		r.watchTarget(ctx, watcher, file, "", target)
+		events, watchErrs = watcher.Events, watcher.Errors
+	}
+	if r.watching != nil {
+		r.watching()
+	}
+
+	// 2.- Debounce events on the file, its target, or anything that moves the target, and reload.
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig := <-signals:
+			r.logger.InfoContext(ctx, "reloading config", "trigger", sig.String())
+			_ = r.Reload(ctx)
+		case ev := <-events:
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			name := filepath.Clean(ev.Name)
+			next := resolveConfigFile(file)
+			if name == file || name == target || next != target {
+				pending = time.After(reloadDebounce)
+			}
+			if next != target {
+				r.watchTarget(ctx, watcher, file, target, next)
+				target = next
+			}
+		case <-pending:
+			pending = nil
+			r.logger.InfoContext(ctx, "reloading config", "trigger", "file", "path", r.src.File)
+			_ = r.Reload(ctx)
+		case err := <-watchErrs:
+			r.logger.WarnContext(ctx, "config watch error", "path", r.src.File, "error", err)
+		}
+	}
+}
+
+// watchTarget moves the watch on the symlink target's directory from old to next; the
+// config file's own directory is always watched and never removed.
+func (r *Reloader) watchTarget(ctx context.Context, watcher *fsnotify.Watcher, file, old, next string) {
+	own := filepath.Dir(file)
+	if dir := filepath.Dir(old); old != "" && dir != own && dir != filepath.Dir(next) {
+		_ = watcher.Remove(dir)
+	}
+	if dir := filepath.Dir(next); dir != own && dir != filepath.Dir(old) {
+		if err := watcher.Add(dir); err != nil {
+			r.logger.WarnContext(ctx, "config watch error", "path", next, "error", err)
+		}
+	}
+}
+
+// resolveConfigFile follows symlinks to the file that is actually read; a missing file,
+// for instance mid-swap, resolves to itself.
+func resolveConfigFile(file string) string {
+	resolved, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return file
+	}
+	return filepath.Clean(resolved)
+}
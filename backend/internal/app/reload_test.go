@@ -0,0 +1,214 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// applied records every config handed to the reloader's apply hook.
+type applied struct {
+	mu      sync.Mutex
+	configs []Config
+	fail    error
+}
+
+func (a *applied) apply(cfg Config) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configs = append(a.configs, cfg)
+	if a.fail != nil {
+		err := a.fail
+		a.fail = nil
+		return err
+	}
+	return nil
+}
+
+func (a *applied) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.configs)
+}
+
+func newTestReloader(t *testing.T, initial string) (*Reloader, *applied, string, *bytes.Buffer) {
+	t.Helper()
+	file := writeFile(t, "kage.yaml", initial)
+	src := Sources{File: file, LookupEnv: envOf(nil)}
+	cfg, err := LoadConfig(src)
+	if err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+	var logs bytes.Buffer
+	hook := &applied{}
+	return NewReloader(src, cfg, hook.apply, slog.New(slog.NewTextHandler(&logs, nil))), hook, file, &logs
+}
+
+func TestReloaderAppliesLiveSettings(t *testing.T) {
+	r, hook, file, logs := newTestReloader(t, "bidding:\n  radius_km: 5\nhttp:\n  port: \"8080\"\n")
+
+	// 1.- Live settings reach the components and the diff is logged.
+	if err := os.WriteFile(file, []byte("bidding:\n  radius_km: 8\n  weights: {eta: 1}\nhttp:\n  port: \"9090\"\n  rate_limits: {\"*\": \"1:2\"}\n"), 0o600); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if hook.count() != 1 {
+		t.Fatalf("expected one apply got %d", hook.count())
+	}
+	got := r.Current()
+	if got.Bidding.RadiusKm != 8 || got.Bidding.Weights.ETA != 1 || got.HTTP.RateLimits["*"].Burst != 2 {
+		t.Fatalf("live settings not applied %+v", got)
+	}
+	if !strings.Contains(logs.String(), "key=bidding.radius_km old=5 new=8") {
+		t.Fatalf("expected radius diff in logs:\n%s", logs.String())
+	}
+
+	// 2.- Settings that need a restart keep their boot value and are flagged.
+	if got.HTTP.Port != "8080" || !strings.Contains(logs.String(), "requires a restart\" key=http.port") {
+		t.Fatalf("expected port to wait for a restart, port %s logs:\n%s", got.HTTP.Port, logs.String())
+	}
+}
+
+func TestReloaderKeepsPreviousConfig(t *testing.T) {
+	r, hook, file, logs := newTestReloader(t, "bidding:\n  radius_km: 5\n")
+
+	// 1.- An invalid file is rejected before any component sees it.
+	if err := os.WriteFile(file, []byte("bidding:\n  radius_km: -2\n"), 0o600); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if err := r.Reload(context.Background()); err == nil || !strings.Contains(err.Error(), "bidding.radius_km") {
+		t.Fatalf("expected validation error got %v", err)
+	}
+	if hook.count() != 0 || r.Current().Bidding.RadiusKm != 5 {
+		t.Fatalf("expected previous config to stay, applies %d", hook.count())
+	}
+
+	// 2.- A version apply refuses is not applied again nor recorded as current.
+	if err := os.WriteFile(file, []byte("bidding:\n  radius_km: 9\n"), 0o600); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	hook.fail = errors.New("refused")
+	if err := r.Reload(context.Background()); err == nil {
+		t.Fatalf("expected apply error")
+	}
+	if hook.count() != 1 || r.Current().Bidding.RadiusKm != 5 {
+		t.Fatalf("expected radius 5 to stay, applies %+v", hook.configs)
+	}
+	if !strings.Contains(logs.String(), "keeping previous config") {
+		t.Fatalf("expected rejection in logs:\n%s", logs.String())
+	}
+}
+
+// startWatch runs r.Watch until the test ends, returning once its file watches are registered.
+func startWatch(t *testing.T, r *Reloader, signals <-chan os.Signal) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan struct{})
+	r.watching = func() { close(ready) }
+	done := make(chan error, 1)
+	go func() { done <- r.Watch(ctx, signals) }()
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatalf("watch: %v", err)
+	}
+	return func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("watch: %v", err)
+		}
+	}
+}
+
+// waitForApplies polls until hook has seen applies configs or the deadline passes.
+func waitForApplies(t *testing.T, hook *applied, applies int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for hook.count() < applies {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for apply %d", applies)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	r, hook, file, _ := newTestReloader(t, "bidding:\n  radius_km: 5\n")
+	signals := make(chan os.Signal, 1)
+	stop := startWatch(t, r, signals)
+
+	// 1.- Writing the file triggers a reload once the debounce settles.
+	if err := os.WriteFile(file, []byte("bidding:\n  radius_km: 6\n"), 0o600); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	waitForApplies(t, hook, 1)
+	if r.Current().Bidding.RadiusKm != 6 {
+		t.Fatalf("expected radius 6 got %v", r.Current().Bidding.RadiusKm)
+	}
+
+	// 2.- A signal reloads too, picking up the environment of the sources.
+	r.mu.Lock()
+	r.src.LookupEnv = envOf(map[string]string{"BACKEND_EVALUATION_TIMEOUT": "1s"})
+	r.mu.Unlock()
+	signals <- syscall.SIGHUP
+	waitForApplies(t, hook, 2)
+	if r.Current().Bidding.EvaluationTimeout != time.Second {
+		t.Fatalf("expected timeout 1s got %v", r.Current().Bidding.EvaluationTimeout)
+	}
+	stop()
+}
+
+func TestReloaderWatchFollowsSymlinkSwap(t *testing.T) {
+	// 1.- Lay the file out like a ConfigMap mount: kage.yaml -> ..data/kage.yaml, ..data -> ..v1.
+	dir := t.TempDir()
+	version := func(name, body string) {
+		t.Helper()
+		if err := os.Mkdir(filepath.Join(dir, name), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "kage.yaml"), []byte(body), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	version("..v1", "bidding:\n  radius_km: 5\n")
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	file := filepath.Join(dir, "kage.yaml")
+	if err := os.Symlink(filepath.Join("..data", "kage.yaml"), file); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	src := Sources{File: file, LookupEnv: envOf(nil)}
+	cfg, err := LoadConfig(src)
+	if err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+	hook := &applied{}
+	r := NewReloader(src, cfg, hook.apply, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	stop := startWatch(t, r, nil)
+	defer stop()
+
+	// 2.- Swapping ..data atomically to a new version reloads, although kage.yaml itself never changes.
+	version("..v2", "bidding:\n  radius_km: 7\n")
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("swap: %v", err)
+	}
+	waitForApplies(t, hook, 1)
+	if r.Current().Bidding.RadiusKm != 7 {
+		t.Fatalf("expected radius 7 got %v", r.Current().Bidding.RadiusKm)
+	}
+}
@@ -0,0 +1,253 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/ratelimit"
+	"kage/backend/internal/ws"
+)
+
+// redactedValue replaces secrets in printed configuration.
+const redactedValue = "<redacted>"
+
+// setting binds one configuration key to its environment variable, flag and Config field.
+// The key is "section.name" in config files and doubles as the flag name.
+type setting struct {
+	key    string
+	env    string
+	usage  string
+	secret bool
+	// emptyOK lets an empty environment variable override the default instead of being ignored.
+	emptyOK bool
+	// live settings are swapped into running components by Reloader; the rest need a restart.
+	live bool
+	set  func(c *Config, raw string) error
+	get  func(c *Config) any
+	copy func(dst, src *Config)
+}
+
+func field[T any](key, env, usage string, ptr func(*Config) *T, parse func(string) (T, error), show func(T) any) setting {
+	return setting{
+		key: key, env: env, usage: usage,
+		set: func(c *Config, raw string) error {
+			v, err := parse(strings.TrimSpace(raw))
+			if err != nil {
+				return err
+			}
+			*ptr(c) = v
+			return nil
+		},
+		get:  func(c *Config) any { return show(*ptr(c)) },
+		copy: func(dst, src *Config) { *ptr(dst) = *ptr(src) },
+	}
+}
+
+func secret(s setting) setting {
+	s.secret = true
+	return s
+}
+
+func emptyOK(s setting) setting {
+	s.emptyOK = true
+	return s
+}
+
+func live(s setting) setting {
+	s.live = true
+	return s
+}
+
+func stringSetting(key, env, usage string, ptr func(*Config) *string) setting {
+	return field(key, env, usage, ptr, func(raw string) (string, error) { return raw, nil }, func(v string) any { return v })
+}
+
+func durationSetting(key, env, usage string, ptr func(*Config) *time.Duration) setting {
+	return field(key, env, usage, ptr, time.ParseDuration, func(v time.Duration) any { return v.String() })
+}
+
+func floatSetting(key, env, usage string, ptr func(*Config) *float64) setting {
+	return field(key, env, usage, ptr, func(raw string) (float64, error) {
+		return strconv.ParseFloat(raw, 64)
+	}, func(v float64) any { return v })
+}
+
+func intSetting(key, env, usage string, ptr func(*Config) *int) setting {
+	return field(key, env, usage, ptr, strconv.Atoi, func(v int) any { return v })
+}
+
+func boolSetting(key, env, usage string, ptr func(*Config) *bool) setting {
+	return field(key, env, usage, ptr, strconv.ParseBool, func(v bool) any { return v })
+}
+
+// settings lists every configurable key; LoadConfig, the flags and `server config print` derive from it.
+var settings = []setting{
+	stringSetting("env", "BACKEND_ENV", "deployment environment: development, staging or production", func(c *Config) *string { return &c.Env }),
+
+	stringSetting("http.port", "BACKEND_HTTP_PORT", "HTTP listen port", func(c *Config) *string { return &c.HTTP.Port }),
+	durationSetting("http.read_header_timeout", "BACKEND_HTTP_READ_HEADER_TIMEOUT", "time allowed to read request headers", func(c *Config) *time.Duration { return &c.HTTP.ReadHeaderTimeout }),
+	durationSetting("http.idle_timeout", "BACKEND_HTTP_IDLE_TIMEOUT", "keep-alive connection idle timeout", func(c *Config) *time.Duration { return &c.HTTP.IdleTimeout }),
+	durationSetting("http.shutdown_timeout", "BACKEND_HTTP_SHUTDOWN_TIMEOUT", "grace period for in-flight requests on shutdown", func(c *Config) *time.Duration { return &c.HTTP.ShutdownTimeout }),
+	durationSetting("http.health_timeout", "BACKEND_HEALTH_TIMEOUT", "deadline for each /readyz check", func(c *Config) *time.Duration { return &c.HTTP.HealthTimeout }),
+	live(field("http.rate_limits", "BACKEND_RATE_LIMITS", "route=rate:burst entries; * sets the default", func(c *Config) *map[string]ratelimit.Limit { return &c.HTTP.RateLimits },
+		ratelimit.ParseLimits, func(v map[string]ratelimit.Limit) any { return stringMap(v) })),
+	live(field("http.ip_rate_limit", "BACKEND_IP_RATE_LIMIT", "API calls per client IP as rate:burst, checked before authentication", func(c *Config) *ratelimit.Limit { return &c.HTTP.IPRateLimit },
+		ratelimit.ParseLimit, func(v ratelimit.Limit) any { return v.String() })),
+	stringSetting("http.rate_limit_store", "BACKEND_RATE_LIMIT_STORE", "token bucket store: memory or redis", func(c *Config) *string { return &c.HTTP.RateLimitStore }),
+	secret(stringSetting("http.redis_url", "BACKEND_REDIS_URL", "Redis URL for the redis rate limit store", func(c *Config) *string { return &c.HTTP.RedisURL })),
+
+	secret(stringSetting("db.dsn", "BACKEND_DB_DSN", "MariaDB or PostgreSQL DSN; empty keeps state in memory", func(c *Config) *string { return &c.DB.DSN })),
+	boolSetting("db.auto_migrate", "BACKEND_AUTO_MIGRATE", "apply pending migrations on boot", func(c *Config) *bool { return &c.DB.AutoMigrate }),
+	intSetting("db.max_open_conns", "BACKEND_DB_MAX_OPEN_CONNS", "connection pool size; 0 is unlimited", func(c *Config) *int { return &c.DB.MaxOpenConns }),
+	intSetting("db.max_idle_conns", "BACKEND_DB_MAX_IDLE_CONNS", "idle connections kept open", func(c *Config) *int { return &c.DB.MaxIdleConns }),
+	durationSetting("db.conn_max_lifetime", "BACKEND_DB_CONN_MAX_LIFETIME", "connection recycle age; 0 keeps connections forever", func(c *Config) *time.Duration { return &c.DB.ConnMaxLifetime }),
+
+	secret(stringSetting("auth.secret", "BACKEND_AUTH_SECRET", "bearer token signing secret", func(c *Config) *string { return &c.Auth.Secret })),
+
+	live(floatSetting("bidding.radius_km", "BACKEND_RADIUS_KM", "maximum pickup distance of a bid", func(c *Config) *float64 { return &c.Bidding.RadiusKm })),
+	live(durationSetting("bidding.evaluation_timeout", "BACKEND_EVALUATION_TIMEOUT", "deadline for ranking bids", func(c *Config) *time.Duration { return &c.Bidding.EvaluationTimeout })),
+	live(field("bidding.weights", "BACKEND_BIDDING_WEIGHTS", "score weights such as price=0.45,eta=0.35,proximity=0.2", func(c *Config) *bidding.Weights { return &c.Bidding.Weights },
+		bidding.ParseWeights, func(v bidding.Weights) any {
+			return map[string]float64{"price": v.Price, "eta": v.ETA, "proximity": v.Proximity}
+		})),
+	stringSetting("bidding.zones_file", "BACKEND_ZONES_FILE", "GeoJSON service zones", func(c *Config) *string { return &c.Bidding.ZonesFile }),
+	durationSetting("bidding.zones_refresh", "BACKEND_ZONES_REFRESH", "how often the zones file is checked for changes", func(c *Config) *time.Duration { return &c.Bidding.ZonesRefresh }),
+	stringSetting("bidding.road_graph", "BACKEND_ROAD_GRAPH", "road network used for ETA checks", func(c *Config) *string { return &c.Bidding.RoadGraphFile }),
+
+	intSetting("trip.replay_size", "BACKEND_TRIP_REPLAY_SIZE", "events kept per trip for stream resumption", func(c *Config) *int { return &c.Trip.ReplaySize }),
+	durationSetting("trip.replay_ttl", "BACKEND_TRIP_REPLAY_TTL", "how long replay buffers of idle trips live", func(c *Config) *time.Duration { return &c.Trip.ReplayTTL }),
+	durationSetting("trip.stream_heartbeat", "BACKEND_TRIP_STREAM_HEARTBEAT", "keep-alive interval of trip event streams", func(c *Config) *time.Duration { return &c.Trip.StreamHeartbeat }),
+
+	live(field("ws.backpressure", "BACKEND_WS_BACKPRESSURE", "type=size:policy entries; * sets the default", func(c *Config) *map[string]ws.Backpressure { return &c.WS.Backpressure },
+		ws.ParseBackpressure, func(v map[string]ws.Backpressure) any { return stringMap(v) })),
+	durationSetting("ws.location_interval", "BACKEND_WS_LOCATION_INTERVAL", "driver location fan-out interval", func(c *Config) *time.Duration { return &c.WS.LocationInterval }),
+	floatSetting("ws.location_min_move_km", "BACKEND_WS_LOCATION_MIN_MOVE_KM", "movement below which locations are not re-sent", func(c *Config) *float64 { return &c.WS.LocationMinMoveKm }),
+	field("ws.frame_limit", "BACKEND_WS_FRAME_LIMIT", "inbound frames per connection as rate:burst", func(c *Config) *ratelimit.Limit { return &c.WS.FrameLimit },
+		ratelimit.ParseLimit, func(v ratelimit.Limit) any { return v.String() }),
+	durationSetting("ws.read_timeout", "BACKEND_WS_READ_TIMEOUT", "silence after which a websocket is dropped", func(c *Config) *time.Duration { return &c.WS.ReadTimeout }),
+	durationSetting("ws.ping_interval", "BACKEND_WS_PING_INTERVAL", "websocket ping interval; shorter than ws.read_timeout", func(c *Config) *time.Duration { return &c.WS.PingInterval }),
+
+	durationSetting("heatmap.interval", "BACKEND_HEATMAP_INTERVAL", "demand snapshot interval; 0 disables publishing", func(c *Config) *time.Duration { return &c.Heatmap.Interval }),
+	durationSetting("heatmap.half_life", "BACKEND_HEATMAP_HALF_LIFE", "demand decay half-life", func(c *Config) *time.Duration { return &c.Heatmap.HalfLife }),
+
+	stringSetting("tracing.exporter", "BACKEND_TRACE_EXPORTER", "span exporter: none, stdout or otlp", func(c *Config) *string { return &c.Tracing.Exporter }),
+	stringSetting("tracing.endpoint", "BACKEND_TRACE_ENDPOINT", "OTLP endpoint", func(c *Config) *string { return &c.Tracing.Endpoint }),
+	floatSetting("tracing.sample_ratio", "BACKEND_TRACE_SAMPLE_RATIO", "fraction of traces sampled", func(c *Config) *float64 { return &c.Tracing.SampleRatio }),
+
+	field("log.level", "BACKEND_LOG_LEVEL", "initial log level: debug, info, warn or error", func(c *Config) *slog.Level { return &c.Log.Level },
+		func(raw string) (slog.Level, error) {
+			var level slog.Level
+			err := level.UnmarshalText([]byte(raw))
+			return level, err
+		}, func(v slog.Level) any { return strings.ToLower(v.String()) }),
+
+	emptyOK(field("outbox.sinks", "BACKEND_OUTBOX_SINKS", "outbox sinks: ws, webhook, file, kafka", func(c *Config) *[]string { return &c.Outbox.Sinks },
+		func(raw string) ([]string, error) { return splitList(raw), nil }, func(v []string) any { return append([]string{}, v...) })),
+	secret(stringSetting("outbox.webhook_url", "BACKEND_OUTBOX_WEBHOOK_URL", "endpoint of the webhook sink", func(c *Config) *string { return &c.Outbox.WebhookURL })),
+	stringSetting("outbox.file", "BACKEND_OUTBOX_FILE", "JSON lines file of the file sink", func(c *Config) *string { return &c.Outbox.File }),
+	secret(stringSetting("outbox.kafka_url", "BACKEND_OUTBOX_KAFKA_URL", "Kafka REST Proxy of the kafka sink", func(c *Config) *string { return &c.Outbox.KafkaURL })),
+	stringSetting("outbox.kafka_topic", "BACKEND_OUTBOX_KAFKA_TOPIC", "topic of the kafka sink", func(c *Config) *string { return &c.Outbox.KafkaTopic }),
+	durationSetting("outbox.poll_interval", "BACKEND_OUTBOX_POLL_INTERVAL", "relay poll interval", func(c *Config) *time.Duration { return &c.Outbox.PollInterval }),
+	intSetting("outbox.max_attempts", "BACKEND_OUTBOX_MAX_ATTEMPTS", "attempts before a message is dead-lettered", func(c *Config) *int { return &c.Outbox.MaxAttempts }),
+}
+
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func stringMap[V fmt.Stringer](m map[string]V) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v.String()
+	}
+	return out
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// flatten turns a decoded config file into raw values keyed like settings. Lists join with
+// commas and mappings become "key=value" entries, matching the environment variable syntax.
+func flatten(doc map[string]any, invalid *[]contracts.FieldError) map[string]string {
+	out := make(map[string]string)
+	var walk func(prefix string, node map[string]any)
+	walk = func(prefix string, node map[string]any) {
+		for name, value := range node {
+			key := prefix + name
+			if _, ok := lookupSetting(key); ok {
+				raw, err := rawValue(value)
+				if err != nil {
+					*invalid = append(*invalid, contracts.FieldError{Field: key, Reason: err.Error()})
+					continue
+				}
+				out[key] = raw
+				continue
+			}
+			section, ok := value.(map[string]any)
+			if !ok || prefix != "" {
+				*invalid = append(*invalid, contracts.FieldError{Field: key, Reason: "is not a known setting"})
+				continue
+			}
+			walk(key+".", section)
+		}
+	}
+	walk("", doc)
+	return out
+}
+
+func rawValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			raw, err := rawValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = raw
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			raw, err := rawValue(v[k])
+			if err != nil {
+				return "", err
+			}
+			parts[i] = k + "=" + raw
+		}
+		return strings.Join(parts, ","), nil
+	case bool, int, int64, uint64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("has unsupported type %T", value)
+}
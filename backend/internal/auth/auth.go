@@ -1,18 +1,45 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
+	"time"
 )
 
+// ErrInvalidToken is returned for malformed, forged, or expired tokens.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Roles carried by principals.
+const (
+	RoleRider   = "rider"
+	RoleDriver  = "driver"
+	RoleService = "service"
+	RoleOps     = "ops"
+)
+
+// Principal identifies the authenticated caller.
+type Principal struct {
+	ID   string `json:"sub"`
+	Role string `json:"role"`
+}
+
+// ServicePrincipal is granted to callers presenting the shared secret directly.
+var ServicePrincipal = Principal{ID: "service", Role: RoleService}
+
 // Validator validates bearer tokens.
 type Validator struct {
 	secret string
+	now    func() time.Time
 }
 
 // NewValidator creates a validator using the provided shared secret.
 func NewValidator(secret string) *Validator {
-	return &Validator{secret: secret}
+	return &Validator{secret: secret, now: time.Now}
 }
 
 // Middleware verifies Authorization headers on inbound HTTP requests.
@@ -26,7 +53,7 @@ func (v *Validator) Middleware(next http.Handler) http.Handler {
 		}
 
 		// 2.- Compare against the configured secret before invoking the next handler.
-		if !v.valid(token) {
+		if _, err := v.Authenticate(token); err != nil {
 			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
@@ -36,12 +63,65 @@ func (v *Validator) Middleware(next http.Handler) http.Handler {
 
 // ValidateToken returns an error when the provided token does not match the secret.
 func (v *Validator) ValidateToken(token string) error {
-	if !v.valid(token) {
-		return errors.New("invalid token")
+	_, err := v.Authenticate(token)
+	return err
+}
+
+// Authenticate resolves the principal behind an Authorization header value.
+// The bare shared secret maps to ServicePrincipal; signed tokens carry their own subject and role.
+func (v *Validator) Authenticate(token string) (Principal, error) {
+	if token == "Bearer "+v.secret {
+		return ServicePrincipal, nil
+	}
+	raw, ok := strings.CutPrefix(token, "Bearer ")
+	if !ok {
+		return Principal{}, ErrInvalidToken
+	}
+
+	// 1.- Split the token into its version, claims, and signature segments.
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 || parts[0] != "v1" {
+		return Principal{}, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, v.sign(parts[0]+"."+parts[1])) {
+		return Principal{}, ErrInvalidToken
+	}
+
+	// 2.- Decode the claims and reject expired or incomplete principals.
+	body, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	var claims struct {
+		Principal
+		Expires int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	if claims.ID == "" || claims.Role == "" || (claims.Expires > 0 && v.now().Unix() > claims.Expires) {
+		return Principal{}, ErrInvalidToken
+	}
+	return claims.Principal, nil
+}
+
+// Issue mints a signed bearer token for the principal, valid for ttl (zero means no expiry).
+func (v *Validator) Issue(p Principal, ttl time.Duration) string {
+	claims := struct {
+		Principal
+		Expires int64 `json:"exp,omitempty"`
+	}{Principal: p}
+	if ttl > 0 {
+		claims.Expires = v.now().Add(ttl).Unix()
 	}
-	return nil
+	body, _ := json.Marshal(claims)
+	unsigned := "v1." + base64.RawURLEncoding.EncodeToString(body)
+	return "Bearer " + unsigned + "." + base64.RawURLEncoding.EncodeToString(v.sign(unsigned))
 }
 
-func (v *Validator) valid(token string) bool {
-	return token == "Bearer "+v.secret
+func (v *Validator) sign(data string) []byte {
+	mac := hmac.New(sha256.New, []byte(v.secret))
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
 }
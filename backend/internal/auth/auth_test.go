@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuthenticateSharedSecret(t *testing.T) {
+	v := NewValidator("top-secret")
+	p, err := v.Authenticate("Bearer top-secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p != ServicePrincipal {
+		t.Fatalf("expected service principal got %+v", p)
+	}
+	if _, err := v.Authenticate("Bearer nope"); err == nil {
+		t.Fatalf("expected invalid token error")
+	}
+}
+
+func TestAuthenticateSignedToken(t *testing.T) {
+	//1.- Issue a short-lived rider token and resolve it back into a principal.
+	v := NewValidator("top-secret")
+	now := time.Unix(1735689600, 0)
+	v.now = func() time.Time { return now }
+	token := v.Issue(Principal{ID: "rider-1", Role: RoleRider}, time.Minute)
+
+	p, err := v.Authenticate(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.ID != "rider-1" || p.Role != RoleRider {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+
+	//2.- Tokens signed with another secret or past expiry are rejected.
+	if _, err := NewValidator("other").Authenticate(token); err == nil {
+		t.Fatalf("expected forged token to fail")
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := v.Authenticate(token); err == nil {
+		t.Fatalf("expected expired token to fail")
+	}
+}
@@ -0,0 +1,54 @@
+package bidding
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Evaluation outcomes reported to a Recorder.
+const (
+	OutcomeWinner    = "winner"
+	OutcomeNoBids    = "no_bids"
+	OutcomeTimeout   = "timeout"
+	OutcomeRepoError = "repo_error"
+	OutcomeRejected  = "rejected"
+	OutcomeCanceled  = "canceled"
+)
+
+// Recorder receives bidding instrumentation.
+type Recorder interface {
+	ObserveEvaluation(outcome string, elapsed time.Duration)
+	ObserveCandidates(remaining int)
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) ObserveEvaluation(string, time.Duration) {}
+func (nopRecorder) ObserveCandidates(int)                   {}
+
+// WithRecorder reports evaluation outcomes, latencies and filtered candidate counts.
+func WithRecorder(r Recorder) Option {
+	return func(a *Arbiter) {
+		if r != nil {
+			a.recorder = r
+		}
+	}
+}
+
+// evaluationOutcome classifies the result of RankAndSelect.
+func evaluationOutcome(ok bool, err error) string {
+	switch {
+	case err == nil && ok:
+		return OutcomeWinner
+	case err == nil:
+		return OutcomeNoBids
+	case errors.Is(err, ErrEvaluationTimeout), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeTimeout
+	case errors.Is(err, context.Canceled):
+		return OutcomeCanceled
+	case errors.Is(err, ErrOutsideServiceArea), errors.Is(err, ErrNoPickupZone):
+		return OutcomeRejected
+	}
+	return OutcomeRepoError
+}
@@ -3,30 +3,94 @@ package bidding
 import (
 	"context"
 	"database/sql"
+	"errors"
 
 	"kage/backend/internal/contracts"
+	"kage/backend/internal/dialect"
+	"kage/backend/internal/outbox"
+	"kage/backend/internal/tracing"
 )
 
+// TopicBidAccepted is the outbox topic announcing a persisted winning bid.
+const TopicBidAccepted = "bid.accepted"
+
 // Repository persists bidding outcomes.
 type Repository interface {
 	SaveAcceptedBid(ctx context.Context, bid contracts.AcceptedBid) error
 }
 
-// SQLRepository stores accepted bids inside MariaDB using database/sql.
+// SQLRepository stores accepted bids in MySQL/MariaDB or PostgreSQL using database/sql.
 type SQLRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect dialect.Dialect
+	upsert  string
+}
+
+// NewSQLRepository wires the repository with the given database handle and its dialect.
+func NewSQLRepository(db *sql.DB, d dialect.Dialect) *SQLRepository {
+	return &SQLRepository{
+		db:      db,
+		dialect: d,
+		upsert: d.Upsert("accepted_bids",
+			[]string{"bid_id", "trip_id", "rider_id", "driver_id", "price", "accepted_at"},
+			[]string{"bid_id"},
+			[]string{"trip_id", "rider_id", "driver_id", "price", "accepted_at"}),
+	}
+}
+
+// SaveAcceptedBid inserts the accepted bid row; saving the same bid again overwrites it.
+// A changed row enqueues a bid.accepted outbox message in the same transaction.
+func (r *SQLRepository) SaveAcceptedBid(ctx context.Context, bid contracts.AcceptedBid) (err error) {
+	ctx, span := tracing.StartSQL(ctx, r.dialect.Name(), "INSERT", "accepted_bids")
+	defer func() { tracing.End(span, err) }()
+	msg, err := outbox.NewMessage(TopicBidAccepted, bid.TripID, bid, bid.AcceptedAt)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	res, err := tx.ExecContext(ctx, r.upsert, bid.BidID, bid.TripID, bid.RiderID, bid.DriverID, bid.Price, bid.AcceptedAt)
+	if err != nil {
+		return err
+	}
+	// An unchanged replay stays silent; drivers that cannot count rows notify anyway.
+	if n, countErr := res.RowsAffected(); countErr != nil || n > 0 {
+		if err := outbox.Enqueue(ctx, tx, r.dialect, msg); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-// NewSQLRepository wires the repository with the given database handle.
-func NewSQLRepository(db *sql.DB) *SQLRepository {
-	return &SQLRepository{db: db}
+// DriverForTrip returns the driver of the latest accepted bid on tripID, or "" when none was accepted.
+func (r *SQLRepository) DriverForTrip(ctx context.Context, tripID string) (driverID string, err error) {
+	ctx, span := tracing.StartSQL(ctx, r.dialect.Name(), "SELECT", "accepted_bids")
+	defer func() { tracing.End(span, err) }()
+	err = r.db.QueryRowContext(ctx, r.dialect.Rebind(
+		`SELECT driver_id FROM accepted_bids WHERE trip_id = ? ORDER BY accepted_at DESC LIMIT 1`), tripID).Scan(&driverID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return driverID, err
 }
 
-// SaveAcceptedBid inserts the accepted bid row.
-func (r *SQLRepository) SaveAcceptedBid(ctx context.Context, bid contracts.AcceptedBid) error {
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO accepted_bids (bid_id, trip_id, driver_id, price, accepted_at) VALUES (?, ?, ?, ?, ?)`,
-		bid.BidID, bid.TripID, bid.DriverID, bid.Price, bid.AcceptedAt,
-	)
-	return err
+// ParticipantsForTrip returns the rider and driver of the latest accepted bid on tripID; ok is
+// false when none was accepted. Bids saved before riders were recorded have an empty rider.
+func (r *SQLRepository) ParticipantsForTrip(ctx context.Context, tripID string) (p contracts.TripParticipants, ok bool, err error) {
+	ctx, span := tracing.StartSQL(ctx, r.dialect.Name(), "SELECT", "accepted_bids")
+	defer func() { tracing.End(span, err) }()
+	var rider sql.NullString
+	err = r.db.QueryRowContext(ctx, r.dialect.Rebind(
+		`SELECT rider_id, driver_id FROM accepted_bids WHERE trip_id = ? ORDER BY accepted_at DESC LIMIT 1`), tripID).Scan(&rider, &p.DriverID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return contracts.TripParticipants{}, false, nil
+	}
+	if err != nil {
+		return contracts.TripParticipants{}, false, err
+	}
+	p.RiderID = rider.String
+	return p, true, nil
 }
@@ -2,46 +2,135 @@ package bidding
 
 import (
 	"context"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"kage/backend/internal/contracts"
+	"kage/backend/internal/dialect"
 )
 
 func TestSQLRepositorySaveAcceptedBid(t *testing.T) {
-	//1.- Create a sqlmock database handle to observe the executed statement.
+	tests := []struct {
+		dialect dialect.Dialect
+		query   string
+		outbox  string
+	}{
+		{dialect.MySQL,
+			`INSERT INTO accepted_bids (bid_id, trip_id, rider_id, driver_id, price, accepted_at) VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE trip_id = VALUES(trip_id), rider_id = VALUES(rider_id), driver_id = VALUES(driver_id), price = VALUES(price), accepted_at = VALUES(accepted_at)`,
+			`INSERT INTO outbox (id, topic, message_key, payload, created_at, status, attempts, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, 0, ?)`},
+		{dialect.Postgres,
+			`INSERT INTO accepted_bids (bid_id, trip_id, rider_id, driver_id, price, accepted_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (bid_id) DO UPDATE SET trip_id = EXCLUDED.trip_id, rider_id = EXCLUDED.rider_id, driver_id = EXCLUDED.driver_id, price = EXCLUDED.price, accepted_at = EXCLUDED.accepted_at`,
+			`INSERT INTO outbox (id, topic, message_key, payload, created_at, status, attempts, next_attempt_at) VALUES ($1, $2, $3, $4, $5, $6, 0, $7)`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.dialect.Name(), func(t *testing.T) {
+			//1.- Create a sqlmock database handle to observe the executed statements.
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			repo := NewSQLRepository(db, tc.dialect)
+			accepted := contracts.AcceptedBid{
+				BidID:      "bid-123",
+				TripID:     "trip-456",
+				RiderID:    "rider-321",
+				DriverID:   "driver-789",
+				Price:      4200,
+				AcceptedAt: time.Unix(1735689600, 0).UTC(),
+			}
+
+			//2.- Expect the dialect's upsert and the outbox row to commit together.
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(tc.query)).
+				WithArgs(
+					accepted.BidID,
+					accepted.TripID,
+					accepted.RiderID,
+					accepted.DriverID,
+					accepted.Price,
+					accepted.AcceptedAt,
+				).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta(tc.outbox)).
+				WithArgs(sqlmock.AnyArg(), TopicBidAccepted, accepted.TripID,
+					`{"bid_id":"bid-123","trip_id":"trip-456","driver_id":"driver-789","price":4200,"accepted_at":"2025-01-01T00:00:00Z"}`,
+					accepted.AcceptedAt, "pending", accepted.AcceptedAt).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			if err := repo.SaveAcceptedBid(context.Background(), accepted); err != nil {
+				t.Fatalf("SaveAcceptedBid: %v", err)
+			}
+
+			//3.- An unchanged replay commits without a second notification.
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(tc.query)).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+			if err := repo.SaveAcceptedBid(context.Background(), accepted); err != nil {
+				t.Fatalf("SaveAcceptedBid replay: %v", err)
+			}
+
+			//4.- Verify the repository issued the expected SQL statements exactly once.
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestSQLRepositoryDriverForTrip(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("create sqlmock: %v", err)
 	}
 	defer db.Close()
+	repo := NewSQLRepository(db, dialect.Postgres)
+	query := regexp.QuoteMeta(`SELECT driver_id FROM accepted_bids WHERE trip_id = $1 ORDER BY accepted_at DESC LIMIT 1`)
+
+	//1.- The latest accepted bid names the driver.
+	mock.ExpectQuery(query).WithArgs("trip-1").WillReturnRows(sqlmock.NewRows([]string{"driver_id"}).AddRow("driver-9"))
+	if driver, err := repo.DriverForTrip(context.Background(), "trip-1"); err != nil || driver != "driver-9" {
+		t.Fatalf("expected driver-9 got %q %v", driver, err)
+	}
 
-	repo := NewSQLRepository(db)
-	accepted := contracts.AcceptedBid{
-		BidID:      "bid-123",
-		TripID:     "trip-456",
-		DriverID:   "driver-789",
-		Price:      4200,
-		AcceptedAt: time.Unix(1735689600, 0).UTC(),
+	//2.- Trips without an accepted bid have no driver rather than an error.
+	mock.ExpectQuery(query).WithArgs("trip-2").WillReturnRows(sqlmock.NewRows([]string{"driver_id"}))
+	if driver, err := repo.DriverForTrip(context.Background(), "trip-2"); err != nil || driver != "" {
+		t.Fatalf("expected no driver got %q %v", driver, err)
 	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
 
-	//2.- Expect an INSERT with the accepted bid payload and ensure it succeeds.
-	mock.ExpectExec(`INSERT INTO accepted_bids \(bid_id, trip_id, driver_id, price, accepted_at\) VALUES \(\?, \?, \?, \?, \?\)`).
-		WithArgs(
-			accepted.BidID,
-			accepted.TripID,
-			accepted.DriverID,
-			accepted.Price,
-			accepted.AcceptedAt,
-		).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+func TestSQLRepositoryParticipantsForTrip(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+	repo := NewSQLRepository(db, dialect.MySQL)
+	query := regexp.QuoteMeta(`SELECT rider_id, driver_id FROM accepted_bids WHERE trip_id = ? ORDER BY accepted_at DESC LIMIT 1`)
 
-	if err := repo.SaveAcceptedBid(context.Background(), accepted); err != nil {
-		t.Fatalf("SaveAcceptedBid: %v", err)
+	//1.- The latest accepted bid names both participants.
+	mock.ExpectQuery(query).WithArgs("trip-1").WillReturnRows(sqlmock.NewRows([]string{"rider_id", "driver_id"}).AddRow("rider-1", "driver-9"))
+	if p, ok, err := repo.ParticipantsForTrip(context.Background(), "trip-1"); err != nil || !ok || p != (contracts.TripParticipants{RiderID: "rider-1", DriverID: "driver-9"}) {
+		t.Fatalf("unexpected participants %+v %v %v", p, ok, err)
 	}
 
-	//3.- Verify the repository issued the expected SQL statement exactly once.
+	//2.- Bids saved before riders were recorded keep an empty rider; trips without a bid report none.
+	mock.ExpectQuery(query).WithArgs("trip-2").WillReturnRows(sqlmock.NewRows([]string{"rider_id", "driver_id"}).AddRow(nil, "driver-9"))
+	if p, ok, err := repo.ParticipantsForTrip(context.Background(), "trip-2"); err != nil || !ok || p.RiderID != "" || p.DriverID != "driver-9" {
+		t.Fatalf("unexpected legacy participants %+v %v %v", p, ok, err)
+	}
+	mock.ExpectQuery(query).WithArgs("trip-3").WillReturnRows(sqlmock.NewRows([]string{"rider_id", "driver_id"}))
+	if _, ok, err := repo.ParticipantsForTrip(context.Background(), "trip-3"); err != nil || ok {
+		t.Fatalf("expected no participants got %v %v", ok, err)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("unmet expectations: %v", err)
 	}
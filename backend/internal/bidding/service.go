@@ -3,16 +3,41 @@ package bidding
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"slices"
 	"sort"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"kage/backend/internal/contracts"
 	"kage/backend/internal/geo"
+	"kage/backend/internal/logging"
+	"kage/backend/internal/routing"
+	"kage/backend/internal/tracing"
 )
 
 // ErrEvaluationTimeout occurs when ranking exceeds the configured deadline.
 var ErrEvaluationTimeout = errors.New("bid evaluation timeout")
 
+// ErrOutsideServiceArea occurs when the pickup lies outside every service zone.
+var ErrOutsideServiceArea = errors.New("pickup outside service area")
+
+// ErrNoPickupZone occurs when the pickup lies inside a zone where pickups are forbidden.
+var ErrNoPickupZone = errors.New("pickup not allowed in this zone")
+
+// ZoneLookup resolves the geofences containing a coordinate.
+type ZoneLookup interface {
+	ZonesAt(lat, lon float64) []geo.Zone
+	HasKind(kind string) bool
+}
+
+// RouteEstimator computes road distance and travel time between two coordinates.
+type RouteEstimator interface {
+	Route(ctx context.Context, fromLat, fromLon, toLat, toLon float64) (routing.Route, error)
+}
+
 // Clock abstracts time for deterministic testing.
 type Clock interface {
 	Now() time.Time
@@ -28,12 +53,23 @@ func (RealClock) Now() time.Time { return time.Now() }
 // After defers to time.After.
 func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
 
+// Tuning holds the arbitration settings that can change while the arbiter serves requests.
+type Tuning struct {
+	RadiusKm          float64
+	EvaluationTimeout time.Duration
+	Weights           Weights
+}
+
 // Arbiter evaluates bids and persists the winner.
 type Arbiter struct {
-	repo              Repository
-	clock             Clock
-	evaluationTimeout time.Duration
-	radiusKm          float64
+	repo     Repository
+	clock    Clock
+	mu       sync.RWMutex
+	tuning   Tuning
+	zones    ZoneLookup
+	router   RouteEstimator
+	recorder Recorder
+	logger   *slog.Logger
 }
 
 // Option mutates Arbiter configuration.
@@ -41,12 +77,27 @@ type Option func(*Arbiter)
 
 // WithTimeout configures a custom evaluation timeout.
 func WithTimeout(d time.Duration) Option {
-	return func(a *Arbiter) { a.evaluationTimeout = d }
+	return func(a *Arbiter) { a.tuning.EvaluationTimeout = d }
 }
 
 // WithRadius configures the proximity radius filter.
 func WithRadius(km float64) Option {
-	return func(a *Arbiter) { a.radiusKm = km }
+	return func(a *Arbiter) { a.tuning.RadiusKm = km }
+}
+
+// WithWeights replaces DefaultWeights in the bid score.
+func WithWeights(w Weights) Option {
+	return func(a *Arbiter) { a.tuning.Weights = w }
+}
+
+// WithZones enables service-area and no-pickup checks against the given zones.
+func WithZones(zones ZoneLookup) Option {
+	return func(a *Arbiter) { a.zones = zones }
+}
+
+// WithRouter verifies bid ETAs and proximity against road-network routes instead of straight-line distance.
+func WithRouter(router RouteEstimator) Option {
+	return func(a *Arbiter) { a.router = router }
 }
 
 // WithClock injects a custom clock for tests.
@@ -54,13 +105,19 @@ func WithClock(clock Clock) Option {
 	return func(a *Arbiter) { a.clock = clock }
 }
 
+// WithLogger writes evaluation results to logger instead of slog.Default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(a *Arbiter) { a.logger = logging.OrDefault(logger) }
+}
+
 // NewArbiter builds the orchestrator with sane defaults.
 func NewArbiter(repo Repository, opts ...Option) *Arbiter {
 	a := &Arbiter{
-		repo:              repo,
-		clock:             RealClock{},
-		evaluationTimeout: 3 * time.Second,
-		radiusKm:          5,
+		repo:     repo,
+		clock:    RealClock{},
+		tuning:   Tuning{RadiusKm: 5, EvaluationTimeout: 3 * time.Second, Weights: DefaultWeights},
+		recorder: nopRecorder{},
+		logger:   slog.Default(),
 	}
 	for _, opt := range opts {
 		opt(a)
@@ -68,8 +125,61 @@ func NewArbiter(repo Repository, opts ...Option) *Arbiter {
 	return a
 }
 
+// Tuning returns the settings the next evaluation will use.
+func (a *Arbiter) Tuning() Tuning {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.tuning
+}
+
+// SetTuning swaps the settings for evaluations that start afterwards; running ones keep theirs.
+func (a *Arbiter) SetTuning(t Tuning) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tuning = t
+}
+
 // RankAndSelect picks the optimal bid and persists it using the repository.
 func (a *Arbiter) RankAndSelect(ctx context.Context, req contracts.BidRequest, bids []contracts.Bid) (contracts.Bid, bool, error) {
+	ctx = logging.WithTripID(ctx, req.TripID)
+	ctx, span := tracing.Start(ctx, "bidding.RankAndSelect",
+		attribute.String("trip.id", req.TripID), attribute.Int("bids.count", len(bids)))
+	start := time.Now()
+	winner, ok, err := a.rankAndSelect(ctx, req, bids)
+	outcome := evaluationOutcome(ok, err)
+	elapsed := time.Since(start)
+	a.recorder.ObserveEvaluation(outcome, elapsed)
+	a.logEvaluation(ctx, outcome, len(bids), winner, elapsed, err)
+	span.SetAttributes(attribute.String("bidding.outcome", outcome))
+	if ok {
+		span.SetAttributes(attribute.String("bid.id", winner.ID))
+	}
+	tracing.End(span, err)
+	return winner, ok, err
+}
+
+// logEvaluation records one line per evaluation, warning on failures the caller cannot fix.
+func (a *Arbiter) logEvaluation(ctx context.Context, outcome string, bids int, winner contracts.Bid, elapsed time.Duration, err error) {
+	attrs := []slog.Attr{slog.String("outcome", outcome), slog.Int("bids", bids), slog.Duration("elapsed", elapsed)}
+	level := slog.LevelInfo
+	switch outcome {
+	case OutcomeWinner:
+		attrs = append(attrs, slog.String("bid_id", winner.ID), slog.String("driver_id", winner.DriverID))
+	case OutcomeTimeout, OutcomeRepoError:
+		level = slog.LevelWarn
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	a.logger.LogAttrs(ctx, level, "bid evaluation", attrs...)
+}
+
+func (a *Arbiter) rankAndSelect(ctx context.Context, req contracts.BidRequest, bids []contracts.Bid) (contracts.Bid, bool, error) {
+	if _, err := a.PickupZones(req); err != nil {
+		return contracts.Bid{}, false, err
+	}
+	tuning := a.Tuning()
+
 	resCh := make(chan struct {
 		bid contracts.Bid
 		ok  bool
@@ -77,8 +187,9 @@ func (a *Arbiter) RankAndSelect(ctx context.Context, req contracts.BidRequest, b
 	}, 1)
 
 	go func() {
-		// 1.- Filter bids by freshness, budget, travel time, and radius.
-		candidates := a.filterBids(req, bids)
+		// 1.- Filter bids by freshness, budget, radius, and verified travel time.
+		candidates := a.filterBids(ctx, req, bids, tuning.RadiusKm)
+		a.recorder.ObserveCandidates(len(candidates))
 		if len(candidates) == 0 {
 			resCh <- struct {
 				bid contracts.Bid
@@ -89,11 +200,12 @@ func (a *Arbiter) RankAndSelect(ctx context.Context, req contracts.BidRequest, b
 		}
 
 		// 2.- Score the remaining bids, persist the winner, and emit the result.
-		winner := a.rankCandidates(req, candidates)
+		winner := a.rankCandidates(ctx, req, candidates, tuning.Weights)
 		if a.repo != nil {
 			err := a.repo.SaveAcceptedBid(ctx, contracts.AcceptedBid{
 				BidID:      winner.ID,
 				TripID:     winner.TripID,
+				RiderID:    req.RiderID,
 				DriverID:   winner.DriverID,
 				Price:      winner.Price,
 				AcceptedAt: a.clock.Now(),
@@ -115,8 +227,8 @@ func (a *Arbiter) RankAndSelect(ctx context.Context, req contracts.BidRequest, b
 	}()
 
 	var timeout <-chan time.Time
-	if a.evaluationTimeout > 0 {
-		timeout = a.clock.After(a.evaluationTimeout)
+	if tuning.EvaluationTimeout > 0 {
+		timeout = a.clock.After(tuning.EvaluationTimeout)
 	}
 
 	select {
@@ -129,9 +241,44 @@ func (a *Arbiter) RankAndSelect(ctx context.Context, req contracts.BidRequest, b
 	}
 }
 
-func (a *Arbiter) filterBids(req contracts.BidRequest, bids []contracts.Bid) []contracts.Bid {
+// PickupZones returns the ids of zones containing the request origin,
+// failing when the pickup is outside every service zone or inside a no-pickup zone.
+func (a *Arbiter) PickupZones(req contracts.BidRequest) ([]string, error) {
+	if a.zones == nil {
+		return nil, nil
+	}
+	zones := a.zones.ZonesAt(req.Latitude, req.Longitude)
+	ids := make([]string, 0, len(zones))
+	inService := false
+	for _, z := range zones {
+		switch z.Kind {
+		case geo.ZoneKindNoPickup:
+			return nil, ErrNoPickupZone
+		case geo.ZoneKindService:
+			inService = true
+		}
+		// A zone split across several features is reported once.
+		if !slices.Contains(ids, z.ID) {
+			ids = append(ids, z.ID)
+		}
+	}
+	if !inService && a.zones.HasKind(geo.ZoneKindService) {
+		return nil, ErrOutsideServiceArea
+	}
+	return ids, nil
+}
+
+// candidate pairs a bid with its distance to the pickup.
+type candidate struct {
+	bid        contracts.Bid
+	distanceKm float64
+}
+
+func (a *Arbiter) filterBids(ctx context.Context, req contracts.BidRequest, bids []contracts.Bid, radiusKm float64) []candidate {
+	ctx, span := tracing.Start(ctx, "bidding.filterBids", attribute.Int("bids.count", len(bids)))
+	defer span.End()
 	now := a.clock.Now()
-	var filtered []contracts.Bid
+	var filtered []candidate
 	for _, bid := range bids {
 		if !bid.ExpiresAt.IsZero() && bid.ExpiresAt.Before(now) {
 			continue
@@ -139,38 +286,65 @@ func (a *Arbiter) filterBids(req contracts.BidRequest, bids []contracts.Bid) []c
 		if req.MaxPrice > 0 && bid.Price > req.MaxPrice {
 			continue
 		}
-		if req.MaxETA > 0 && bid.ETA > req.MaxETA {
+		if radiusKm > 0 && !geo.WithinRadius(req.Latitude, req.Longitude, bid.Latitude, bid.Longitude, radiusKm) {
 			continue
 		}
-		if a.radiusKm > 0 && !geo.WithinRadius(req.Latitude, req.Longitude, bid.Latitude, bid.Longitude, a.radiusKm) {
+		c, ok := a.estimate(ctx, req, bid)
+		if !ok {
 			continue
 		}
-		filtered = append(filtered, bid)
+		if req.MaxETA > 0 && c.bid.ETA > req.MaxETA {
+			continue
+		}
+		filtered = append(filtered, c)
 	}
+	span.SetAttributes(attribute.Int("candidates.count", len(filtered)))
 	return filtered
 }
 
-func (a *Arbiter) rankCandidates(req contracts.BidRequest, bids []contracts.Bid) contracts.Bid {
-	type candidate struct {
+// estimate replaces straight-line distance with the road route when a router is configured.
+// A driver may be slower than the road allows but never faster, so the larger ETA wins.
+// Unreachable pickups drop the bid; other routing failures fall back to the driver's figures.
+func (a *Arbiter) estimate(ctx context.Context, req contracts.BidRequest, bid contracts.Bid) (candidate, bool) {
+	c := candidate{bid: bid, distanceKm: geo.DistanceBetween(req.Latitude, req.Longitude, bid.Latitude, bid.Longitude)}
+	if a.router == nil {
+		return c, true
+	}
+	route, err := a.router.Route(ctx, bid.Latitude, bid.Longitude, req.Latitude, req.Longitude)
+	if errors.Is(err, routing.ErrNoRoute) {
+		return c, false
+	}
+	if err != nil {
+		return c, true
+	}
+	c.distanceKm = route.DistanceKm
+	if route.Duration > c.bid.ETA {
+		c.bid.ETA = route.Duration
+	}
+	return c, true
+}
+
+func (a *Arbiter) rankCandidates(ctx context.Context, req contracts.BidRequest, candidates []candidate, weights Weights) contracts.Bid {
+	_, span := tracing.Start(ctx, "bidding.rankCandidates", attribute.Int("candidates.count", len(candidates)))
+	defer span.End()
+	type scored struct {
 		bid   contracts.Bid
 		score float64
 	}
 
-	var candidates []candidate
-	for _, bid := range bids {
-		distance := geo.DistanceBetween(req.Latitude, req.Longitude, bid.Latitude, bid.Longitude)
-		score := a.computeScore(req, bid, distance)
-		candidates = append(candidates, candidate{bid: bid, score: score})
+	var ranked []scored
+	for _, c := range candidates {
+		ranked = append(ranked, scored{bid: c.bid, score: computeScore(req, c.bid, c.distanceKm, weights)})
 	}
 
-	sort.SliceStable(candidates, func(i, j int) bool {
-		return candidates[i].score > candidates[j].score
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
 	})
 
-	return candidates[0].bid
+	return ranked[0].bid
 }
 
-func (a *Arbiter) computeScore(req contracts.BidRequest, bid contracts.Bid, distance float64) float64 {
+func computeScore(req contracts.BidRequest, bid contracts.Bid, distance float64, weights Weights) float64 {
 	priceComponent := 1.0
 	if req.MaxPrice > 0 {
 		priceComponent = (req.MaxPrice - bid.Price) / req.MaxPrice
@@ -186,5 +360,5 @@ func (a *Arbiter) computeScore(req contracts.BidRequest, bid contracts.Bid, dist
 		priceComponent = 0
 	}
 	proximityComponent := 1 / (1 + distance)
-	return 0.45*priceComponent + 0.35*timeComponent + 0.2*proximityComponent
+	return weights.Price*priceComponent + weights.ETA*timeComponent + weights.Proximity*proximityComponent
 }
@@ -3,10 +3,14 @@ package bidding
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"kage/backend/internal/contracts"
+	"kage/backend/internal/geo"
+	"kage/backend/internal/routing"
 )
 
 type fakeRepo struct {
@@ -76,3 +80,132 @@ func TestRankAndSelectTimeout(t *testing.T) {
 		t.Fatalf("expected no winner due to timeout")
 	}
 }
+
+func TestRankAndSelectZones(t *testing.T) {
+	zones := geo.NewZoneSet([]geo.Zone{
+		{ID: "city", Kind: geo.ZoneKindService, Geometry: geo.MultiPolygon{{{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}, {-1, -1}}}}},
+		{ID: "city", Kind: geo.ZoneKindService, Geometry: geo.MultiPolygon{{{{-0.5, -0.5}, {0.5, -0.5}, {0.5, 0.5}, {-0.5, 0.5}, {-0.5, -0.5}}}}},
+		{ID: "plaza", Kind: geo.ZoneKindNoPickup, Geometry: geo.MultiPolygon{{{{0.5, 0.5}, {0.9, 0.5}, {0.9, 0.9}, {0.5, 0.9}, {0.5, 0.5}}}}},
+	})
+	fc := &fakeClock{now: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)}
+	arbiter := NewArbiter(&fakeRepo{}, WithClock(fc), WithRadius(10), WithTimeout(time.Minute), WithZones(zones))
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		err      error
+	}{
+		{"inside service area", 0, 0, nil},
+		{"outside service area", 5, 5, ErrOutsideServiceArea},
+		{"no pickup zone", 0.7, 0.7, ErrNoPickupZone},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := contracts.BidRequest{TripID: "t1", Latitude: tc.lat, Longitude: tc.lon, MaxETA: time.Hour, MaxPrice: 50}
+			_, _, err := arbiter.RankAndSelect(context.Background(), req, nil)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v got %v", tc.err, err)
+			}
+		})
+	}
+
+	ids, err := arbiter.PickupZones(contracts.BidRequest{Latitude: 0, Longitude: 0})
+	if err != nil || len(ids) != 1 || ids[0] != "city" {
+		t.Fatalf("expected city tag got %v %v", ids, err)
+	}
+}
+
+type fakeRouter struct {
+	routes map[string]routing.Route
+}
+
+func (f *fakeRouter) Route(ctx context.Context, fromLat, fromLon, toLat, toLon float64) (routing.Route, error) {
+	route, ok := f.routes[fmt.Sprintf("%g,%g", fromLat, fromLon)]
+	if !ok {
+		return routing.Route{}, routing.ErrNoRoute
+	}
+	return route, nil
+}
+
+func TestRankAndSelectUsesRoadETA(t *testing.T) {
+	fc := &fakeClock{now: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)}
+	router := &fakeRouter{routes: map[string]routing.Route{
+		// b1 sits across a river: close in a straight line, far by road.
+		"0.01,0.01": {DistanceKm: 9, Duration: 25 * time.Minute},
+		"0.03,0.03": {DistanceKm: 4, Duration: 8 * time.Minute},
+	}}
+	arbiter := NewArbiter(&fakeRepo{}, WithClock(fc), WithRadius(10), WithTimeout(time.Minute), WithRouter(router))
+
+	req := contracts.BidRequest{TripID: "t1", MaxETA: 20 * time.Minute, MaxPrice: 50}
+	bids := []contracts.Bid{
+		{ID: "b1", DriverID: "d1", TripID: "t1", Price: 30, Latitude: 0.01, Longitude: 0.01, ETA: 3 * time.Minute},
+		{ID: "b2", DriverID: "d2", TripID: "t1", Price: 35, Latitude: 0.03, Longitude: 0.03, ETA: 5 * time.Minute},
+		{ID: "b3", DriverID: "d3", TripID: "t1", Price: 20, Latitude: 0.02, Longitude: 0.02, ETA: 2 * time.Minute},
+	}
+
+	winner, ok, err := arbiter.RankAndSelect(context.Background(), req, bids)
+	if err != nil || !ok {
+		t.Fatalf("expected a winner, got ok=%v err=%v", ok, err)
+	}
+	// b1 exceeds MaxETA once routed and b3 is unreachable, so b2 wins with its verified ETA.
+	if winner.ID != "b2" {
+		t.Fatalf("expected b2 to win got %s", winner.ID)
+	}
+	if winner.ETA != 8*time.Minute {
+		t.Fatalf("expected routed ETA to replace the driver's, got %v", winner.ETA)
+	}
+}
+
+type countingRecorder struct {
+	mu         sync.Mutex
+	outcomes   map[string]int
+	candidates []int
+}
+
+func (r *countingRecorder) ObserveEvaluation(outcome string, _ time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outcomes[outcome]++
+}
+
+func (r *countingRecorder) ObserveCandidates(remaining int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.candidates = append(r.candidates, remaining)
+}
+
+func TestRankAndSelectRecordsOutcomes(t *testing.T) {
+	// 1.- Drive one evaluation into each outcome.
+	fc := &fakeClock{now: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)}
+	rec := &countingRecorder{outcomes: make(map[string]int)}
+	repo := &fakeRepo{}
+	arbiter := NewArbiter(repo, WithClock(fc), WithRadius(10), WithRecorder(rec))
+	req := contracts.BidRequest{TripID: "t1", MaxPrice: 50}
+	bids := []contracts.Bid{
+		{ID: "b1", TripID: "t1", Price: 40, Latitude: 0.01, Longitude: 0.01},
+		{ID: "b2", TripID: "t1", Price: 90, Latitude: 0.01, Longitude: 0.01},
+	}
+	if _, ok, err := arbiter.RankAndSelect(context.Background(), req, bids); !ok || err != nil {
+		t.Fatalf("expected winner, got %v %v", ok, err)
+	}
+	if _, ok, _ := arbiter.RankAndSelect(context.Background(), req, bids[1:]); ok {
+		t.Fatalf("expected no winner")
+	}
+	repo.err = errors.New("disk full")
+	if _, _, err := arbiter.RankAndSelect(context.Background(), req, bids); err == nil {
+		t.Fatalf("expected repository error")
+	}
+
+	// 2.- Every call is classified once and candidate counts reflect the filters.
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	want := map[string]int{OutcomeWinner: 1, OutcomeNoBids: 1, OutcomeRepoError: 1}
+	for outcome, n := range want {
+		if rec.outcomes[outcome] != n {
+			t.Fatalf("outcome %s: want %d got %v", outcome, n, rec.outcomes)
+		}
+	}
+	if len(rec.candidates) != 3 || rec.candidates[0] != 1 || rec.candidates[1] != 0 || rec.candidates[2] != 1 {
+		t.Fatalf("unexpected candidate counts %v", rec.candidates)
+	}
+}
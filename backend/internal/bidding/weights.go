@@ -0,0 +1,61 @@
+package bidding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Weights balance the price, ETA and proximity components of a bid score; only their ratios affect the ranking.
+type Weights struct {
+	Price     float64
+	ETA       float64
+	Proximity float64
+}
+
+// DefaultWeights favour price, then ETA, then proximity.
+var DefaultWeights = Weights{Price: 0.45, ETA: 0.35, Proximity: 0.2}
+
+// Valid reports whether every weight is non-negative and at least one is positive.
+func (w Weights) Valid() bool {
+	return w.Price >= 0 && w.ETA >= 0 && w.Proximity >= 0 && w.Price+w.ETA+w.Proximity > 0
+}
+
+// String renders the weights in the form ParseWeights accepts.
+func (w Weights) String() string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	return "price=" + format(w.Price) + ",eta=" + format(w.ETA) + ",proximity=" + format(w.Proximity)
+}
+
+// ParseWeights decodes specs such as "price=0.5,eta=0.3,proximity=0.2"; omitted components keep their default.
+func ParseWeights(spec string) (Weights, error) {
+	w := DefaultWeights
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return Weights{}, fmt.Errorf("weight entry %q: expected name=value", entry)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || v < 0 {
+			return Weights{}, fmt.Errorf("weight entry %q: invalid value", entry)
+		}
+		switch strings.TrimSpace(name) {
+		case "price":
+			w.Price = v
+		case "eta":
+			w.ETA = v
+		case "proximity":
+			w.Proximity = v
+		default:
+			return Weights{}, fmt.Errorf("weight entry %q: unknown component; expected price, eta or proximity", entry)
+		}
+	}
+	if !w.Valid() {
+		return Weights{}, fmt.Errorf("weights %q: at least one must be positive", spec)
+	}
+	return w, nil
+}
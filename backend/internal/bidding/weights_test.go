@@ -0,0 +1,68 @@
+package bidding
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kage/backend/internal/contracts"
+)
+
+func TestWeightsChangeTheWinner(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	req := contracts.BidRequest{RiderID: "r1", TripID: "t1", MaxETA: 30 * time.Minute, MaxPrice: 50}
+	bids := []contracts.Bid{
+		{ID: "cheap", DriverID: "d1", TripID: "t1", Price: 10, Latitude: 0.01, ETA: 25 * time.Minute, ExpiresAt: now.Add(time.Hour)},
+		{ID: "fast", DriverID: "d2", TripID: "t1", Price: 45, Latitude: 0.01, ETA: 2 * time.Minute, ExpiresAt: now.Add(time.Hour)},
+	}
+
+	// 1.- The default weights favour price.
+	winner, _, err := NewArbiter(&fakeRepo{}, WithClock(&fakeClock{now: now}), WithTimeout(time.Minute)).RankAndSelect(context.Background(), req, bids)
+	if err != nil || winner.ID != "cheap" {
+		t.Fatalf("expected cheap to win got %s %v", winner.ID, err)
+	}
+
+	// 2.- Weighting ETA alone flips the ranking.
+	winner, _, err = NewArbiter(&fakeRepo{}, WithClock(&fakeClock{now: now}), WithTimeout(time.Minute), WithWeights(Weights{ETA: 1})).RankAndSelect(context.Background(), req, bids)
+	if err != nil || winner.ID != "fast" {
+		t.Fatalf("expected fast to win got %s %v", winner.ID, err)
+	}
+}
+
+func TestSetTuningAppliesToLaterEvaluations(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	req := contracts.BidRequest{RiderID: "r1", TripID: "t1", MaxETA: 30 * time.Minute, MaxPrice: 50}
+	bids := []contracts.Bid{{ID: "far", DriverID: "d1", TripID: "t1", Price: 10, Latitude: 0.05, ETA: 5 * time.Minute, ExpiresAt: now.Add(time.Hour)}}
+	arbiter := NewArbiter(&fakeRepo{}, WithClock(&fakeClock{now: now}), WithTimeout(time.Minute), WithRadius(5))
+
+	// 1.- A bid about 5.5 km away falls outside the initial radius.
+	if _, ok, err := arbiter.RankAndSelect(context.Background(), req, bids); ok || err != nil {
+		t.Fatalf("expected no winner got %v %v", ok, err)
+	}
+
+	// 2.- Widening the radius admits it without rebuilding the arbiter.
+	tuning := arbiter.Tuning()
+	tuning.RadiusKm = 10
+	arbiter.SetTuning(tuning)
+	if winner, ok, err := arbiter.RankAndSelect(context.Background(), req, bids); !ok || err != nil || winner.ID != "far" {
+		t.Fatalf("expected far to win got %s %v %v", winner.ID, ok, err)
+	}
+	if got := arbiter.Tuning(); got.EvaluationTimeout != time.Minute || got.Weights != DefaultWeights {
+		t.Fatalf("unexpected tuning %+v", got)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights("price=0.6, eta=0.4")
+	if err != nil || w != (Weights{Price: 0.6, ETA: 0.4, Proximity: DefaultWeights.Proximity}) {
+		t.Fatalf("unexpected weights %+v: %v", w, err)
+	}
+	if round, err := ParseWeights(w.String()); err != nil || round != w {
+		t.Fatalf("expected round trip got %+v: %v", round, err)
+	}
+	for _, spec := range []string{"price", "price=-1", "speed=1", "price=0,eta=0,proximity=0"} {
+		if _, err := ParseWeights(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package chat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrBlocked occurs when moderation refuses to deliver a message.
+var ErrBlocked = errors.New("chat message blocked")
+
+// MaxBodyLength bounds the size of a single chat message.
+const MaxBodyLength = 2000
+
+// Message is a single rider–driver chat entry scoped to a trip.
+type Message struct {
+	ID              string    `json:"id"`
+	ClientMessageID string    `json:"client_message_id"`
+	TripID          string    `json:"trip_id"`
+	SenderID        string    `json:"sender_id"`
+	SenderRole      string    `json:"sender_role"`
+	Body            string    `json:"body"`
+	Redacted        bool      `json:"redacted,omitempty"`
+	SentAt          time.Time `json:"sent_at"`
+	ReceivedAt      time.Time `json:"received_at"`
+	ReadAt          time.Time `json:"read_at,omitzero"`
+}
+
+// Validate checks the fields a sender must supply.
+func (m Message) Validate() error {
+	switch {
+	case m.TripID == "":
+		return errors.New("trip_id is required")
+	case m.SenderID == "":
+		return errors.New("sender is required")
+	case m.ClientMessageID == "":
+		return errors.New("client_message_id is required")
+	case m.Body == "":
+		return errors.New("body is required")
+	case len(m.Body) > MaxBodyLength:
+		return errors.New("body is too long")
+	}
+	return nil
+}
+
+// Store persists chat history so it survives reconnects.
+type Store interface {
+	// Save stores the message, returning the previously stored copy when the client id was already seen.
+	Save(ctx context.Context, msg Message) (Message, error)
+	// History lists up to limit messages for the trip received after the given time, oldest first.
+	History(ctx context.Context, tripID string, after time.Time, limit int) ([]Message, error)
+	// MarkRead records that readerID has read the message; it is idempotent.
+	MarkRead(ctx context.Context, tripID, messageID, readerID string, at time.Time) error
+}
+
+// NewID returns a random identifier for server-assigned message ids.
+func NewID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Action is the verdict returned by a Moderator.
+type Action string
+
+const (
+	ActionAllow  Action = "allow"
+	ActionRedact Action = "redact"
+	ActionBlock  Action = "block"
+)
+
+// Decision describes how a message should be altered before delivery.
+type Decision struct {
+	Action Action
+	// Body replaces the message text when Action is ActionRedact.
+	Body   string
+	Reason string
+}
+
+// Moderator inspects messages before they are stored and delivered.
+type Moderator interface {
+	Moderate(ctx context.Context, msg Message) (Decision, error)
+}
+
+// ModeratorFunc adapts a function into a Moderator.
+type ModeratorFunc func(ctx context.Context, msg Message) (Decision, error)
+
+// Moderate calls f.
+func (f ModeratorFunc) Moderate(ctx context.Context, msg Message) (Decision, error) {
+	return f(ctx, msg)
+}
+
+// Apply runs the moderator against msg and returns the message to deliver.
+func Apply(ctx context.Context, mod Moderator, msg Message) (Message, error) {
+	if mod == nil {
+		return msg, nil
+	}
+	decision, err := mod.Moderate(ctx, msg)
+	if err != nil {
+		return Message{}, err
+	}
+	switch decision.Action {
+	case ActionBlock:
+		return Message{}, ErrBlocked
+	case ActionRedact:
+		msg.Body = decision.Body
+		msg.Redacted = true
+	}
+	return msg, nil
+}
@@ -0,0 +1,55 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApplyModeration(t *testing.T) {
+	msg := Message{TripID: "t1", SenderID: "r1", ClientMessageID: "c1", Body: "call me at 555-0100"}
+	redactor := ModeratorFunc(func(_ context.Context, m Message) (Decision, error) {
+		if strings.Contains(m.Body, "555") {
+			return Decision{Action: ActionRedact, Body: "[redacted]", Reason: "phone number"}, nil
+		}
+		return Decision{Action: ActionAllow}, nil
+	})
+
+	//1.- Redaction replaces the body and flags the message.
+	got, err := Apply(context.Background(), redactor, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Body != "[redacted]" || !got.Redacted {
+		t.Fatalf("expected redacted message got %+v", got)
+	}
+
+	//2.- Blocking surfaces ErrBlocked and a nil moderator passes messages through.
+	blocker := ModeratorFunc(func(context.Context, Message) (Decision, error) {
+		return Decision{Action: ActionBlock}, nil
+	})
+	if _, err := Apply(context.Background(), blocker, msg); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected ErrBlocked got %v", err)
+	}
+	if got, _ := Apply(context.Background(), nil, msg); got.Body != msg.Body {
+		t.Fatalf("expected passthrough without moderator")
+	}
+}
+
+func TestMessageValidate(t *testing.T) {
+	valid := Message{TripID: "t1", SenderID: "r1", ClientMessageID: "c1", Body: "hi"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	missing := valid
+	missing.ClientMessageID = ""
+	if err := missing.Validate(); err == nil {
+		t.Fatalf("expected missing client id to fail")
+	}
+	long := valid
+	long.Body = strings.Repeat("x", MaxBodyLength+1)
+	if err := long.Validate(); err == nil {
+		t.Fatalf("expected long body to fail")
+	}
+}
@@ -0,0 +1,185 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"kage/backend/internal/dialect"
+	"kage/backend/internal/tracing"
+)
+
+// MemoryStore keeps chat history in process memory.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	messages map[string][]Message
+}
+
+// NewMemoryStore constructs an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{messages: make(map[string][]Message)}
+}
+
+// Save appends the message unless the sender already used the client message id.
+func (s *MemoryStore) Save(_ context.Context, msg Message) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.messages[msg.TripID] {
+		if existing.SenderID == msg.SenderID && existing.ClientMessageID == msg.ClientMessageID {
+			return existing, nil
+		}
+	}
+	s.messages[msg.TripID] = append(s.messages[msg.TripID], msg)
+	return msg, nil
+}
+
+// History returns messages received after the cursor in arrival order.
+func (s *MemoryStore) History(_ context.Context, tripID string, after time.Time, limit int) ([]Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []Message
+	for _, msg := range s.messages[tripID] {
+		if !msg.ReceivedAt.After(after) {
+			continue
+		}
+		out = append(out, msg)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ReceivedAt.Before(out[j].ReceivedAt) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// MarkRead stamps the read time on a message addressed to the reader; repeated calls are no-ops.
+func (s *MemoryStore) MarkRead(_ context.Context, tripID, messageID, readerID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, msg := range s.messages[tripID] {
+		if msg.ID == messageID && msg.SenderID != readerID && msg.ReadAt.IsZero() {
+			s.messages[tripID][i].ReadAt = at
+		}
+	}
+	return nil
+}
+
+// SQLStore persists chat history using database/sql.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+	insert  string
+}
+
+// NewSQLStore builds a store backed by MariaDB or PostgreSQL.
+func NewSQLStore(db *sql.DB, d dialect.Dialect) *SQLStore {
+	return &SQLStore{
+		db:      db,
+		dialect: d,
+		insert: d.Upsert("chat_messages",
+			[]string{"id", "client_message_id", "trip_id", "sender_id", "sender_role", "body", "redacted", "sent_at", "received_at"},
+			[]string{"trip_id", "sender_id", "client_message_id"},
+			nil),
+	}
+}
+
+// Save inserts the message row, returning the stored copy for repeated client ids.
+func (s *SQLStore) Save(ctx context.Context, msg Message) (Message, error) {
+	// 1.- Insert unless the sender already used the client id; the unique key settles concurrent retries.
+	inserted, err := s.insertOnce(ctx, msg)
+	if err != nil || inserted {
+		return msg, err
+	}
+
+	// 2.- Return the earlier row when the client retried the same message.
+	return s.scanOne(ctx, s.dialect.Rebind(
+		`SELECT id, client_message_id, trip_id, sender_id, sender_role, body, redacted, sent_at, received_at, read_at FROM chat_messages WHERE trip_id = ? AND sender_id = ? AND client_message_id = ?`),
+		msg.TripID, msg.SenderID, msg.ClientMessageID,
+	)
+}
+
+// insertOnce reports whether the row was written; databases with RETURNING answer with the new id
+// and no row on conflict, the others report zero affected rows.
+func (s *SQLStore) insertOnce(ctx context.Context, msg Message) (inserted bool, err error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "INSERT", "chat_messages")
+	defer func() { tracing.End(span, err) }()
+	args := []interface{}{msg.ID, msg.ClientMessageID, msg.TripID, msg.SenderID, msg.SenderRole, msg.Body, msg.Redacted, msg.SentAt, msg.ReceivedAt}
+	if query, ok := s.dialect.Returning(s.insert, "id"); ok {
+		var id string
+		err = s.db.QueryRowContext(ctx, query, args...).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	res, err := s.db.ExecContext(ctx, s.insert, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// History selects messages received after the cursor ordered by arrival.
+func (s *SQLStore) History(ctx context.Context, tripID string, after time.Time, limit int) (out []Message, err error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "SELECT", "chat_messages")
+	defer func() { tracing.End(span, err) }()
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(
+		`SELECT id, client_message_id, trip_id, sender_id, sender_role, body, redacted, sent_at, received_at, read_at FROM chat_messages WHERE trip_id = ? AND received_at > ? ORDER BY received_at ASC LIMIT ?`),
+		tripID, after, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, msg)
+	}
+	return out, rows.Err()
+}
+
+// MarkRead updates read_at for messages the reader did not send.
+func (s *SQLStore) MarkRead(ctx context.Context, tripID, messageID, readerID string, at time.Time) error {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "UPDATE", "chat_messages")
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE chat_messages SET read_at = ? WHERE trip_id = ? AND id = ? AND sender_id <> ? AND read_at IS NULL`),
+		at, tripID, messageID, readerID,
+	)
+	return tracing.End(span, err)
+}
+
+// scanOne selects a single message; a missing row is an expected outcome, not a span error.
+func (s *SQLStore) scanOne(ctx context.Context, query string, args ...interface{}) (Message, error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "SELECT", "chat_messages")
+	msg, err := scanMessage(s.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		span.End()
+		return msg, err
+	}
+	return msg, tracing.End(span, err)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row scanner) (Message, error) {
+	var msg Message
+	var readAt sql.NullTime
+	if err := row.Scan(&msg.ID, &msg.ClientMessageID, &msg.TripID, &msg.SenderID, &msg.SenderRole, &msg.Body, &msg.Redacted, &msg.SentAt, &msg.ReceivedAt, &readAt); err != nil {
+		return Message{}, err
+	}
+	if readAt.Valid {
+		msg.ReadAt = readAt.Time
+	}
+	return msg, nil
+}
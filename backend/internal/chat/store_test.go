@@ -0,0 +1,158 @@
+package chat
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"kage/backend/internal/dialect"
+)
+
+func TestMemoryStoreHistoryAndReceipts(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	base := time.Unix(1735689600, 0).UTC()
+	first := Message{ID: "m1", ClientMessageID: "c1", TripID: "t1", SenderID: "r1", Body: "hi", ReceivedAt: base}
+	second := Message{ID: "m2", ClientMessageID: "c2", TripID: "t1", SenderID: "d1", Body: "on my way", ReceivedAt: base.Add(time.Second)}
+
+	//1.- Duplicate client ids return the original message instead of storing twice.
+	for _, msg := range []Message{first, second} {
+		if _, err := store.Save(ctx, msg); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	dup := first
+	dup.ID = "m3"
+	stored, _ := store.Save(ctx, dup)
+	if stored.ID != "m1" {
+		t.Fatalf("expected duplicate to resolve to m1 got %s", stored.ID)
+	}
+
+	//2.- Read receipts only apply to messages sent by someone else.
+	_ = store.MarkRead(ctx, "t1", "m1", "r1", base.Add(time.Minute))
+	_ = store.MarkRead(ctx, "t1", "m2", "r1", base.Add(time.Minute))
+
+	history, err := store.History(ctx, "t1", base, 10)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) != 1 || history[0].ID != "m2" {
+		t.Fatalf("expected only messages after the cursor, got %+v", history)
+	}
+	if history[0].ReadAt.IsZero() {
+		t.Fatalf("expected m2 marked read")
+	}
+	all, _ := store.History(ctx, "t1", time.Time{}, 10)
+	if len(all) != 2 || !all[0].ReadAt.IsZero() {
+		t.Fatalf("sender must not mark their own message read: %+v", all)
+	}
+}
+
+func TestSQLStoreSave(t *testing.T) {
+	const lookup = `SELECT id, client_message_id, trip_id, sender_id, sender_role, body, redacted, sent_at, received_at, read_at FROM chat_messages WHERE trip_id = $1 AND sender_id = $2 AND client_message_id = $3`
+	msg := Message{
+		ID:              "m2",
+		ClientMessageID: "c1",
+		TripID:          "t1",
+		SenderID:        "r1",
+		SenderRole:      "rider",
+		Body:            "hi",
+		SentAt:          time.Unix(1735689600, 0).UTC(),
+		ReceivedAt:      time.Unix(1735689601, 0).UTC(),
+	}
+	args := []driver.Value{msg.ID, msg.ClientMessageID, msg.TripID, msg.SenderID, msg.SenderRole, msg.Body, msg.Redacted, msg.SentAt, msg.ReceivedAt}
+	tests := []struct {
+		name    string
+		dialect dialect.Dialect
+		expect  func(sqlmock.Sqlmock)
+		wantID  string
+	}{
+		{"mysql insert", dialect.MySQL, func(mock sqlmock.Sqlmock) {
+			mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO chat_messages (id, client_message_id, trip_id, sender_id, sender_role, body, redacted, sent_at, received_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE trip_id = trip_id`)).
+				WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, 1))
+		}, "m2"},
+		{"mysql duplicate", dialect.MySQL, func(mock sqlmock.Sqlmock) {
+			mock.ExpectExec(`INSERT INTO chat_messages`).WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(regexp.QuoteMeta(`WHERE trip_id = ? AND sender_id = ? AND client_message_id = ?`)).
+				WithArgs(msg.TripID, msg.SenderID, msg.ClientMessageID).WillReturnRows(storedRow(msg))
+		}, "m1"},
+		{"postgresql insert", dialect.Postgres, func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO chat_messages (id, client_message_id, trip_id, sender_id, sender_role, body, redacted, sent_at, received_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (trip_id, sender_id, client_message_id) DO NOTHING RETURNING id`)).
+				WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(msg.ID))
+		}, "m2"},
+		{"postgresql duplicate", dialect.Postgres, func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(`INSERT INTO chat_messages .* RETURNING id`).WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			mock.ExpectQuery(regexp.QuoteMeta(lookup)).
+				WithArgs(msg.TripID, msg.SenderID, msg.ClientMessageID).WillReturnRows(storedRow(msg))
+		}, "m1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			//1.- Prepare sqlmock to observe the insert and, for retries, the lookup of the stored row.
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("create sqlmock: %v", err)
+			}
+			defer db.Close()
+			tc.expect(mock)
+
+			//2.- A retried client id resolves to the message stored first.
+			stored, err := NewSQLStore(db, tc.dialect).Save(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if stored.ID != tc.wantID {
+				t.Fatalf("expected %s got %s", tc.wantID, stored.ID)
+			}
+
+			//3.- Ensure the statements executed in order.
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+// storedRow is the earlier copy of msg saved under id m1.
+func storedRow(msg Message) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "client_message_id", "trip_id", "sender_id", "sender_role", "body", "redacted", "sent_at", "received_at", "read_at"}).
+		AddRow("m1", msg.ClientMessageID, msg.TripID, msg.SenderID, msg.SenderRole, msg.Body, false, msg.SentAt, msg.ReceivedAt, nil)
+}
+
+func TestSQLStoreHistory(t *testing.T) {
+	tests := []struct {
+		dialect dialect.Dialect
+		query   string
+	}{
+		{dialect.MySQL, `SELECT .* FROM chat_messages WHERE trip_id = \? AND received_at > \? ORDER BY received_at ASC LIMIT \?`},
+		{dialect.Postgres, `SELECT .* FROM chat_messages WHERE trip_id = \$1 AND received_at > \$2 ORDER BY received_at ASC LIMIT \$3`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.dialect.Name(), func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			received := time.Unix(1735689601, 0).UTC()
+			rows := sqlmock.NewRows([]string{"id", "client_message_id", "trip_id", "sender_id", "sender_role", "body", "redacted", "sent_at", "received_at", "read_at"}).
+				AddRow("m1", "c1", "t1", "r1", "rider", "hi", false, received, received, nil)
+			mock.ExpectQuery(tc.query).
+				WithArgs("t1", time.Time{}, 50).
+				WillReturnRows(rows)
+
+			history, err := NewSQLStore(db, tc.dialect).History(context.Background(), "t1", time.Time{}, 50)
+			if err != nil {
+				t.Fatalf("History: %v", err)
+			}
+			if len(history) != 1 || history[0].ID != "m1" || !history[0].ReadAt.IsZero() {
+				t.Fatalf("unexpected history %+v", history)
+			}
+		})
+	}
+}
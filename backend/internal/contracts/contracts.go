@@ -24,13 +24,26 @@ type BidRequest struct {
 	MaxPrice  float64
 }
 
-// AcceptedBid records the winning offer for persistence.
+// AcceptedBid records the winning offer for persistence and outbound notifications.
+// RiderID is persisted for participant checks but kept out of notifications sent to partners.
 type AcceptedBid struct {
-	BidID      string
-	TripID     string
-	DriverID   string
-	Price      float64
-	AcceptedAt time.Time
+	BidID      string    `json:"bid_id"`
+	TripID     string    `json:"trip_id"`
+	RiderID    string    `json:"-"`
+	DriverID   string    `json:"driver_id"`
+	Price      float64   `json:"price"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+// TripParticipants names the rider and the driver whose bid was accepted for a trip.
+type TripParticipants struct {
+	RiderID  string `json:"rider_id"`
+	DriverID string `json:"driver_id"`
+}
+
+// Includes reports whether id rides or drives the trip.
+func (p TripParticipants) Includes(id string) bool {
+	return id != "" && (id == p.RiderID || id == p.DriverID)
 }
 
 // TripState captures the lifecycle stage of a trip.
@@ -44,10 +57,10 @@ const (
 	TripStateComplete TripState = "complete"
 )
 
-// TripEvent describes a state change event for auditing.
+// TripEvent describes a state change event for auditing and outbound notifications.
 type TripEvent struct {
-	TripID     string
-	State      TripState
-	OccurredAt time.Time
-	Notes      string
+	TripID     string    `json:"trip_id"`
+	State      TripState `json:"state"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Notes      string    `json:"notes"`
 }
@@ -0,0 +1,54 @@
+package contracts
+
+import (
+	"errors"
+	"strings"
+
+	"kage/backend/internal/geo"
+)
+
+// FieldError names one offending input field and why it was rejected.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError collects every invalid field found while decoding a payload.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + " " + f.Reason
+	}
+	return "invalid input: " + strings.Join(parts, "; ")
+}
+
+// WithPrefix qualifies every field name with prefix, e.g. "bids[2].".
+func (e *ValidationError) WithPrefix(prefix string) *ValidationError {
+	out := &ValidationError{Fields: make([]FieldError, len(e.Fields))}
+	for i, f := range e.Fields {
+		out.Fields[i] = FieldError{Field: prefix + f.Field, Reason: f.Reason}
+	}
+	return out
+}
+
+// NormalizeCoordinate wraps the longitude across the antimeridian and validates both
+// components, reporting failures as a ValidationError under the given field names.
+func NormalizeCoordinate(lat, lon float64, latField, lonField string) (geo.LatLng, error) {
+	c, err := geo.LatLng{Lat: lat, Lon: lon}.Normalize()
+	var coordErr *geo.CoordinateError
+	if !errors.As(err, &coordErr) {
+		return c, err
+	}
+	verr := &ValidationError{}
+	if coordErr.Latitude != "" {
+		verr.Fields = append(verr.Fields, FieldError{Field: latField, Reason: coordErr.Latitude})
+	}
+	if coordErr.Longitude != "" {
+		verr.Fields = append(verr.Fields, FieldError{Field: lonField, Reason: coordErr.Longitude})
+	}
+	return c, verr
+}
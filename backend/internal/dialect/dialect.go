@@ -0,0 +1,144 @@
+// Package dialect hides the SQL differences between MySQL/MariaDB and PostgreSQL.
+//
+// Repositories write queries with ? placeholders and pass them through Rebind;
+// statements whose syntax differs are built by the dialect itself.
+package dialect
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// ErrUnsupportedScheme occurs when a DSN names a database this build cannot talk to.
+var ErrUnsupportedScheme = errors.New("unsupported database scheme")
+
+// Dialect renders the statements that differ between databases.
+type Dialect interface {
+	// Name is the OpenTelemetry db.system value, such as "mysql" or "postgresql".
+	Name() string
+	// Driver is the database/sql driver name.
+	Driver() string
+	// Rebind rewrites ? placeholders into the dialect's bind syntax.
+	Rebind(query string) string
+	// Upsert builds an insert of columns that updates the listed columns when the conflict key exists,
+	// or leaves the stored row alone when update is empty.
+	Upsert(table string, columns, conflict, update []string) string
+	// Returning appends a RETURNING clause; ok is false when the database cannot return rows from writes.
+	Returning(query string, columns ...string) (out string, ok bool)
+}
+
+var (
+	// MySQL covers MySQL and MariaDB through go-sql-driver/mysql.
+	MySQL Dialect = mysqlDialect{}
+	// Postgres covers PostgreSQL through pgx.
+	Postgres Dialect = postgresDialect{}
+)
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string               { return "mysql" }
+func (mysqlDialect) Driver() string             { return "mysql" }
+func (mysqlDialect) Rebind(query string) string { return query }
+
+func (mysqlDialect) Upsert(table string, columns, conflict, update []string) string {
+	assignments := make([]string, 0, len(update))
+	for _, col := range update {
+		assignments = append(assignments, col+" = VALUES("+col+")")
+	}
+	if len(assignments) == 0 {
+		// 1.- A self-assignment keeps the row untouched without INSERT IGNORE swallowing other errors.
+		assignments = append(assignments, conflict[0]+" = "+conflict[0])
+	}
+	return insert(table, columns, "?") + " ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+}
+
+func (mysqlDialect) Returning(query string, _ ...string) (string, bool) { return query, false }
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string   { return "postgresql" }
+func (postgresDialect) Driver() string { return "pgx" }
+
+// Rebind numbers placeholders $1, $2, ... and leaves question marks inside quoted literals alone.
+func (postgresDialect) Rebind(query string) string {
+	var (
+		b     strings.Builder
+		n     int
+		quote rune
+	)
+	b.Grow(len(query) + 8)
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (d postgresDialect) Upsert(table string, columns, conflict, update []string) string {
+	action := "DO NOTHING"
+	if len(update) > 0 {
+		assignments := make([]string, 0, len(update))
+		for _, col := range update {
+			assignments = append(assignments, col+" = EXCLUDED."+col)
+		}
+		action = "DO UPDATE SET " + strings.Join(assignments, ", ")
+	}
+	return d.Rebind(insert(table, columns, "?") + " ON CONFLICT (" + strings.Join(conflict, ", ") + ") " + action)
+}
+
+func (postgresDialect) Returning(query string, columns ...string) (string, bool) {
+	return query + " RETURNING " + strings.Join(columns, ", "), true
+}
+
+// insert renders INSERT INTO table (columns) VALUES (placeholders).
+func insert(table string, columns []string, placeholder string) string {
+	marks := strings.TrimSuffix(strings.Repeat(placeholder+", ", len(columns)), ", ")
+	return "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + marks + ")"
+}
+
+// FromDSN picks the dialect from the DSN scheme and returns the DSN its driver expects.
+// postgres:// and postgresql:// select PostgreSQL; mysql:// or a bare go-sql-driver DSN
+// such as user:pass@tcp(host:3306)/db select MySQL.
+func FromDSN(dsn string) (Dialect, string, error) {
+	scheme, rest, ok := strings.Cut(dsn, "://")
+	if !ok {
+		return MySQL, dsn, nil
+	}
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return Postgres, dsn, nil
+	case "mysql", "mariadb":
+		return MySQL, rest, nil
+	}
+	return nil, "", fmt.Errorf("%w %q", ErrUnsupportedScheme, scheme)
+}
+
+// Open connects lazily to dsn with the driver its scheme selects.
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	d, driverDSN, err := FromDSN(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open(d.Driver(), driverDSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open %s: %w", d.Name(), err)
+	}
+	return db, d, nil
+}
@@ -0,0 +1,41 @@
+package geo
+
+import "testing"
+
+func TestBoundingBoxContains(t *testing.T) {
+	tests := []struct {
+		name     string
+		box      BoundingBox
+		lat, lon float64
+		expected bool
+	}{
+		{"inside", BoundingBox{MinLat: 19, MinLon: -100, MaxLat: 20, MaxLon: -99}, 19.4, -99.1, true},
+		{"outside latitude", BoundingBox{MinLat: 19, MinLon: -100, MaxLat: 20, MaxLon: -99}, 21, -99.1, false},
+		{"antimeridian east side", BoundingBox{MinLat: -20, MinLon: 170, MaxLat: -10, MaxLon: -170}, -15, 175, true},
+		{"antimeridian west side", BoundingBox{MinLat: -20, MinLon: 170, MaxLat: -10, MaxLon: -170}, -15, -175, true},
+		{"antimeridian gap", BoundingBox{MinLat: -20, MinLon: 170, MaxLat: -10, MaxLon: -170}, -15, 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.box.Contains(tc.lat, tc.lon); got != tc.expected {
+				t.Fatalf("expected %v got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseBoundingBox(t *testing.T) {
+	b, err := ParseBoundingBox("19,-100,20,-99")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b != (BoundingBox{MinLat: 19, MinLon: -100, MaxLat: 20, MaxLon: -99}) {
+		t.Fatalf("unexpected box %+v", b)
+	}
+	for _, bad := range []string{"1,2,3", "a,b,c,d", "20,0,19,1", "0,0,95,1"} {
+		if _, err := ParseBoundingBox(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
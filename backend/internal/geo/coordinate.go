@@ -0,0 +1,67 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// LatLng is a coordinate pair in degrees.
+type LatLng struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// CoordinateError reports why each component of a coordinate was rejected; empty reasons are valid.
+type CoordinateError struct {
+	Latitude  string
+	Longitude string
+}
+
+func (e *CoordinateError) Error() string {
+	var parts []string
+	if e.Latitude != "" {
+		parts = append(parts, "latitude "+e.Latitude)
+	}
+	if e.Longitude != "" {
+		parts = append(parts, "longitude "+e.Longitude)
+	}
+	return "invalid coordinate: " + strings.Join(parts, ", ")
+}
+
+// Validate checks that both components are finite and inside the WGS84 range.
+func (c LatLng) Validate() error {
+	e := &CoordinateError{Latitude: checkComponent(c.Lat, 90), Longitude: checkComponent(c.Lon, 180)}
+	if e.Latitude != "" || e.Longitude != "" {
+		return e
+	}
+	return nil
+}
+
+// Normalize wraps finite longitudes into [-180, 180) so points past the antimeridian
+// are accepted, then validates the result. Latitudes are never wrapped.
+func (c LatLng) Normalize() (LatLng, error) {
+	if !math.IsNaN(c.Lon) && !math.IsInf(c.Lon, 0) && (c.Lon < -180 || c.Lon > 180) {
+		c.Lon = math.Mod(c.Lon+180, 360)
+		if c.Lon < 0 {
+			c.Lon += 360
+		}
+		c.Lon -= 180
+	}
+	if err := c.Validate(); err != nil {
+		return LatLng{}, err
+	}
+	return c, nil
+}
+
+func checkComponent(v, limit float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "must be a number"
+	case math.IsInf(v, 0):
+		return "must be finite"
+	case v < -limit || v > limit:
+		return fmt.Sprintf("must be between %g and %g", -limit, limit)
+	}
+	return ""
+}
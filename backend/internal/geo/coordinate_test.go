@@ -0,0 +1,48 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLatLngNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       LatLng
+		expected LatLng
+		latErr   bool
+		lonErr   bool
+	}{
+		{"valid", LatLng{19.43, -99.13}, LatLng{19.43, -99.13}, false, false},
+		{"edges", LatLng{90, 180}, LatLng{90, 180}, false, false},
+		{"wraps east of antimeridian", LatLng{-17, 190}, LatLng{-17, -170}, false, false},
+		{"wraps west of antimeridian", LatLng{-17, -190}, LatLng{-17, 170}, false, false},
+		{"wraps several turns", LatLng{0, 900}, LatLng{0, -180}, false, false},
+		{"latitude out of range", LatLng{200, 10}, LatLng{}, true, false},
+		{"nan latitude", LatLng{math.NaN(), 10}, LatLng{}, true, false},
+		{"infinite longitude", LatLng{10, math.Inf(-1)}, LatLng{}, false, true},
+		{"both invalid", LatLng{-91, math.NaN()}, LatLng{}, true, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.in.Normalize()
+			if !tc.latErr && !tc.lonErr {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if mathAbs(got.Lat-tc.expected.Lat) > 1e-9 || mathAbs(got.Lon-tc.expected.Lon) > 1e-9 {
+					t.Fatalf("expected %v got %v", tc.expected, got)
+				}
+				return
+			}
+			var coordErr *CoordinateError
+			if !errors.As(err, &coordErr) {
+				t.Fatalf("expected coordinate error got %v", err)
+			}
+			if (coordErr.Latitude != "") != tc.latErr || (coordErr.Longitude != "") != tc.lonErr {
+				t.Fatalf("unexpected fields %+v", coordErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash encodes the coordinate into a base32 geohash of the given precision.
+func Geohash(lat, lon float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+	out := make([]byte, 0, precision)
+	bit, ch, even := 0, 0, true
+	for len(out) < precision {
+		// 1.- Alternate between longitude and latitude bisection, starting with longitude.
+		if even {
+			mid := (minLon + maxLon) / 2
+			if lon >= mid {
+				ch = ch<<1 | 1
+				minLon = mid
+			} else {
+				ch <<= 1
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		even = !even
+
+		// 2.- Emit a base32 character every five bits.
+		bit++
+		if bit == 5 {
+			out = append(out, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(out)
+}
+
+// GeohashBounds decodes a geohash into the bounding box of its cell.
+func GeohashBounds(hash string) (BoundingBox, error) {
+	b := BoundingBox{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180}
+	even := true
+	for i := 0; i < len(hash); i++ {
+		idx := strings.IndexByte(geohashAlphabet, hash[i])
+		if idx < 0 {
+			return BoundingBox{}, fmt.Errorf("geohash %q: invalid character %q", hash, hash[i])
+		}
+		// 1.- Replay the bisections encoded by each of the five bits.
+		for bit := 4; bit >= 0; bit-- {
+			set := idx>>bit&1 == 1
+			if even {
+				mid := (b.MinLon + b.MaxLon) / 2
+				if set {
+					b.MinLon = mid
+				} else {
+					b.MaxLon = mid
+				}
+			} else {
+				mid := (b.MinLat + b.MaxLat) / 2
+				if set {
+					b.MinLat = mid
+				} else {
+					b.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return b, nil
+}
+
+// geohashCellSize returns the height and width in degrees of a geohash cell.
+func geohashCellSize(precision int) (latDeg, lonDeg float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Exp2(float64(latBits)), 360 / math.Exp2(float64(lonBits))
+}
+
+// Point is an identified coordinate stored in an Index.
+type Point struct {
+	ID  string
+	Lat float64
+	Lon float64
+}
+
+// Neighbor is a query result together with its distance from the query origin.
+type Neighbor struct {
+	Point
+	DistanceKm float64
+}
+
+// Index buckets points into geohash cells for fast proximity queries.
+// It is safe for concurrent readers and writers.
+type Index struct {
+	mu        sync.RWMutex
+	precision int
+	cellLat   float64
+	cellLon   float64
+	cells     map[string]map[string]Point
+	cellOf    map[string]string
+}
+
+// NewIndex builds an index using geohash cells of the given precision (1-12; 6 is ~1.2 km x 0.6 km).
+func NewIndex(precision int) *Index {
+	if precision < 1 || precision > 12 {
+		precision = 6
+	}
+	latDeg, lonDeg := geohashCellSize(precision)
+	return &Index{
+		precision: precision,
+		cellLat:   latDeg,
+		cellLon:   lonDeg,
+		cells:     make(map[string]map[string]Point),
+		cellOf:    make(map[string]string),
+	}
+}
+
+// Insert adds or moves the point identified by p.ID.
+func (x *Index) Insert(p Point) {
+	cell := Geohash(p.Lat, p.Lon, x.precision)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if prev, ok := x.cellOf[p.ID]; ok && prev != cell {
+		x.removeFromCell(prev, p.ID)
+	}
+	bucket, ok := x.cells[cell]
+	if !ok {
+		bucket = make(map[string]Point)
+		x.cells[cell] = bucket
+	}
+	bucket[p.ID] = p
+	x.cellOf[p.ID] = cell
+}
+
+// Update moves an existing point; it behaves like Insert for unknown ids.
+func (x *Index) Update(p Point) {
+	x.Insert(p)
+}
+
+// Remove deletes the point and reports whether it was present.
+func (x *Index) Remove(id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	cell, ok := x.cellOf[id]
+	if !ok {
+		return false
+	}
+	x.removeFromCell(cell, id)
+	delete(x.cellOf, id)
+	return true
+}
+
+// Len returns the number of indexed points.
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.cellOf)
+}
+
+// WithinRadius returns every point within radiusKm of the origin, nearest first.
+func (x *Index) WithinRadius(lat, lon, radiusKm float64) []Neighbor {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	out := x.collect(lat, lon, radiusKm)
+	sortNeighbors(out)
+	return out
+}
+
+// Nearest returns up to k points closest to the origin, nearest first.
+func (x *Index) Nearest(lat, lon float64, k int) []Neighbor {
+	if k <= 0 {
+		return nil
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if len(x.cellOf) == 0 {
+		return nil
+	}
+
+	// 1.- Grow the search radius until it holds k points; everything outside it is farther away.
+	radius := math.Max(x.cellLat, x.cellLon) * math.Pi * earthRadiusKm / 180
+	const halfCircumferenceKm = math.Pi * earthRadiusKm
+	for {
+		found := x.collect(lat, lon, radius)
+		if len(found) >= k || radius >= halfCircumferenceKm {
+			sortNeighbors(found)
+			if len(found) > k {
+				found = found[:k]
+			}
+			return found
+		}
+		radius *= 2
+	}
+}
+
+// collect gathers points within radiusKm by scanning the covering cells. Callers hold x.mu.
+func (x *Index) collect(lat, lon, radiusKm float64) []Neighbor {
+	var out []Neighbor
+	for _, cell := range x.coveringCells(lat, lon, radiusKm) {
+		for _, p := range x.cells[cell] {
+			d := DistanceBetween(lat, lon, p.Lat, p.Lon)
+			if d <= radiusKm {
+				out = append(out, Neighbor{Point: p, DistanceKm: d})
+			}
+		}
+	}
+	return out
+}
+
+// coveringCells lists the geohash cells overlapping the bounding box of the search circle.
+func (x *Index) coveringCells(lat, lon, radiusKm float64) []string {
+	angular := radiusKm / earthRadiusKm
+	dLat := angular * 180 / math.Pi
+	minLat, maxLat := math.Max(-90, lat-dLat), math.Min(90, lat+dLat)
+
+	// 1.- Bound the longitude span exactly; circles touching a pole cover every longitude.
+	spanLon := 360.0
+	if maxLat < 90 && minLat > -90 {
+		if ratio := math.Sin(angular) / math.Cos(degreesToRadians(lat)); ratio < 1 {
+			spanLon = math.Min(360, 2*math.Asin(ratio)*180/math.Pi)
+		}
+	}
+
+	// 2.- When the box spans more cells than are populated, scanning populated cells is cheaper.
+	if x.estimateCells(maxLat-minLat, spanLon) > len(x.cells) {
+		cells := make([]string, 0, len(x.cells))
+		for cell := range x.cells {
+			cells = append(cells, cell)
+		}
+		return cells
+	}
+
+	// 3.- Step through the box one cell at a time, wrapping longitude across the antimeridian.
+	seen := make(map[string]struct{})
+	var cells []string
+	for cLat := minLat; ; cLat += x.cellLat {
+		if cLat > maxLat {
+			cLat = maxLat
+		}
+		for step := 0.0; ; step += x.cellLon {
+			if step > spanLon {
+				step = spanLon
+			}
+			cLon := normalizeLon(lon - spanLon/2 + step)
+			cell := Geohash(cLat, cLon, x.precision)
+			if _, ok := seen[cell]; !ok {
+				seen[cell] = struct{}{}
+				if _, exists := x.cells[cell]; exists {
+					cells = append(cells, cell)
+				}
+			}
+			if step >= spanLon {
+				break
+			}
+		}
+		if cLat >= maxLat {
+			break
+		}
+	}
+	return cells
+}
+
+func (x *Index) estimateCells(latSpan, lonSpan float64) int {
+	return int((latSpan/x.cellLat + 1) * (lonSpan/x.cellLon + 1))
+}
+
+func (x *Index) removeFromCell(cell, id string) {
+	bucket := x.cells[cell]
+	delete(bucket, id)
+	if len(bucket) == 0 {
+		delete(x.cells, cell)
+	}
+}
+
+func normalizeLon(lon float64) float64 {
+	for lon < -180 {
+		lon += 360
+	}
+	for lon >= 180 {
+		lon -= 360
+	}
+	return lon
+}
+
+func sortNeighbors(ns []Neighbor) {
+	sort.Slice(ns, func(i, j int) bool {
+		if ns[i].DistanceKm == ns[j].DistanceKm {
+			return ns[i].ID < ns[j].ID
+		}
+		return ns[i].DistanceKm < ns[j].DistanceKm
+	})
+}
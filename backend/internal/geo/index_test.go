@@ -0,0 +1,215 @@
+package geo
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestGeohash(t *testing.T) {
+	// Reference values from the original geohash.org implementation.
+	if got := Geohash(57.64911, 10.40744, 11); got != "u4pruydqqvj" {
+		t.Fatalf("unexpected geohash %s", got)
+	}
+	if got := Geohash(-25.382708, -49.265506, 6); got != "6gkzwg" {
+		t.Fatalf("unexpected geohash %s", got)
+	}
+}
+
+func randomPoints(n int, seed int64, lat, lon, spread float64) []Point {
+	rng := rand.New(rand.NewSource(seed))
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = Point{
+			ID:  fmt.Sprintf("p%d", i),
+			Lat: lat + (rng.Float64()*2-1)*spread,
+			Lon: normalizeLon(lon + (rng.Float64()*2-1)*spread),
+		}
+	}
+	return points
+}
+
+func linearWithinRadius(points []Point, lat, lon, radiusKm float64) []Neighbor {
+	var out []Neighbor
+	for _, p := range points {
+		if d := DistanceBetween(lat, lon, p.Lat, p.Lon); d <= radiusKm {
+			out = append(out, Neighbor{Point: p, DistanceKm: d})
+		}
+	}
+	sortNeighbors(out)
+	return out
+}
+
+func sameNeighbors(a, b []Neighbor) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndexMatchesLinearScan(t *testing.T) {
+	tests := []struct {
+		name           string
+		lat, lon       float64
+		spread, radius float64
+	}{
+		{"city", 19.43, -99.13, 0.5, 3},
+		{"antimeridian", -17.7, 179.9, 0.5, 20},
+		{"near pole", 89.5, 0, 0.4, 40},
+		{"continental", 40, -3, 10, 500},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// 1.- Load the same random points into the index and a plain slice.
+			points := randomPoints(2000, 42, tc.lat, tc.lon, tc.spread)
+			idx := NewIndex(6)
+			for _, p := range points {
+				idx.Insert(p)
+			}
+
+			// 2.- Radius and k-nearest answers must agree with brute force.
+			want := linearWithinRadius(points, tc.lat, tc.lon, tc.radius)
+			if got := idx.WithinRadius(tc.lat, tc.lon, tc.radius); !sameNeighbors(got, want) {
+				t.Fatalf("radius mismatch: got %d want %d", len(got), len(want))
+			}
+			all := linearWithinRadius(points, tc.lat, tc.lon, 1e9)
+			if got := idx.Nearest(tc.lat, tc.lon, 10); !sameNeighbors(got, all[:10]) {
+				t.Fatalf("nearest mismatch: got %+v want %+v", got, all[:10])
+			}
+		})
+	}
+}
+
+func TestIndexUpdateAndRemove(t *testing.T) {
+	idx := NewIndex(7)
+	idx.Insert(Point{ID: "d1", Lat: 0, Lon: 0})
+	idx.Update(Point{ID: "d1", Lat: 10, Lon: 10})
+
+	if got := idx.WithinRadius(0, 0, 5); len(got) != 0 {
+		t.Fatalf("expected moved point to leave its old cell, got %+v", got)
+	}
+	if got := idx.WithinRadius(10, 10, 5); len(got) != 1 {
+		t.Fatalf("expected moved point at new location, got %+v", got)
+	}
+	if !idx.Remove("d1") || idx.Remove("d1") {
+		t.Fatalf("expected remove to succeed exactly once")
+	}
+	if idx.Len() != 0 || len(idx.Nearest(0, 0, 1)) != 0 {
+		t.Fatalf("expected empty index")
+	}
+}
+
+func TestIndexConcurrentWriters(t *testing.T) {
+	idx := NewIndex(6)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i, p := range randomPoints(500, int64(w), 19.4, -99.1, 0.2) {
+				p.ID = fmt.Sprintf("w%d-%d", w, i)
+				idx.Insert(p)
+				idx.Nearest(p.Lat, p.Lon, 3)
+				if i%2 == 0 {
+					idx.Remove(p.ID)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if idx.Len() != 8*250 {
+		t.Fatalf("expected %d points got %d", 8*250, idx.Len())
+	}
+}
+
+const benchPoints = 100_000
+
+func BenchmarkWithinRadiusLinear(b *testing.B) {
+	points := randomPoints(benchPoints, 1, 19.43, -99.13, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var n int
+		for _, p := range points {
+			if WithinRadius(19.43, -99.13, p.Lat, p.Lon, 5) {
+				n++
+			}
+		}
+	}
+}
+
+func BenchmarkWithinRadiusIndex(b *testing.B) {
+	idx := NewIndex(6)
+	for _, p := range randomPoints(benchPoints, 1, 19.43, -99.13, 1) {
+		idx.Insert(p)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.WithinRadius(19.43, -99.13, 5)
+	}
+}
+
+func BenchmarkNearestLinear(b *testing.B) {
+	points := randomPoints(benchPoints, 1, 19.43, -99.13, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ns := make([]Neighbor, 0, len(points))
+		for _, p := range points {
+			ns = append(ns, Neighbor{Point: p, DistanceKm: DistanceBetween(19.43, -99.13, p.Lat, p.Lon)})
+		}
+		sortNeighbors(ns)
+	}
+}
+
+func BenchmarkNearestIndex(b *testing.B) {
+	idx := NewIndex(6)
+	for _, p := range randomPoints(benchPoints, 1, 19.43, -99.13, 1) {
+		idx.Insert(p)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Nearest(19.43, -99.13, 10)
+	}
+}
+
+func BenchmarkIndexUpdateParallel(b *testing.B) {
+	idx := NewIndex(6)
+	points := randomPoints(benchPoints, 1, 19.43, -99.13, 1)
+	for _, p := range points {
+		idx.Insert(p)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			p := points[i%len(points)]
+			p.Lat += 0.0001
+			idx.Update(p)
+			i++
+		}
+	})
+}
+
+func TestGeohashBounds(t *testing.T) {
+	hash := Geohash(19.4326, -99.1332, 7)
+	b, err := GeohashBounds(hash)
+	if err != nil {
+		t.Fatalf("bounds: %v", err)
+	}
+	if !b.Contains(19.4326, -99.1332) {
+		t.Fatalf("bounds %+v do not contain the encoded point", b)
+	}
+	latDeg, lonDeg := geohashCellSize(7)
+	if mathAbs(b.MaxLat-b.MinLat-latDeg) > 1e-9 || mathAbs(b.MaxLon-b.MinLon-lonDeg) > 1e-9 {
+		t.Fatalf("unexpected cell size %+v", b)
+	}
+	if _, err := GeohashBounds("9g3a"); err == nil {
+		t.Fatalf("expected error for invalid character")
+	}
+}
@@ -0,0 +1,125 @@
+package geo
+
+import "math"
+
+// Bearing returns the initial great-circle heading in degrees [0, 360) from the first coordinate to the second.
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := degreesToRadians(lat1), degreesToRadians(lat2)
+	dLon := degreesToRadians(lon2 - lon1)
+	y := math.Sin(dLon) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLon)
+	return math.Mod(radiansToDegrees(math.Atan2(y, x))+360, 360)
+}
+
+// Intermediate returns the point at fraction f (0..1) along the great circle from a to b.
+func Intermediate(a, b LatLng, f float64) LatLng {
+	d := DistanceBetween(a.Lat, a.Lon, b.Lat, b.Lon) / earthRadiusKm
+	if d == 0 {
+		return a
+	}
+	phi1, lambda1 := degreesToRadians(a.Lat), degreesToRadians(a.Lon)
+	phi2, lambda2 := degreesToRadians(b.Lat), degreesToRadians(b.Lon)
+	wa := math.Sin((1-f)*d) / math.Sin(d)
+	wb := math.Sin(f*d) / math.Sin(d)
+	x := wa*math.Cos(phi1)*math.Cos(lambda1) + wb*math.Cos(phi2)*math.Cos(lambda2)
+	y := wa*math.Cos(phi1)*math.Sin(lambda1) + wb*math.Cos(phi2)*math.Sin(lambda2)
+	z := wa*math.Sin(phi1) + wb*math.Sin(phi2)
+	return LatLng{
+		Lat: radiansToDegrees(math.Atan2(z, math.Hypot(x, y))),
+		Lon: radiansToDegrees(math.Atan2(y, x)),
+	}
+}
+
+// PathLength returns the length of the path in kilometers.
+func PathLength(path []LatLng) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		total += DistanceBetween(path[i-1].Lat, path[i-1].Lon, path[i].Lat, path[i].Lon)
+	}
+	return total
+}
+
+// Along returns the position distanceKm along the path and the heading of the segment it falls on.
+// Distances before the start or past the end clamp to the first or last vertex.
+func Along(path []LatLng, distanceKm float64) (LatLng, float64) {
+	switch len(path) {
+	case 0:
+		return LatLng{}, 0
+	case 1:
+		return path[0], 0
+	}
+	if distanceKm <= 0 {
+		return path[0], Bearing(path[0].Lat, path[0].Lon, path[1].Lat, path[1].Lon)
+	}
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		segment := DistanceBetween(a.Lat, a.Lon, b.Lat, b.Lon)
+		if distanceKm <= segment && segment > 0 {
+			p := Intermediate(a, b, distanceKm/segment)
+			return p, Bearing(p.Lat, p.Lon, b.Lat, b.Lon)
+		}
+		distanceKm -= segment
+	}
+	n := len(path)
+	return path[n-1], Bearing(path[n-2].Lat, path[n-2].Lon, path[n-1].Lat, path[n-1].Lon)
+}
+
+// Simplify reduces the path with Douglas–Peucker, dropping vertices closer than toleranceMeters
+// to the simplified line. The first and last vertices are always kept.
+func Simplify(path []LatLng, toleranceMeters float64) []LatLng {
+	if len(path) < 3 || toleranceMeters <= 0 {
+		return append([]LatLng(nil), path...)
+	}
+	keep := make([]bool, len(path))
+	keep[0], keep[len(path)-1] = true, true
+
+	// 1.- Walk the segments iteratively so very long paths cannot exhaust the stack.
+	stack := [][2]int{{0, len(path) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		first, last := span[0], span[1]
+		farthest, maxDist := -1, toleranceMeters
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistanceMeters(path[i], path[first], path[last]); d > maxDist {
+				farthest, maxDist = i, d
+			}
+		}
+		if farthest < 0 {
+			continue
+		}
+		keep[farthest] = true
+		stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+	}
+
+	// 2.- Collect the surviving vertices in their original order.
+	out := make([]LatLng, 0, len(path))
+	for i, p := range path {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// segmentDistanceMeters projects onto a local equirectangular plane around a and
+// returns the distance from p to the segment ab, which is accurate at route scale.
+func segmentDistanceMeters(p, a, b LatLng) float64 {
+	const metersPerDegree = earthRadiusKm * 1000 * math.Pi / 180
+	cosLat := math.Cos(degreesToRadians(a.Lat))
+	project := func(q LatLng) (float64, float64) {
+		return normalizeLon(q.Lon-a.Lon) * cosLat * metersPerDegree, (q.Lat - a.Lat) * metersPerDegree
+	}
+	px, py := project(p)
+	bx, by := project(b)
+	lengthSq := bx*bx + by*by
+	if lengthSq == 0 {
+		return math.Hypot(px, py)
+	}
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/lengthSq))
+	return math.Hypot(px-t*bx, py-t*by)
+}
+
+func radiansToDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,68 @@
+package geo
+
+import "testing"
+
+func TestBearing(t *testing.T) {
+	tests := []struct {
+		name       string
+		lat1, lon1 float64
+		lat2, lon2 float64
+		expected   float64
+	}{
+		{"north", 0, 0, 1, 0, 0},
+		{"east", 0, 0, 0, 1, 90},
+		{"south", 1, 0, 0, 0, 180},
+		{"west", 0, 1, 0, 0, 270},
+		{"across antimeridian", 0, 179.5, 0, -179.5, 90},
+		{"london to paris", 51.5074, -0.1278, 48.8566, 2.3522, 148.1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Bearing(tc.lat1, tc.lon1, tc.lat2, tc.lon2); mathAbs(got-tc.expected) > 0.1 {
+				t.Fatalf("expected %.1f got %.1f", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestAlong(t *testing.T) {
+	path := []LatLng{{0, 0}, {0, 1}, {1, 1}}
+	length := PathLength(path)
+	if mathAbs(length-222.4) > 0.5 {
+		t.Fatalf("unexpected path length %.1f", length)
+	}
+
+	mid, heading := Along(path, length/4)
+	if mathAbs(mid.Lat) > 1e-6 || mathAbs(mid.Lon-0.5) > 1e-3 || mathAbs(heading-90) > 0.1 {
+		t.Fatalf("expected midpoint of first leg heading east, got %v %.1f", mid, heading)
+	}
+	corner, heading := Along(path, length*0.75)
+	if mathAbs(corner.Lat-0.5) > 1e-3 || mathAbs(corner.Lon-1) > 1e-6 || mathAbs(heading) > 0.1 {
+		t.Fatalf("expected midpoint of second leg heading north, got %v %.1f", corner, heading)
+	}
+	end, _ := Along(path, length*2)
+	if end != path[2] {
+		t.Fatalf("expected clamp to last vertex, got %v", end)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	// A straight street with GPS jitter of ~5 m and one real 90° turn.
+	path := []LatLng{
+		{19.4000, -99.1000}, {19.40004, -99.0990}, {19.39997, -99.0980}, {19.40003, -99.0970},
+		{19.4000, -99.0960}, {19.4010, -99.09603}, {19.4020, -99.09598}, {19.4030, -99.0960},
+	}
+	simplified := Simplify(path, 10)
+	expected := []LatLng{path[0], path[4], path[7]}
+	if len(simplified) != len(expected) {
+		t.Fatalf("expected %d vertices got %v", len(expected), simplified)
+	}
+	for i := range expected {
+		if simplified[i] != expected[i] {
+			t.Fatalf("vertex %d: expected %v got %v", i, expected[i], simplified[i])
+		}
+	}
+	if got := Simplify(path, 1); len(got) != len(path) {
+		t.Fatalf("expected tight tolerance to keep every vertex, got %d", len(got))
+	}
+}
@@ -0,0 +1,93 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// ErrInvalidPolyline occurs when an encoded polyline is truncated or malformed.
+var ErrInvalidPolyline = errors.New("invalid encoded polyline")
+
+// EncodePolyline encodes the path with Google's polyline algorithm at precision 5.
+func EncodePolyline(path []LatLng) string {
+	return EncodePolylinePrecision(path, 5)
+}
+
+// DecodePolyline decodes a Google precision-5 polyline.
+func DecodePolyline(encoded string) ([]LatLng, error) {
+	return DecodePolylinePrecision(encoded, 5)
+}
+
+// EncodePolylinePrecision encodes the path keeping the given number of decimal places (5 for Google, 6 for OSRM).
+func EncodePolylinePrecision(path []LatLng, precision int) string {
+	factor := math.Pow10(precision)
+	var b strings.Builder
+	var prevLat, prevLon int64
+	for _, p := range path {
+		// 1.- Round to fixed point and emit the delta from the previous vertex.
+		lat := int64(math.Round(p.Lat * factor))
+		lon := int64(math.Round(p.Lon * factor))
+		encodeValue(&b, lat-prevLat)
+		encodeValue(&b, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return b.String()
+}
+
+// DecodePolylinePrecision decodes a polyline encoded with the given number of decimal places.
+func DecodePolylinePrecision(encoded string, precision int) ([]LatLng, error) {
+	factor := math.Pow10(precision)
+	var path []LatLng
+	var lat, lon int64
+	for i := 0; i < len(encoded); {
+		dLat, next, err := decodeValue(encoded, i)
+		if err != nil {
+			return nil, err
+		}
+		dLon, next, err := decodeValue(encoded, next)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+		lat += dLat
+		lon += dLon
+		path = append(path, LatLng{Lat: float64(lat) / factor, Lon: float64(lon) / factor})
+	}
+	return path, nil
+}
+
+func encodeValue(b *strings.Builder, v int64) {
+	// 1.- Zig-zag the sign into the low bit, then emit 5-bit chunks with a continuation flag.
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	b.WriteByte(byte(u + 63))
+}
+
+func decodeValue(encoded string, i int) (int64, int, error) {
+	var result uint64
+	for shift := uint(0); ; shift += 5 {
+		if i >= len(encoded) || shift > 60 {
+			return 0, 0, ErrInvalidPolyline
+		}
+		c := int(encoded[i]) - 63
+		i++
+		if c < 0 || c > 0x3f {
+			return 0, 0, ErrInvalidPolyline
+		}
+		result |= uint64(c&0x1f) << shift
+		if c < 0x20 {
+			break
+		}
+	}
+	if result&1 != 0 {
+		return int64(^(result >> 1)), i, nil
+	}
+	return int64(result >> 1), i, nil
+}
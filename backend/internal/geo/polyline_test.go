@@ -0,0 +1,97 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEncodePolylineGoogleExample(t *testing.T) {
+	path := []LatLng{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}}
+	const expected = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+	if got := EncodePolyline(path); got != expected {
+		t.Fatalf("expected %q got %q", expected, got)
+	}
+	decoded, err := DecodePolyline(expected)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(decoded) != len(path) {
+		t.Fatalf("expected %d points got %d", len(path), len(decoded))
+	}
+	for i := range path {
+		if mathAbs(decoded[i].Lat-path[i].Lat) > 1e-9 || mathAbs(decoded[i].Lon-path[i].Lon) > 1e-9 {
+			t.Fatalf("point %d: expected %v got %v", i, path[i], decoded[i])
+		}
+	}
+}
+
+func TestDecodePolylineErrors(t *testing.T) {
+	for _, encoded := range []string{"_p~iF", "_p~iF~ps|", "\x01\x02", "~~~~~~~~~~~~~~~~~~"} {
+		if _, err := DecodePolyline(encoded); !errors.Is(err, ErrInvalidPolyline) {
+			t.Fatalf("%q: expected invalid polyline got %v", encoded, err)
+		}
+	}
+}
+
+func FuzzPolylineRoundTrip(f *testing.F) {
+	f.Add(38.5, -120.2, 40.7, -120.95, 6)
+	f.Add(-89.99999, 179.99999, 89.99999, -179.99999, 5)
+	f.Add(0.0, 0.0, 0.0, 0.0, 5)
+	f.Fuzz(func(t *testing.T, lat1, lon1, lat2, lon2 float64, precision int) {
+		for _, v := range []float64{lat1, lon1, lat2, lon2} {
+			if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v) > 180 {
+				t.Skip()
+			}
+		}
+		precision = 5 + (precision & 1)
+		path := []LatLng{{lat1, lon1}, {lat2, lon2}}
+		encoded := EncodePolylinePrecision(path, precision)
+		decoded, err := DecodePolylinePrecision(encoded, precision)
+		if err != nil {
+			t.Fatalf("decode %q: %v", encoded, err)
+		}
+		if len(decoded) != len(path) {
+			t.Fatalf("expected %d points got %d", len(path), len(decoded))
+		}
+		tolerance := 0.5/math.Pow10(precision) + 1e-9
+		for i := range path {
+			if math.Abs(decoded[i].Lat-path[i].Lat) > tolerance || math.Abs(decoded[i].Lon-path[i].Lon) > tolerance {
+				t.Fatalf("point %d: expected %v got %v", i, path[i], decoded[i])
+			}
+		}
+		if again := EncodePolylinePrecision(decoded, precision); again != encoded {
+			t.Fatalf("re-encoding changed the polyline: %q vs %q", encoded, again)
+		}
+	})
+}
+
+func FuzzDecodePolyline(f *testing.F) {
+	f.Add("_p~iF~ps|U_ulLnnqC_mqNvxq`@")
+	f.Add("??")
+	f.Add("")
+	f.Fuzz(func(t *testing.T, encoded string) {
+		decoded, err := DecodePolyline(encoded)
+		if err != nil {
+			return
+		}
+		for _, p := range decoded {
+			if math.Abs(p.Lat) > 360 || math.Abs(p.Lon) > 360 {
+				t.Skip("deltas beyond coordinate range lose float precision")
+			}
+		}
+		// A decodable string must survive a second encode/decode unchanged.
+		again, err := DecodePolyline(EncodePolyline(decoded))
+		if err != nil {
+			t.Fatalf("re-decode: %v", err)
+		}
+		if len(again) != len(decoded) {
+			t.Fatalf("expected %d points got %d", len(decoded), len(again))
+		}
+		for i := range decoded {
+			if again[i] != decoded[i] {
+				t.Fatalf("point %d: expected %v got %v", i, decoded[i], again[i])
+			}
+		}
+	})
+}
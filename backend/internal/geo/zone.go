@@ -0,0 +1,228 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Zone kinds with built-in meaning; other kinds are carried as tags only.
+const (
+	ZoneKindService  = "service"
+	ZoneKindNoPickup = "no_pickup"
+	ZoneKindAirport  = "airport"
+)
+
+// Ring is a closed sequence of [lon, lat] positions in GeoJSON order.
+type Ring [][2]float64
+
+// Polygon holds an outer ring followed by zero or more holes.
+type Polygon []Ring
+
+// MultiPolygon is a union of polygons.
+type MultiPolygon []Polygon
+
+// Contains reports whether the point lies inside the ring using the even-odd rule.
+func (r Ring) Contains(lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		xi, yi := r[i][0], r[i][1]
+		xj, yj := r[j][0], r[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Contains reports whether the point is inside the outer ring and outside every hole.
+func (p Polygon) Contains(lat, lon float64) bool {
+	if len(p) == 0 || !p[0].Contains(lat, lon) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.Contains(lat, lon) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains reports whether any member polygon contains the point.
+func (m MultiPolygon) Contains(lat, lon float64) bool {
+	for _, p := range m {
+		if p.Contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bounds returns the bounding box of every outer ring.
+func (m MultiPolygon) Bounds() BoundingBox {
+	b := BoundingBox{MinLat: math.Inf(1), MinLon: math.Inf(1), MaxLat: math.Inf(-1), MaxLon: math.Inf(-1)}
+	for _, p := range m {
+		if len(p) == 0 {
+			continue
+		}
+		for _, pos := range p[0] {
+			b.MinLon, b.MaxLon = math.Min(b.MinLon, pos[0]), math.Max(b.MaxLon, pos[0])
+			b.MinLat, b.MaxLat = math.Min(b.MinLat, pos[1]), math.Max(b.MaxLat, pos[1])
+		}
+	}
+	return b
+}
+
+// Zone is a named geofence such as a service area or an airport pickup lot.
+type Zone struct {
+	ID       string
+	Kind     string
+	Name     string
+	Geometry MultiPolygon
+	bounds   BoundingBox
+}
+
+// Contains reports whether the point falls inside the zone.
+func (z Zone) Contains(lat, lon float64) bool {
+	return z.bounds.Contains(lat, lon) && z.Geometry.Contains(lat, lon)
+}
+
+// ZoneSet is an immutable collection of zones.
+type ZoneSet struct {
+	zones []Zone
+}
+
+// NewZoneSet builds a set from zones, precomputing their bounding boxes.
+func NewZoneSet(zones []Zone) *ZoneSet {
+	out := make([]Zone, len(zones))
+	for i, z := range zones {
+		z.bounds = z.Geometry.Bounds()
+		out[i] = z
+	}
+	return &ZoneSet{zones: out}
+}
+
+// ZonesAt returns every zone containing the point.
+func (s *ZoneSet) ZonesAt(lat, lon float64) []Zone {
+	if s == nil {
+		return nil
+	}
+	var out []Zone
+	for _, z := range s.zones {
+		if z.Contains(lat, lon) {
+			out = append(out, z)
+		}
+	}
+	return out
+}
+
+// HasKind reports whether any zone of the given kind is defined.
+func (s *ZoneSet) HasKind(kind string) bool {
+	if s == nil {
+		return false
+	}
+	for _, z := range s.zones {
+		if z.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of zones.
+func (s *ZoneSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.zones)
+}
+
+// LoadZonesGeoJSON parses a FeatureCollection of Polygon/MultiPolygon features.
+// Each feature needs an "id" property and may carry "kind" and "name".
+func LoadZonesGeoJSON(r io.Reader) (*ZoneSet, error) {
+	var doc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Properties struct {
+				ID   string `json:"id"`
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"properties"`
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode geojson: %w", err)
+	}
+	if doc.Type != "FeatureCollection" {
+		return nil, errors.New("geojson: expected a FeatureCollection")
+	}
+
+	zones := make([]Zone, 0, len(doc.Features))
+	seen := make(map[string]bool)
+	for i, f := range doc.Features {
+		// 1.- Require unique identifiers so trips can be tagged unambiguously.
+		id := f.Properties.ID
+		if id == "" {
+			return nil, fmt.Errorf("geojson feature %d: missing id property", i)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("geojson feature %q: duplicate id", id)
+		}
+		seen[id] = true
+
+		// 2.- Decode the geometry into a MultiPolygon regardless of its declared type.
+		var geometry MultiPolygon
+		switch f.Geometry.Type {
+		case "Polygon":
+			var p Polygon
+			if err := json.Unmarshal(f.Geometry.Coordinates, &p); err != nil {
+				return nil, fmt.Errorf("geojson feature %q: %w", id, err)
+			}
+			geometry = MultiPolygon{p}
+		case "MultiPolygon":
+			if err := json.Unmarshal(f.Geometry.Coordinates, &geometry); err != nil {
+				return nil, fmt.Errorf("geojson feature %q: %w", id, err)
+			}
+		default:
+			return nil, fmt.Errorf("geojson feature %q: unsupported geometry %q", id, f.Geometry.Type)
+		}
+		if err := validateGeometry(geometry); err != nil {
+			return nil, fmt.Errorf("geojson feature %q: %w", id, err)
+		}
+
+		kind := f.Properties.Kind
+		if kind == "" {
+			kind = ZoneKindService
+		}
+		zones = append(zones, Zone{ID: id, Kind: kind, Name: f.Properties.Name, Geometry: geometry})
+	}
+	return NewZoneSet(zones), nil
+}
+
+func validateGeometry(m MultiPolygon) error {
+	if len(m) == 0 {
+		return errors.New("empty geometry")
+	}
+	for _, p := range m {
+		if len(p) == 0 {
+			return errors.New("polygon without rings")
+		}
+		for _, ring := range p {
+			if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+				return errors.New("rings must be closed and have at least four positions")
+			}
+			for _, pos := range ring {
+				if pos[0] < -180 || pos[0] > 180 || pos[1] < -90 || pos[1] > 90 {
+					return errors.New("position out of range")
+				}
+			}
+		}
+	}
+	return nil
+}
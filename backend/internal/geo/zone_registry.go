@@ -0,0 +1,83 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ZoneRegistry serves the current zone set and reloads it when the backing file changes.
+type ZoneRegistry struct {
+	path    string
+	current atomic.Pointer[ZoneSet]
+	modTime atomic.Int64
+	logger  *slog.Logger
+}
+
+// NewZoneRegistry loads the GeoJSON file at path and returns a registry serving it.
+func NewZoneRegistry(path string, logger *slog.Logger) (*ZoneRegistry, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r := &ZoneRegistry{path: path, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ZonesAt returns the zones containing the point in the current set.
+func (r *ZoneRegistry) ZonesAt(lat, lon float64) []Zone {
+	return r.current.Load().ZonesAt(lat, lon)
+}
+
+// HasKind reports whether the current set defines a zone of the given kind.
+func (r *ZoneRegistry) HasKind(kind string) bool {
+	return r.current.Load().HasKind(kind)
+}
+
+// Reload parses the file again and swaps it in atomically; the old set stays active on error.
+func (r *ZoneRegistry) Reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("stat zones: %w", err)
+	}
+	f, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("open zones: %w", err)
+	}
+	defer f.Close()
+	set, err := LoadZonesGeoJSON(f)
+	if err != nil {
+		return fmt.Errorf("load zones %s: %w", r.path, err)
+	}
+	r.current.Store(set)
+	r.modTime.Store(info.ModTime().UnixNano())
+	return nil
+}
+
+// Watch polls the file every interval and reloads it when its modification time changes.
+func (r *ZoneRegistry) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(r.path)
+			if err != nil || info.ModTime().UnixNano() == r.modTime.Load() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				r.logger.WarnContext(ctx, "zone reload failed, keeping previous zones", "path", r.path, "error", err)
+				r.modTime.Store(info.ModTime().UnixNano())
+				continue
+			}
+			r.logger.InfoContext(ctx, "reloaded zones", "path", r.path, "zones", r.current.Load().Len())
+		}
+	}
+}
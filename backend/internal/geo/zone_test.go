@@ -0,0 +1,122 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const zonesFixture = `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "properties": {"id": "cdmx", "kind": "service", "name": "Mexico City"},
+     "geometry": {"type": "Polygon", "coordinates": [
+       [[-99.3, 19.2], [-98.9, 19.2], [-98.9, 19.6], [-99.3, 19.6], [-99.3, 19.2]],
+       [[-99.2, 19.3], [-99.1, 19.3], [-99.1, 19.4], [-99.2, 19.4], [-99.2, 19.3]]
+     ]}},
+    {"type": "Feature", "properties": {"id": "mex-airport", "kind": "airport"},
+     "geometry": {"type": "MultiPolygon", "coordinates": [
+       [[[-99.08, 19.42], [-99.05, 19.42], [-99.05, 19.45], [-99.08, 19.45], [-99.08, 19.42]]],
+       [[[-99.0, 19.5], [-98.95, 19.5], [-98.95, 19.55], [-99.0, 19.55], [-99.0, 19.5]]]
+     ]}}
+  ]
+}`
+
+func zoneIDs(zones []Zone) string {
+	ids := make([]string, len(zones))
+	for i, z := range zones {
+		ids[i] = z.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestZoneSetZonesAt(t *testing.T) {
+	set, err := LoadZonesGeoJSON(strings.NewReader(zonesFixture))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	tests := []struct {
+		name     string
+		lat, lon float64
+		expected string
+	}{
+		{"inside service area", 19.5, -99.25, "cdmx"},
+		{"inside hole", 19.35, -99.15, ""},
+		{"outside everything", 20, -99, ""},
+		{"first airport polygon", 19.43, -99.06, "cdmx,mex-airport"},
+		{"second airport polygon", 19.52, -98.97, "cdmx,mex-airport"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := zoneIDs(set.ZonesAt(tc.lat, tc.lon)); got != tc.expected {
+				t.Fatalf("expected %q got %q", tc.expected, got)
+			}
+		})
+	}
+	if !set.HasKind(ZoneKindAirport) || set.HasKind(ZoneKindNoPickup) {
+		t.Fatalf("unexpected kinds in set")
+	}
+}
+
+func TestLoadZonesGeoJSONErrors(t *testing.T) {
+	tests := map[string]string{
+		"not a collection": `{"type": "Feature"}`,
+		"missing id":       `{"type": "FeatureCollection", "features": [{"properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[0,0],[1,0],[1,1],[0,0]]]}}]}`,
+		"open ring":        `{"type": "FeatureCollection", "features": [{"properties": {"id": "a"}, "geometry": {"type": "Polygon", "coordinates": [[[0,0],[1,0],[1,1],[0,1]]]}}]}`,
+		"unsupported":      `{"type": "FeatureCollection", "features": [{"properties": {"id": "a"}, "geometry": {"type": "Point", "coordinates": [0,0]}}]}`,
+		"duplicate id": `{"type": "FeatureCollection", "features": [
+			{"properties": {"id": "a"}, "geometry": {"type": "Polygon", "coordinates": [[[0,0],[1,0],[1,1],[0,0]]]}},
+			{"properties": {"id": "a"}, "geometry": {"type": "Polygon", "coordinates": [[[0,0],[1,0],[1,1],[0,0]]]}}]}`,
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadZonesGeoJSON(strings.NewReader(doc)); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestZoneRegistryReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.geojson")
+	if err := os.WriteFile(path, []byte(zonesFixture), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	registry, err := NewZoneRegistry(path, nil)
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	if !registry.HasKind(ZoneKindService) {
+		t.Fatalf("expected service zone")
+	}
+
+	// 1.- A broken file must leave the previous zones in place.
+	if err := os.WriteFile(path, []byte(`{"type":`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := registry.Reload(); err == nil {
+		t.Fatalf("expected reload error")
+	}
+	if got := zoneIDs(registry.ZonesAt(19.5, -99.25)); got != "cdmx" {
+		t.Fatalf("expected previous zones to remain, got %q", got)
+	}
+
+	// 2.- A valid replacement is swapped in.
+	replacement := `{"type": "FeatureCollection", "features": [{"properties": {"id": "airport-lot", "kind": "no_pickup"},
+		"geometry": {"type": "Polygon", "coordinates": [[[-99.3,19.2],[-98.9,19.2],[-98.9,19.6],[-99.3,19.2]]]}}]}`
+	if err := os.WriteFile(path, []byte(replacement), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if err := registry.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if registry.HasKind(ZoneKindService) || !registry.HasKind(ZoneKindNoPickup) {
+		t.Fatalf("expected replacement zones")
+	}
+}
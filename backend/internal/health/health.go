@@ -0,0 +1,179 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Check statuses reported per checker and for the whole report.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker probes one dependency; the detail string is surfaced in the report on success.
+type Checker interface {
+	Check(ctx context.Context) (string, error)
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) (string, error)
+
+// Check calls f.
+func (f CheckerFunc) Check(ctx context.Context) (string, error) { return f(ctx) }
+
+// Result is the outcome of one checker.
+type Result struct {
+	Status     string  `json:"status"`
+	Detail     string  `json:"detail,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Report aggregates every checker; Status is ok only when all checks pass.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Healthy reports whether every check passed.
+func (r Report) Healthy() bool { return r.Status == StatusOK }
+
+// Registry holds named checkers that subsystems plug into.
+type Registry struct {
+	mu       sync.RWMutex
+	checkers map[string]Checker
+	timeout  time.Duration
+}
+
+// Option mutates Registry configuration.
+type Option func(*Registry)
+
+// WithTimeout bounds how long each checker may run.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Registry) {
+		if d > 0 {
+			r.timeout = d
+		}
+	}
+}
+
+// NewRegistry constructs an empty registry with a two second per-check timeout.
+func NewRegistry(opts ...Option) *Registry {
+	r := &Registry{checkers: make(map[string]Checker), timeout: 2 * time.Second}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register adds or replaces the checker under name.
+func (r *Registry) Register(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkers[name] = c
+}
+
+// Names lists the registered checkers in order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.checkers))
+	for name := range r.checkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run executes every checker concurrently, each under the registry timeout.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checkers := make(map[string]Checker, len(r.checkers))
+	for name, c := range r.checkers {
+		checkers[name] = c
+	}
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checkers))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, c := range checkers {
+		wg.Add(1)
+		go func(name string, c Checker) {
+			defer wg.Done()
+			result := r.run(ctx, c)
+			mu.Lock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+			mu.Unlock()
+		}(name, c)
+	}
+	wg.Wait()
+	return report
+}
+
+// run executes one checker, abandoning it when the timeout fires so a hung dependency cannot stall the probe.
+func (r *Registry) run(ctx context.Context, c Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	start := time.Now()
+
+	type outcome struct {
+		detail string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		detail, err := c.Check(ctx)
+		done <- outcome{detail, err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		out.err = ctx.Err()
+	}
+	result := Result{Status: StatusOK, Detail: out.detail, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if out.err != nil {
+		result.Status = StatusFail
+		result.Detail = ""
+		result.Error = out.err.Error()
+	}
+	return result
+}
+
+// DBPing checks that the database answers a ping.
+func DBPing(db *sql.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) (string, error) {
+		if err := db.PingContext(ctx); err != nil {
+			return "", fmt.Errorf("ping database: %w", err)
+		}
+		return "", nil
+	})
+}
+
+// ErrSchemaBehind occurs when the applied migration version is older than the binary expects.
+var ErrSchemaBehind = errors.New("schema migrations behind")
+
+// MigrationVersion checks that schema_migrations has reached at least want.
+func MigrationVersion(db *sql.DB, want int) Checker {
+	return CheckerFunc(func(ctx context.Context) (string, error) {
+		var version sql.NullInt64
+		if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+			return "", fmt.Errorf("read migration version: %w", err)
+		}
+		if int(version.Int64) < want {
+			return "", fmt.Errorf("%w: at version %d, want %d", ErrSchemaBehind, version.Int64, want)
+		}
+		return fmt.Sprintf("version %d", version.Int64), nil
+	})
+}
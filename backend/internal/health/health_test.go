@@ -0,0 +1,66 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRegistryRunReportsEveryCheck(t *testing.T) {
+	// 1.- Register a passing, a failing and a hanging checker.
+	r := NewRegistry(WithTimeout(20 * time.Millisecond))
+	r.Register("ok", CheckerFunc(func(context.Context) (string, error) { return "fine", nil }))
+	r.Register("broken", CheckerFunc(func(context.Context) (string, error) { return "", errors.New("boom") }))
+	r.Register("stuck", CheckerFunc(func(context.Context) (string, error) {
+		select {}
+	}))
+
+	// 2.- The hung checker is abandoned at the timeout and the report fails overall.
+	report := r.Run(context.Background())
+	if report.Healthy() || report.Status != StatusFail {
+		t.Fatalf("expected failing report, got %+v", report)
+	}
+	if got := report.Checks["ok"]; got.Status != StatusOK || got.Detail != "fine" {
+		t.Fatalf("unexpected ok result %+v", got)
+	}
+	if got := report.Checks["broken"]; got.Status != StatusFail || got.Error != "boom" {
+		t.Fatalf("unexpected broken result %+v", got)
+	}
+	if got := report.Checks["stuck"]; got.Status != StatusFail || got.Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("unexpected stuck result %+v", got)
+	}
+	if names := r.Names(); len(names) != 3 || names[0] != "broken" {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
+
+func TestDatabaseCheckers(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// 1.- Pings surface driver errors.
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	if _, err := DBPing(db).Check(context.Background()); err == nil {
+		t.Fatalf("expected ping failure")
+	}
+
+	// 2.- The migration check reports the version and flags a schema that lags behind.
+	mock.ExpectQuery("SELECT MAX\\(version\\) FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"v"}).AddRow(3))
+	detail, err := MigrationVersion(db, 3).Check(context.Background())
+	if err != nil || detail != "version 3" {
+		t.Fatalf("unexpected migration result %q %v", detail, err)
+	}
+	mock.ExpectQuery("SELECT MAX\\(version\\) FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"v"}).AddRow(2))
+	if _, err := MigrationVersion(db, 3).Check(context.Background()); !errors.Is(err, ErrSchemaBehind) {
+		t.Fatalf("expected ErrSchemaBehind got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
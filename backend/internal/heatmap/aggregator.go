@@ -0,0 +1,205 @@
+package heatmap
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/geo"
+)
+
+// ErrInvalidResolution occurs when a snapshot asks for a finer grid than the aggregator stores.
+var ErrInvalidResolution = errors.New("invalid heatmap resolution")
+
+// minWeight is the decayed weight below which a cell is forgotten.
+const minWeight = 0.01
+
+// Cell is the decayed demand inside one geohash cell.
+type Cell struct {
+	Geohash    string          `json:"geohash"`
+	Bounds     geo.BoundingBox `json:"bounds"`
+	Requests   float64         `json:"requests"`
+	TripStarts float64         `json:"trip_starts"`
+}
+
+// Weight returns the combined decayed demand in the cell.
+func (c Cell) Weight() float64 {
+	return c.Requests + c.TripStarts
+}
+
+type bucket struct {
+	requests float64
+	starts   float64
+	updated  time.Time
+}
+
+type origin struct {
+	lat, lon float64
+	at       time.Time
+}
+
+// Aggregator buckets demand signals into geohash cells whose weight halves every half-life.
+type Aggregator struct {
+	mu        sync.Mutex
+	precision int
+	halfLife  time.Duration
+	originTTL time.Duration
+	now       func() time.Time
+	cells     map[string]*bucket
+	origins   map[string]origin
+}
+
+// Option mutates Aggregator configuration.
+type Option func(*Aggregator)
+
+// WithHalfLife sets how quickly old demand fades.
+func WithHalfLife(d time.Duration) Option {
+	return func(a *Aggregator) { a.halfLife = d }
+}
+
+// WithPrecision sets the finest geohash precision stored and served.
+func WithPrecision(p int) Option {
+	return func(a *Aggregator) { a.precision = p }
+}
+
+// WithClock injects a time source for tests.
+func WithClock(now func() time.Time) Option {
+	return func(a *Aggregator) { a.now = now }
+}
+
+// NewAggregator builds an aggregator with a 15 minute half-life at geohash precision 7.
+func NewAggregator(opts ...Option) *Aggregator {
+	a := &Aggregator{
+		precision: 7,
+		halfLife:  15 * time.Minute,
+		originTTL: time.Hour,
+		now:       time.Now,
+		cells:     make(map[string]*bucket),
+		origins:   make(map[string]origin),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// RecordRequest counts a rider request at its pickup and remembers the pickup for the trip start.
+func (a *Aggregator) RecordRequest(req contracts.BidRequest) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	a.add(req.Latitude, req.Longitude, now, 1, 0)
+	if req.TripID != "" {
+		a.origins[req.TripID] = origin{lat: req.Latitude, lon: req.Longitude, at: now}
+	}
+}
+
+// RecordTripStart counts a trip start at the pickup remembered from its request.
+// It reports false when the trip's pickup is unknown.
+func (a *Aggregator) RecordTripStart(tripID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	o, ok := a.origins[tripID]
+	if !ok {
+		return false
+	}
+	delete(a.origins, tripID)
+	a.add(o.lat, o.lon, a.now(), 0, 1)
+	return true
+}
+
+// add folds a new signal into its cell. Callers hold a.mu.
+func (a *Aggregator) add(lat, lon float64, now time.Time, requests, starts float64) {
+	key := geo.Geohash(lat, lon, a.precision)
+	b, ok := a.cells[key]
+	if !ok {
+		b = &bucket{updated: now}
+		a.cells[key] = b
+	}
+	a.decay(b, now)
+	b.requests += requests
+	b.starts += starts
+}
+
+// decay ages the bucket to now. Callers hold a.mu.
+func (a *Aggregator) decay(b *bucket, now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 || a.halfLife <= 0 {
+		return
+	}
+	factor := math.Exp2(-float64(elapsed) / float64(a.halfLife))
+	b.requests *= factor
+	b.starts *= factor
+	b.updated = now
+}
+
+// Snapshot rolls cells up to the given geohash resolution, keeping those whose
+// centre falls in bbox (all when nil), ordered by geohash.
+func (a *Aggregator) Snapshot(bbox *geo.BoundingBox, resolution int) ([]Cell, error) {
+	if resolution < 1 || resolution > a.precision {
+		return nil, ErrInvalidResolution
+	}
+	a.mu.Lock()
+	now := a.now()
+	rollup := make(map[string]*Cell)
+	for key, b := range a.cells {
+		// 1.- Age every bucket and forget the ones that have faded out.
+		a.decay(b, now)
+		if b.requests+b.starts < minWeight {
+			delete(a.cells, key)
+			continue
+		}
+		parent := key[:resolution]
+		c, ok := rollup[parent]
+		if !ok {
+			c = &Cell{Geohash: parent}
+			rollup[parent] = c
+		}
+		c.Requests += b.requests
+		c.TripStarts += b.starts
+	}
+	for id, o := range a.origins {
+		if now.Sub(o.at) > a.originTTL {
+			delete(a.origins, id)
+		}
+	}
+	a.mu.Unlock()
+
+	// 2.- Attach cell bounds and apply the bounding box filter.
+	cells := make([]Cell, 0, len(rollup))
+	for _, c := range rollup {
+		bounds, err := geo.GeohashBounds(c.Geohash)
+		if err != nil {
+			return nil, err
+		}
+		c.Bounds = bounds
+		if bbox != nil && !bbox.Contains((bounds.MinLat+bounds.MaxLat)/2, (bounds.MinLon+bounds.MaxLon)/2) {
+			continue
+		}
+		cells = append(cells, *c)
+	}
+	sort.Slice(cells, func(i, j int) bool { return cells[i].Geohash < cells[j].Geohash })
+	return cells, nil
+}
+
+// Publish emits a snapshot every interval until ctx is cancelled, skipping empty snapshots.
+func (a *Aggregator) Publish(ctx context.Context, interval time.Duration, resolution int, publish func([]Cell)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cells, err := a.Snapshot(nil, resolution)
+			if err != nil || len(cells) == 0 {
+				continue
+			}
+			publish(cells)
+		}
+	}
+}
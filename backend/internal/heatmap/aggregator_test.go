@@ -0,0 +1,86 @@
+package heatmap
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/geo"
+)
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func TestAggregatorDecaysAndRollsUp(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	agg := NewAggregator(WithClock(clock.Now), WithHalfLife(10*time.Minute))
+
+	// 1.- Two nearby requests share a coarse cell; one far away lands elsewhere.
+	agg.RecordRequest(contracts.BidRequest{TripID: "t1", Latitude: 19.4326, Longitude: -99.1332})
+	agg.RecordRequest(contracts.BidRequest{TripID: "t2", Latitude: 19.4330, Longitude: -99.1340})
+	agg.RecordRequest(contracts.BidRequest{TripID: "t3", Latitude: 40.7128, Longitude: -74.0060})
+	if !agg.RecordTripStart("t1") {
+		t.Fatalf("expected trip start to resolve the request pickup")
+	}
+	if agg.RecordTripStart("unknown") {
+		t.Fatalf("expected unknown trip to be ignored")
+	}
+
+	cells, err := agg.Snapshot(nil, 5)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if len(cells) != 2 {
+		t.Fatalf("expected 2 cells got %+v", cells)
+	}
+	cdmx := cells[0]
+	if cdmx.Geohash != geo.Geohash(19.4326, -99.1332, 5) {
+		t.Fatalf("unexpected ordering %+v", cells)
+	}
+	if cdmx.Requests != 2 || cdmx.TripStarts != 1 {
+		t.Fatalf("expected 2 requests and 1 start, got %+v", cdmx)
+	}
+	if !cdmx.Bounds.Contains(19.4326, -99.1332) {
+		t.Fatalf("cell bounds %+v do not contain the pickup", cdmx.Bounds)
+	}
+
+	// 2.- One half-life later the weights have halved and the bbox filter narrows the result.
+	clock.now = clock.now.Add(10 * time.Minute)
+	box := geo.BoundingBox{MinLat: 19, MinLon: -100, MaxLat: 20, MaxLon: -99}
+	cells, err = agg.Snapshot(&box, 5)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if len(cells) != 1 || math.Abs(cells[0].Weight()-1.5) > 1e-9 {
+		t.Fatalf("expected a single decayed cell of weight 1.5, got %+v", cells)
+	}
+
+	// 3.- Long idle periods forget the cells entirely.
+	clock.now = clock.now.Add(3 * time.Hour)
+	if cells, _ := agg.Snapshot(nil, 5); len(cells) != 0 {
+		t.Fatalf("expected faded cells to be dropped, got %+v", cells)
+	}
+	if _, err := agg.Snapshot(nil, 9); err != ErrInvalidResolution {
+		t.Fatalf("expected invalid resolution got %v", err)
+	}
+}
+
+func TestGeoJSON(t *testing.T) {
+	bounds, err := geo.GeohashBounds("9g3qx")
+	if err != nil {
+		t.Fatalf("bounds: %v", err)
+	}
+	fc := GeoJSON([]Cell{{Geohash: "9g3qx", Bounds: bounds, Requests: 2, TripStarts: 1}})
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 1 {
+		t.Fatalf("unexpected collection %+v", fc)
+	}
+	ring := fc.Features[0].Geometry.Coordinates[0]
+	if len(ring) != 5 || ring[0] != ring[4] {
+		t.Fatalf("expected a closed rectangle got %v", ring)
+	}
+	if fc.Features[0].Properties["weight"] != 3.0 {
+		t.Fatalf("unexpected properties %v", fc.Features[0].Properties)
+	}
+}
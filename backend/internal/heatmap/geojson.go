@@ -0,0 +1,41 @@
+package heatmap
+
+// FeatureCollection is the GeoJSON document served for heatmaps.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is one heatmap cell rendered as a polygon.
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON polygon with [lon, lat] positions.
+type Geometry struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// GeoJSON renders the cells as a FeatureCollection of closed rectangles.
+func GeoJSON(cells []Cell) FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0, len(cells))}
+	for _, c := range cells {
+		b := c.Bounds
+		fc.Features = append(fc.Features, Feature{
+			Type: "Feature",
+			Geometry: Geometry{Type: "Polygon", Coordinates: [][][2]float64{{
+				{b.MinLon, b.MinLat}, {b.MaxLon, b.MinLat}, {b.MaxLon, b.MaxLat}, {b.MinLon, b.MaxLat}, {b.MinLon, b.MinLat},
+			}}},
+			Properties: map[string]interface{}{
+				"geohash":     c.Geohash,
+				"weight":      c.Weight(),
+				"requests":    c.Requests,
+				"trip_starts": c.TripStarts,
+			},
+		})
+	}
+	return fc
+}
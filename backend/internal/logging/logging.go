@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// Attribute keys added to every record whose context carries them.
+const (
+	KeyRequestID = "request_id"
+	KeyTripID    = "trip_id"
+	KeyPrincipal = "principal"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	tripIDKey
+	principalKey
+)
+
+// New builds a JSON logger whose threshold follows level, enriched with correlation ids from the context.
+func New(w io.Writer, level *slog.LevelVar) *slog.Logger {
+	return slog.New(&contextHandler{Handler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// OrDefault returns logger, or slog.Default when it is nil.
+func OrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// WithRequestID stores the request correlation id on the context.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the correlation id stored on the context.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithTripID stores the trip a code path works on.
+func WithTripID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tripIDKey, id)
+}
+
+// WithPrincipal stores the authenticated principal id.
+func WithPrincipal(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, principalKey, id)
+}
+
+// contextHandler copies correlation ids from the record's context into its attributes.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	for _, kv := range []struct {
+		key   string
+		value contextKey
+	}{{KeyRequestID, requestIDKey}, {KeyTripID, tripIDKey}, {KeyPrincipal, principalKey}} {
+		if v, ok := ctx.Value(kv.value).(string); ok && v != "" {
+			r.AddAttrs(slog.String(kv.key, v))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
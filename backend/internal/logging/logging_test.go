@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record map[string]interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestContextAttributesAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	logger := New(&buf, level).With("component", "test")
+
+	// 1.- Correlation ids stored on the context appear on every record.
+	ctx := WithPrincipal(WithTripID(WithRequestID(context.Background(), "req-1"), "trip-1"), "rider-1")
+	logger.InfoContext(ctx, "hello")
+	logger.DebugContext(ctx, "hidden")
+
+	// 2.- Raising verbosity at runtime takes effect immediately.
+	level.Set(slog.LevelDebug)
+	logger.DebugContext(context.Background(), "visible")
+
+	records := decodeLines(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("expected two records got %v", records)
+	}
+	first := records[0]
+	if first[KeyRequestID] != "req-1" || first[KeyTripID] != "trip-1" || first[KeyPrincipal] != "rider-1" || first["component"] != "test" {
+		t.Fatalf("missing context attributes: %v", first)
+	}
+	if _, ok := records[1][KeyRequestID]; ok || records[1]["msg"] != "visible" {
+		t.Fatalf("unexpected second record %v", records[1])
+	}
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	router := gin.New()
+	router.Use(Middleware(), AccessLog(New(&buf, new(slog.LevelVar))))
+	var seen string
+	router.GET("/trips/:id", func(c *gin.Context) {
+		seen = RequestID(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"accepted", "abc-123", true},
+		{"generated", "", false},
+		{"replaced when malformed", "bad id\nwith newline", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/trips/t1", nil)
+			if tc.incoming != "" {
+				req.Header.Set(RequestIDHeader, tc.incoming)
+			}
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+
+			// 1.- The handler and the response agree on the id.
+			echoed := res.Header().Get(RequestIDHeader)
+			if echoed == "" || echoed != seen {
+				t.Fatalf("expected echoed id %q to match context id %q", echoed, seen)
+			}
+			if tc.keep != (echoed == tc.incoming) {
+				t.Fatalf("incoming %q echoed %q", tc.incoming, echoed)
+			}
+		})
+	}
+
+	// 2.- The access log carries the id and the route template.
+	records := decodeLines(t, &buf)
+	if len(records) != len(tests) || records[0][KeyRequestID] != "abc-123" || records[0]["route"] != "/trips/:id" {
+		t.Fatalf("unexpected access log %v", records)
+	}
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the correlation id in requests and responses.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID bounds caller-supplied ids so they cannot inject into logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Middleware accepts a well-formed X-Request-ID or generates one, stores it on the
+// request context and echoes it in the response.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// AccessLog writes one record per request once the handler chain has finished.
+func AccessLog(logger *slog.Logger) gin.HandlerFunc {
+	logger = OrDefault(logger)
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// 1.- Server errors warn; everything else is informational.
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "http request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("elapsed", time.Since(start)),
+		)
+	}
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/ws"
+)
+
+const namespace = "kage"
+
+// Prometheus implements the api, bidding, trip and ws recorders on a private registry.
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	httpDuration       *prometheus.HistogramVec
+	evaluationDuration *prometheus.HistogramVec
+	candidates         prometheus.Histogram
+	tripTransitions    *prometheus.CounterVec
+	activeTrips        prometheus.Gauge
+	wsConnections      *prometheus.GaugeVec
+	wsDropped          *prometheus.CounterVec
+}
+
+// New registers every collector plus the Go runtime and process collectors.
+func New() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+			Help:    "HTTP request latency by method, route template and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		evaluationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "bidding", Name: "evaluation_duration_seconds",
+			Help:    "RankAndSelect latency by outcome; the count is the number of evaluations.",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"outcome"}),
+		candidates: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "bidding", Name: "candidates_after_filter",
+			Help:    "Bids remaining after expiry, price, radius and ETA filters.",
+			Buckets: []float64{0, 1, 2, 3, 5, 10, 20, 50},
+		}),
+		tripTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "trip", Name: "transitions_total",
+			Help: "Trip lifecycle transitions by target state.",
+		}, []string{"state"}),
+		activeTrips: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "trip", Name: "active",
+			Help: "Trips currently in the active state.",
+		}),
+		wsConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "ws", Name: "connections",
+			Help: "Open websocket connections by role.",
+		}, []string{"role"}),
+		wsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "ws", Name: "dropped_messages_total",
+			Help: "Messages dropped by backpressure policies by message type.",
+		}, []string{"type"}),
+	}
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		p.httpDuration, p.evaluationDuration, p.candidates,
+		p.tripTransitions, p.activeTrips, p.wsConnections, p.wsDropped,
+	)
+	return p
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{Registry: p.registry})
+}
+
+// Registry exposes the underlying registry for tests and extra collectors.
+func (p *Prometheus) Registry() *prometheus.Registry { return p.registry }
+
+// ObserveRequest implements api.Recorder.
+func (p *Prometheus) ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	p.httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(elapsed.Seconds())
+}
+
+// ObserveEvaluation implements bidding.Recorder.
+func (p *Prometheus) ObserveEvaluation(outcome string, elapsed time.Duration) {
+	p.evaluationDuration.WithLabelValues(outcome).Observe(elapsed.Seconds())
+}
+
+// ObserveCandidates implements bidding.Recorder.
+func (p *Prometheus) ObserveCandidates(remaining int) {
+	p.candidates.Observe(float64(remaining))
+}
+
+// ObserveTransition implements trip.Recorder.
+func (p *Prometheus) ObserveTransition(to contracts.TripState) {
+	p.tripTransitions.WithLabelValues(string(to)).Inc()
+}
+
+// SetActiveTrips implements trip.Recorder.
+func (p *Prometheus) SetActiveTrips(n int) {
+	p.activeTrips.Set(float64(n))
+}
+
+// ConnectionOpened implements ws.Recorder.
+func (p *Prometheus) ConnectionOpened(role ws.Role) {
+	p.wsConnections.WithLabelValues(string(role)).Inc()
+}
+
+// ConnectionClosed implements ws.Recorder.
+func (p *Prometheus) ConnectionClosed(role ws.Role) {
+	p.wsConnections.WithLabelValues(string(role)).Dec()
+}
+
+// MessageDropped implements ws.Recorder.
+func (p *Prometheus) MessageDropped(msgType string) {
+	p.wsDropped.WithLabelValues(msgType).Inc()
+}
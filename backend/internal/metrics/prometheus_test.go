@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"kage/backend/internal/api"
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/contracts"
+	"kage/backend/internal/trip"
+	"kage/backend/internal/ws"
+)
+
+// The adapter must satisfy every subsystem's recorder.
+var (
+	_ api.Recorder     = (*Prometheus)(nil)
+	_ bidding.Recorder = (*Prometheus)(nil)
+	_ trip.Recorder    = (*Prometheus)(nil)
+	_ ws.Recorder      = (*Prometheus)(nil)
+)
+
+func TestPrometheusExposition(t *testing.T) {
+	// 1.- Feed a few observations through the recorder methods.
+	p := New()
+	p.ObserveRequest("GET", "/api/v1/trips/:id/metrics", 200, 20*time.Millisecond)
+	p.ObserveEvaluation(bidding.OutcomeWinner, 5*time.Millisecond)
+	p.ObserveEvaluation(bidding.OutcomeTimeout, 3*time.Second)
+	p.ObserveCandidates(2)
+	p.ObserveTransition(contracts.TripStateActive)
+	p.SetActiveTrips(1)
+	p.ConnectionOpened(ws.RoleDriver)
+	p.ConnectionOpened(ws.RoleDriver)
+	p.ConnectionClosed(ws.RoleDriver)
+	p.MessageDropped("location")
+
+	// 2.- Values are visible through the registry.
+	if got := testutil.ToFloat64(p.tripTransitions.WithLabelValues("active")); got != 1 {
+		t.Fatalf("transitions: got %v", got)
+	}
+	if got := testutil.ToFloat64(p.wsConnections.WithLabelValues("driver")); got != 1 {
+		t.Fatalf("connections: got %v", got)
+	}
+	if got := testutil.CollectAndCount(p.evaluationDuration); got != 2 {
+		t.Fatalf("expected two outcome series got %d", got)
+	}
+
+	// 3.- The handler renders the text format with the labelled series.
+	res := httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(res.Body)
+	for _, want := range []string{
+		`kage_http_request_duration_seconds_count{method="GET",route="/api/v1/trips/:id/metrics",status="200"} 1`,
+		`kage_bidding_evaluation_duration_seconds_count{outcome="timeout"} 1`,
+		`kage_bidding_candidates_after_filter_sum 2`,
+		`kage_trip_active 1`,
+		`kage_ws_dropped_messages_total{type="location"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("exposition missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// Package migrate applies the versioned SQL schema and records it in schema_migrations.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"kage/backend/internal/dialect"
+)
+
+var (
+	// ErrLocked occurs when another process holds the migration lock past the lock timeout.
+	ErrLocked = errors.New("migrations locked by another process")
+	// ErrChecksumMismatch occurs when an applied migration was edited after it ran.
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	// ErrUnknownVersion occurs when the database records a migration this binary does not ship.
+	ErrUnknownVersion = errors.New("database has a migration this binary does not know")
+)
+
+// Bookkeeping tables, created on first use; %s is the dialect's timestamp type.
+const (
+	createVersionTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER      NOT NULL PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    checksum   CHAR(64)     NOT NULL,
+    applied_at %s NOT NULL
+)`
+	createLockTable = `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+    id        INTEGER      NOT NULL PRIMARY KEY,
+    owner     VARCHAR(255) NOT NULL,
+    locked_at %s NOT NULL
+)`
+)
+
+// Status describes one migration as known to the binary and the database.
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool
+}
+
+// Migrator applies and reverts migrations against one database.
+type Migrator struct {
+	db           *sql.DB
+	dialect      dialect.Dialect
+	migrations   []Migration
+	lockTimeout  time.Duration
+	pollInterval time.Duration
+	owner        string
+	now          func() time.Time
+	logger       *slog.Logger
+}
+
+// Option mutates Migrator configuration.
+type Option func(*Migrator)
+
+// WithLockTimeout bounds how long Up and Down wait for another migrator to finish.
+func WithLockTimeout(d time.Duration) Option {
+	return func(m *Migrator) { m.lockTimeout = d }
+}
+
+// WithDialect selects the database syntax for the bookkeeping tables; MySQL is the default.
+func WithDialect(d dialect.Dialect) Option {
+	return func(m *Migrator) {
+		if d != nil {
+			m.dialect = d
+		}
+	}
+}
+
+// WithClock injects the time recorded as applied_at.
+func WithClock(now func() time.Time) Option {
+	return func(m *Migrator) { m.now = now }
+}
+
+// WithLogger reports every applied and reverted migration.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Migrator) {
+		if logger != nil {
+			m.logger = logger
+		}
+	}
+}
+
+// New builds a migrator for migrations, which must be ordered as returned by Load.
+func New(db *sql.DB, migrations []Migration, opts ...Option) *Migrator {
+	host, _ := os.Hostname()
+	m := &Migrator{
+		db:           db,
+		dialect:      dialect.MySQL,
+		migrations:   migrations,
+		lockTimeout:  30 * time.Second,
+		pollInterval: 250 * time.Millisecond,
+		owner:        fmt.Sprintf("%s:%d", host, os.Getpid()),
+		now:          time.Now,
+		logger:       slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Up applies every pending migration in order and returns the ones it ran.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := m.locked(ctx, func(applied map[int]Status) error {
+		for _, mig := range m.migrations {
+			if applied[mig.Version].Applied {
+				continue
+			}
+			if err := m.apply(ctx, mig); err != nil {
+				return err
+			}
+			ran = append(ran, mig)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down reverts up to steps of the most recently applied migrations and returns the ones it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(applied map[int]Status) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := m.migrations[i]
+			if !applied[mig.Version].Applied {
+				continue
+			}
+			if err := m.revert(ctx, mig); err != nil {
+				return err
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration with its applied state, creating the bookkeeping tables if needed.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := applied[mig.Version]
+		st.Version, st.Name = mig.Version, mig.Name
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// Unlock removes a lock left behind by a migrator that crashed.
+func (m *Migrator) Unlock(ctx context.Context) error {
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+	_, err := m.db.ExecContext(ctx, `DELETE FROM schema_migrations_lock WHERE id = 1`)
+	return err
+}
+
+// locked runs fn while holding the migration lock, after verifying the applied history.
+func (m *Migrator) locked(ctx context.Context, fn func(applied map[int]Status) error) error {
+	// 1.- Create the bookkeeping tables and wait for the lock.
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = m.db.ExecContext(context.WithoutCancel(ctx), m.dialect.Rebind(`DELETE FROM schema_migrations_lock WHERE id = 1 AND owner = ?`), m.owner)
+	}()
+
+	// 2.- Refuse to proceed when the recorded history disagrees with the shipped files.
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	known := make(map[int]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		if applied[mig.Version].Modified {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, mig.Version, mig.Name)
+		}
+	}
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: version %d", ErrUnknownVersion, version)
+		}
+	}
+	return fn(applied)
+}
+
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	timestamp := "DATETIME"
+	if m.dialect == dialect.Postgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+	for _, stmt := range []string{createVersionTable, createLockTable} {
+		if _, err := m.db.ExecContext(ctx, fmt.Sprintf(stmt, timestamp)); err != nil {
+			return fmt.Errorf("create migration tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// lock claims the single lock row, polling until the lock timeout while another owner holds it.
+func (m *Migrator) lock(ctx context.Context) error {
+	deadline := time.NewTimer(m.lockTimeout)
+	defer deadline.Stop()
+	for {
+		_, err := m.db.ExecContext(ctx, m.dialect.Rebind(`INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, ?, ?)`), m.owner, m.now().UTC())
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			var holder string
+			_ = m.db.QueryRowContext(ctx, `SELECT owner FROM schema_migrations_lock WHERE id = 1`).Scan(&holder)
+			return fmt.Errorf("%w: held by %q", ErrLocked, holder)
+		case <-time.After(m.pollInterval):
+		}
+	}
+}
+
+// applied reads schema_migrations, flagging rows whose checksum differs from the shipped file.
+func (m *Migrator) applied(ctx context.Context) (map[int]Status, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	checksums := make(map[int]string, len(m.migrations))
+	for _, mig := range m.migrations {
+		checksums[mig.Version] = mig.Checksum
+	}
+	out := make(map[int]Status)
+	for rows.Next() {
+		var (
+			st       = Status{Applied: true}
+			checksum string
+		)
+		if err := rows.Scan(&st.Version, &st.Name, &checksum, &st.AppliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		if want, ok := checksums[st.Version]; ok && want != checksum {
+			st.Modified = true
+		}
+		out[st.Version] = st
+	}
+	return out, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	return m.inTx(ctx, mig, mig.Up, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, m.dialect.Rebind(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`),
+			mig.Version, mig.Name, mig.Checksum, m.now().UTC())
+		return err
+	}, "applied migration")
+}
+
+func (m *Migrator) revert(ctx context.Context, mig Migration) error {
+	return m.inTx(ctx, mig, mig.Down, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, m.dialect.Rebind(`DELETE FROM schema_migrations WHERE version = ?`), mig.Version)
+		return err
+	}, "reverted migration")
+}
+
+// inTx runs body's statements and the bookkeeping change in one transaction.
+// MariaDB commits DDL implicitly, so a failed statement there may leave earlier ones in place.
+func (m *Migrator) inTx(ctx context.Context, mig Migration, body string, record func(*sql.Tx) error, msg string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, stmt := range statements(body) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	if err := record(tx); err != nil {
+		return fmt.Errorf("record migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.logger.InfoContext(ctx, msg, "version", mig.Version, "name", mig.Name)
+	return nil
+}
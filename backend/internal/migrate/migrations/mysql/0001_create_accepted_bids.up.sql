@@ -0,0 +1,9 @@
+CREATE TABLE accepted_bids (
+    bid_id      VARCHAR(64)   NOT NULL PRIMARY KEY,
+    trip_id     VARCHAR(64)   NOT NULL,
+    driver_id   VARCHAR(64)   NOT NULL,
+    price       DECIMAL(12,2) NOT NULL,
+    accepted_at DATETIME(6)   NOT NULL
+);
+
+CREATE INDEX idx_accepted_bids_trip ON accepted_bids (trip_id);
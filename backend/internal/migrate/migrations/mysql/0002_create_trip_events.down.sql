@@ -0,0 +1 @@
+DROP TABLE trip_events;
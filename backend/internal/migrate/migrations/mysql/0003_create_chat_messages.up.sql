@@ -0,0 +1,16 @@
+CREATE TABLE chat_messages (
+    id                VARCHAR(64)  NOT NULL PRIMARY KEY,
+    client_message_id VARCHAR(128) NOT NULL,
+    trip_id           VARCHAR(64)  NOT NULL,
+    sender_id         VARCHAR(64)  NOT NULL,
+    sender_role       VARCHAR(16)  NOT NULL,
+    body              TEXT         NOT NULL,
+    redacted          BOOLEAN      NOT NULL DEFAULT FALSE,
+    sent_at           DATETIME(6)  NOT NULL,
+    received_at       DATETIME(6)  NOT NULL,
+    read_at           DATETIME(6)  NULL
+);
+
+CREATE UNIQUE INDEX uq_chat_messages_client ON chat_messages (trip_id, sender_id, client_message_id);
+
+CREATE INDEX idx_chat_messages_history ON chat_messages (trip_id, received_at);
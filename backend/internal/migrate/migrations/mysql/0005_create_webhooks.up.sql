@@ -0,0 +1,33 @@
+-- Partner subscriptions; events and driver_ids hold comma-separated filters, an empty driver_ids matches every driver.
+CREATE TABLE webhook_subscriptions (
+    id         VARCHAR(64)   NOT NULL PRIMARY KEY,
+    url        VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(128)  NOT NULL,
+    events     TEXT          NOT NULL,
+    driver_ids TEXT          NOT NULL,
+    active     BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_at DATETIME(6)   NOT NULL,
+    updated_at DATETIME(6)   NOT NULL
+);
+
+-- One row per subscription and outbox message doubles as the delivery log.
+CREATE TABLE webhook_deliveries (
+    id              VARCHAR(64) NOT NULL PRIMARY KEY,
+    subscription_id VARCHAR(64) NOT NULL,
+    message_id      VARCHAR(64) NOT NULL,
+    event           VARCHAR(64) NOT NULL,
+    payload         TEXT        NOT NULL,
+    status          VARCHAR(16) NOT NULL,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME(6) NOT NULL,
+    last_status     INTEGER     NULL,
+    last_error      TEXT        NULL,
+    created_at      DATETIME(6) NOT NULL,
+    delivered_at    DATETIME(6) NULL
+);
+
+CREATE UNIQUE INDEX uq_webhook_deliveries_message ON webhook_deliveries (subscription_id, message_id);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+
+CREATE INDEX idx_webhook_deliveries_log ON webhook_deliveries (subscription_id, created_at);
@@ -0,0 +1 @@
+ALTER TABLE accepted_bids DROP COLUMN rider_id;
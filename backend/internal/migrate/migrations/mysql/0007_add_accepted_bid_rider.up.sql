@@ -0,0 +1,2 @@
+-- The rider of the trip, so participant checks survive restarts and work on every replica.
+ALTER TABLE accepted_bids ADD COLUMN rider_id VARCHAR(64) NULL;
@@ -0,0 +1 @@
+DROP TABLE accepted_bids;
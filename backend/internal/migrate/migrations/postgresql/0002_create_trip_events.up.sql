@@ -0,0 +1,8 @@
+-- A trip never enters the same state twice at the same instant, so the natural key suffices.
+CREATE TABLE trip_events (
+    trip_id     VARCHAR(64) NOT NULL,
+    state       VARCHAR(16) NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    notes       TEXT        NOT NULL,
+    PRIMARY KEY (trip_id, occurred_at, state)
+);
@@ -0,0 +1,15 @@
+-- Notifications written in the same transaction as the row that caused them; the relay publishes and marks them.
+CREATE TABLE outbox (
+    id              VARCHAR(64)  NOT NULL PRIMARY KEY,
+    topic           VARCHAR(64)  NOT NULL,
+    message_key     VARCHAR(64)  NOT NULL,
+    payload         TEXT         NOT NULL,
+    created_at      TIMESTAMPTZ  NOT NULL,
+    status          VARCHAR(16)  NOT NULL DEFAULT 'pending',
+    attempts        INTEGER      NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ  NOT NULL,
+    last_error      TEXT         NULL,
+    delivered_at    TIMESTAMPTZ  NULL
+);
+
+CREATE INDEX idx_outbox_due ON outbox (status, next_attempt_at);
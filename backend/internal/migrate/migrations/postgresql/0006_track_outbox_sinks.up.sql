@@ -0,0 +1,2 @@
+-- Comma-separated names of the sinks that already accepted a pending message, so retries skip them.
+ALTER TABLE outbox ADD COLUMN delivered_sinks TEXT NULL;
@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"kage/backend/internal/dialect"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	var perDialect [][]string
+	for _, d := range []dialect.Dialect{dialect.MySQL, dialect.Postgres} {
+		migrations, err := Load(Embedded, Dir(d))
+		if err != nil {
+			t.Fatalf("load %s: %v", d.Name(), err)
+		}
+		if Latest(migrations) != len(migrations) {
+			t.Fatalf("%s: expected contiguous versions got %d migrations up to %d", d.Name(), len(migrations), Latest(migrations))
+		}
+		var names []string
+		for i, m := range migrations {
+			if m.Version != i+1 || len(m.Checksum) != 64 || len(statements(m.Up)) == 0 || len(statements(m.Down)) == 0 {
+				t.Fatalf("%s: unexpected migration %+v", d.Name(), m)
+			}
+			names = append(names, m.Name)
+		}
+		perDialect = append(perDialect, names)
+	}
+
+	// 1.- Every dialect ships the same versions under the same names.
+	if !reflect.DeepEqual(perDialect[0], perDialect[1]) {
+		t.Fatalf("dialects diverge: %v vs %v", perDialect[0], perDialect[1])
+	}
+}
+
+func TestLoadRejectsBrokenSources(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"missing down", fstest.MapFS{"m/0001_a.up.sql": {Data: []byte("SELECT 1;")}}},
+		{"empty up", fstest.MapFS{"m/0001_a.up.sql": {Data: []byte(" ")}, "m/0001_a.down.sql": {Data: []byte("SELECT 1;")}}},
+		{"conflicting names", fstest.MapFS{"m/0001_a.up.sql": {Data: []byte("SELECT 1;")}, "m/0001_b.down.sql": {Data: []byte("SELECT 1;")}}},
+		{"zero version", fstest.MapFS{"m/0000_a.up.sql": {Data: []byte("SELECT 1;")}, "m/0000_a.down.sql": {Data: []byte("SELECT 1;")}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Load(tc.files, "m"); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	body := "-- comment\nCREATE TABLE a (\n  id INT\n);\n\nCREATE INDEX i ON a (id);\nDROP TABLE b"
+	got := statements(body)
+	want := []string{"CREATE TABLE a (\n  id INT\n)", "CREATE INDEX i ON a (id)", "DROP TABLE b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %q got %q", want, got)
+	}
+}
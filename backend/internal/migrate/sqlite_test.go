@@ -0,0 +1,147 @@
+//go:build cgo
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"kage/backend/internal/dialect"
+	"kage/backend/internal/health"
+)
+
+// openSQLite returns a file-backed database so every pooled connection sees the same schema.
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "kage.db")+"?_busy_timeout=1000")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// TestMigrationBookkeepingAgainstSQLite checks Up, Down, status and the readiness check on the
+// MySQL set, which SQLite happens to accept. It says nothing about the repositories' statements;
+// server_test.go runs those against MariaDB and PostgreSQL.
+func TestMigrationBookkeepingAgainstSQLite(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	migrations, err := Load(Embedded, Dir(dialect.MySQL))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	m := New(db, migrations)
+	check := health.MigrationVersion(db, Latest(migrations))
+
+	// 1.- Before any migration the readiness check fails, then Up applies everything once and it passes.
+	if _, err := check.Check(ctx); err == nil {
+		t.Fatalf("expected readiness to fail before schema_migrations exists")
+	}
+	ran, err := m.Up(ctx)
+	if err != nil || len(ran) != len(migrations) {
+		t.Fatalf("expected %d applied got %d: %v", len(migrations), len(ran), err)
+	}
+	if ran, err := m.Up(ctx); err != nil || len(ran) != 0 {
+		t.Fatalf("expected idempotent up got %d: %v", len(ran), err)
+	}
+	if detail, err := check.Check(ctx); err != nil || detail != fmt.Sprintf("version %d", Latest(migrations)) {
+		t.Fatalf("migration check %q: %v", detail, err)
+	}
+
+	// 2.- Down reverts the newest migration only and status reflects it.
+	reverted, err := m.Down(ctx, 1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != Latest(migrations) {
+		t.Fatalf("unexpected down result %v: %v", reverted, err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, st := range statuses {
+		if want := st.Version != Latest(migrations); st.Applied != want {
+			t.Fatalf("unexpected status %+v", st)
+		}
+	}
+	if _, err := check.Check(ctx); !errors.Is(err, health.ErrSchemaBehind) {
+		t.Fatalf("expected schema behind got %v", err)
+	}
+
+	// 3.- Reverting everything leaves only the bookkeeping tables.
+	if _, err := m.Down(ctx, len(migrations)); err != nil {
+		t.Fatalf("down all: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `SELECT 1 FROM accepted_bids`); err == nil {
+		t.Fatalf("expected accepted_bids dropped")
+	}
+}
+
+func TestChecksumAndUnknownVersions(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	files := fstest.MapFS{
+		"m/0001_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER);")},
+		"m/0001_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+	}
+	original, err := Load(files, "m")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if _, err := New(db, original).Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	// 1.- Editing an applied file is detected before anything runs.
+	files["m/0001_widgets.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE widgets (id INTEGER, name TEXT);")}
+	edited, _ := Load(files, "m")
+	if _, err := New(db, edited).Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch got %v", err)
+	}
+	statuses, _ := New(db, edited).Status(ctx)
+	if len(statuses) != 1 || !statuses[0].Modified {
+		t.Fatalf("expected modified status got %+v", statuses)
+	}
+
+	// 2.- A binary older than the database refuses to migrate it.
+	if _, err := New(db, nil).Up(ctx); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("expected unknown version got %v", err)
+	}
+}
+
+func TestLockBlocksConcurrentMigrators(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	migrations, _ := Load(Embedded, Dir(dialect.MySQL))
+	m := New(db, migrations, WithLockTimeout(50*time.Millisecond))
+
+	// 1.- A lock held by another process makes Up give up after the timeout.
+	if err := m.ensureTables(ctx); err != nil {
+		t.Fatalf("ensure tables: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, 'other:1', ?)`, time.Now().UTC()); err != nil {
+		t.Fatalf("seed lock: %v", err)
+	}
+	if _, err := m.Up(ctx); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected lock timeout got %v", err)
+	}
+
+	// 2.- Unlock clears a stale lock and Up then releases its own.
+	if err := m.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up after unlock: %v", err)
+	}
+	var held int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations_lock`).Scan(&held); err != nil || held != 0 {
+		t.Fatalf("expected lock released got %d: %v", held, err)
+	}
+}
@@ -0,0 +1,67 @@
+// Package outbox stores notifications in the same transaction as the change that
+// caused them and relays them to sinks afterwards, so a crash between the write and
+// the notification cannot lose it. Delivery is at least once: consumers de-duplicate
+// by Message.ID.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"kage/backend/internal/dialect"
+)
+
+// Row states stored in outbox.status.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Message is one notification as stored in the outbox and handed to sinks.
+type Message struct {
+	ID        string          `json:"id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	// Attempts counts delivery attempts including the one in progress.
+	Attempts int `json:"-"`
+	// DeliveredSinks names the sinks that accepted the message on an earlier attempt.
+	DeliveredSinks []string `json:"-"`
+}
+
+// NewMessage encodes payload under topic; key groups related messages, such as a trip id.
+func NewMessage(topic, key string, payload interface{}, now time.Time) (Message, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, fmt.Errorf("encode %s payload: %w", topic, err)
+	}
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return Message{}, err
+	}
+	return Message{ID: hex.EncodeToString(id[:]), Topic: topic, Key: key, Payload: body, CreatedAt: now.UTC()}, nil
+}
+
+// Execer is satisfied by *sql.Tx, so repositories enqueue inside their own transaction.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Enqueue inserts msg as a pending row due immediately.
+func Enqueue(ctx context.Context, tx Execer, d dialect.Dialect, msg Message) error {
+	_, err := tx.ExecContext(ctx, d.Rebind(
+		`INSERT INTO outbox (id, topic, message_key, payload, created_at, status, attempts, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, 0, ?)`),
+		msg.ID, msg.Topic, msg.Key, string(msg.Payload), msg.CreatedAt, StatusPending, msg.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("enqueue %s: %w", msg.Topic, err)
+	}
+	return nil
+}
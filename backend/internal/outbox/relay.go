@@ -0,0 +1,234 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"kage/backend/internal/logging"
+)
+
+// Relay polls the outbox and publishes due messages to every sink.
+type Relay struct {
+	store        Store
+	sinks        []Sink
+	batchSize    int
+	pollInterval time.Duration
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	lease        time.Duration
+	retention    time.Duration
+	purgeEvery   time.Duration
+	lastPurge    time.Time
+	now          func() time.Time
+	logger       *slog.Logger
+}
+
+// Option mutates Relay configuration.
+type Option func(*Relay)
+
+// WithBatchSize bounds how many messages one poll handles.
+func WithBatchSize(n int) Option {
+	return func(r *Relay) {
+		if n > 0 {
+			r.batchSize = n
+		}
+	}
+}
+
+// WithPollInterval sets the pause between polls that found nothing left to do.
+func WithPollInterval(d time.Duration) Option {
+	return func(r *Relay) {
+		if d > 0 {
+			r.pollInterval = d
+		}
+	}
+}
+
+// WithMaxAttempts sets how many failed attempts dead-letter a message.
+func WithMaxAttempts(n int) Option {
+	return func(r *Relay) {
+		if n > 0 {
+			r.maxAttempts = n
+		}
+	}
+}
+
+// WithBackoff doubles the retry delay from base after every failure, capped at max.
+func WithBackoff(base, max time.Duration) Option {
+	return func(r *Relay) {
+		if base > 0 && max >= base {
+			r.baseBackoff, r.maxBackoff = base, max
+		}
+	}
+}
+
+// WithLease sets how long a claimed message stays hidden in case the relay dies mid-publish.
+func WithLease(d time.Duration) Option {
+	return func(r *Relay) {
+		if d > 0 {
+			r.lease = d
+		}
+	}
+}
+
+// WithRetention sets how long delivered messages are kept before purging.
+func WithRetention(d time.Duration) Option {
+	return func(r *Relay) {
+		if d > 0 {
+			r.retention = d
+		}
+	}
+}
+
+// WithPurgeInterval sets how often delivered messages past retention are purged.
+func WithPurgeInterval(d time.Duration) Option {
+	return func(r *Relay) {
+		if d > 0 {
+			r.purgeEvery = d
+		}
+	}
+}
+
+// WithClock injects the time source used for scheduling.
+func WithClock(now func() time.Time) Option {
+	return func(r *Relay) { r.now = now }
+}
+
+// WithLogger reports retries and dead letters.
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Relay) { r.logger = logging.OrDefault(logger) }
+}
+
+// NewRelay publishes messages from store to sinks.
+func NewRelay(store Store, sinks []Sink, opts ...Option) *Relay {
+	r := &Relay{
+		store:        store,
+		sinks:        sinks,
+		batchSize:    100,
+		pollInterval: time.Second,
+		maxAttempts:  10,
+		baseBackoff:  time.Second,
+		maxBackoff:   5 * time.Minute,
+		lease:        30 * time.Second,
+		retention:    24 * time.Hour,
+		purgeEvery:   time.Hour,
+		now:          time.Now,
+		logger:       slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run polls until ctx is done, draining full batches without waiting.
+func (r *Relay) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		n, err := r.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.WarnContext(ctx, "outbox relay poll failed", "error", err)
+		}
+		wait := r.pollInterval
+		if err == nil && n == r.batchSize {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// RunOnce handles one batch of due messages and returns how many it attempted.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	// 1.- Drop delivered rows past retention once per purge interval; a failure here must not stall delivery.
+	now := r.now()
+	if r.lastPurge.IsZero() || now.Sub(r.lastPurge) >= r.purgeEvery {
+		r.lastPurge = now
+		if err := r.store.Purge(ctx, now.Add(-r.retention)); err != nil {
+			r.logger.WarnContext(ctx, "outbox purge failed", "error", err)
+		}
+	}
+
+	// 2.- Claim each due message so concurrent relays skip it.
+	due, err := r.store.Due(ctx, now, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("list due outbox messages: %w", err)
+	}
+	attempted := 0
+	for _, msg := range due {
+		ok, err := r.store.Claim(ctx, msg, now.Add(r.lease))
+		if err != nil {
+			return attempted, fmt.Errorf("claim outbox message %s: %w", msg.ID, err)
+		}
+		if !ok {
+			continue
+		}
+		msg.Attempts++
+		attempted++
+
+		// 3.- Publish to the sinks still owed the message, then mark, reschedule or dead-letter it.
+		delivered, failure := r.publish(ctx, msg)
+		if err := r.settle(ctx, msg, delivered, failure); err != nil {
+			return attempted, err
+		}
+	}
+	return attempted, nil
+}
+
+// publish hands msg to every sink that has not accepted it yet; a failing sink does not stop
+// the others. It returns the sinks done so far, earlier attempts included.
+func (r *Relay) publish(ctx context.Context, msg Message) ([]string, error) {
+	done := make(map[string]bool, len(msg.DeliveredSinks))
+	for _, name := range msg.DeliveredSinks {
+		done[name] = true
+	}
+	delivered := append([]string(nil), msg.DeliveredSinks...)
+	var errs []error
+	for _, sink := range r.sinks {
+		if done[sink.Name()] {
+			continue
+		}
+		if err := sink.Publish(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			continue
+		}
+		delivered = append(delivered, sink.Name())
+	}
+	return delivered, errors.Join(errs...)
+}
+
+func (r *Relay) settle(ctx context.Context, msg Message, delivered []string, failure error) error {
+	attrs := []any{"outbox_id", msg.ID, "topic", msg.Topic, "attempt", msg.Attempts}
+	switch {
+	case failure == nil:
+		return r.store.Delivered(ctx, msg.ID, r.now())
+	case msg.Attempts >= r.maxAttempts:
+		r.logger.ErrorContext(ctx, "outbox message dead-lettered", append(attrs, "error", failure)...)
+		return r.store.DeadLetter(ctx, msg.ID, failure.Error())
+	default:
+		delay := r.backoff(msg.Attempts)
+		r.logger.WarnContext(ctx, "outbox delivery failed", append(attrs, "retry_in", delay.String(), "error", failure)...)
+		return r.store.Retry(ctx, msg.ID, r.now().Add(delay), failure.Error(), delivered)
+	}
+}
+
+// backoff is the delay after the given failed attempt: base, 2*base, 4*base, ... up to max.
+func (r *Relay) backoff(attempt int) time.Duration {
+	delay := r.baseBackoff
+	for i := 1; i < attempt && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,223 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-process Store keeping the same semantics as the outbox table.
+type memoryStore struct {
+	mu     sync.Mutex
+	rows   map[string]*memoryRow
+	purges int
+}
+
+type memoryRow struct {
+	msg       Message
+	status    string
+	next      time.Time
+	lastError string
+}
+
+func newMemoryStore(msgs ...Message) *memoryStore {
+	s := &memoryStore{rows: make(map[string]*memoryRow)}
+	for _, msg := range msgs {
+		s.rows[msg.ID] = &memoryRow{msg: msg, status: StatusPending, next: msg.CreatedAt}
+	}
+	return s
+}
+
+func (s *memoryStore) Due(_ context.Context, now time.Time, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Message
+	for _, row := range s.rows {
+		if row.status == StatusPending && !row.next.After(now) {
+			out = append(out, row.msg)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *memoryStore) Claim(_ context.Context, msg Message, lease time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row := s.rows[msg.ID]
+	if row.status != StatusPending || row.msg.Attempts != msg.Attempts {
+		return false, nil
+	}
+	row.msg.Attempts++
+	row.next = lease
+	return true, nil
+}
+
+func (s *memoryStore) Delivered(_ context.Context, id string, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows[id].status = StatusDelivered
+	return nil
+}
+
+func (s *memoryStore) Retry(_ context.Context, id string, next time.Time, cause string, delivered []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows[id].next, s.rows[id].lastError, s.rows[id].msg.DeliveredSinks = next, cause, delivered
+	return nil
+}
+
+func (s *memoryStore) DeadLetter(_ context.Context, id string, cause string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows[id].status, s.rows[id].lastError = StatusDead, cause
+	return nil
+}
+
+func (s *memoryStore) Purge(context.Context, time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purges++
+	return nil
+}
+
+func (s *memoryStore) row(id string) memoryRow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.rows[id]
+}
+
+// recordingSink remembers published ids and fails while failures remain.
+type recordingSink struct {
+	mu        sync.Mutex
+	name      string
+	published []string
+	failures  int
+}
+
+func (s *recordingSink) Name() string {
+	if s.name == "" {
+		return "recording"
+	}
+	return s.name
+}
+
+func (s *recordingSink) Publish(_ context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	s.published = append(s.published, msg.ID)
+	return nil
+}
+
+func TestRelayDeliversRetriesAndDeadLetters(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := base
+	clock := func() time.Time { return now }
+	msg := Message{ID: "m1", Topic: "trip.event", Key: "t1", Payload: []byte(`{}`), CreatedAt: base}
+	store := newMemoryStore(msg)
+	healthy, flaky := &recordingSink{name: "healthy"}, &recordingSink{name: "flaky", failures: 2}
+	relay := NewRelay(store, []Sink{healthy, flaky}, WithClock(clock), WithMaxAttempts(3), WithBackoff(time.Second, time.Minute))
+
+	// 1.- The first failure schedules a retry after the base backoff; healthy sinks still receive it once.
+	if n, err := relay.RunOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected one attempt got %d: %v", n, err)
+	}
+	if row := store.row("m1"); row.status != StatusPending || !row.next.Equal(base.Add(time.Second)) || row.lastError == "" ||
+		len(row.msg.DeliveredSinks) != 1 || row.msg.DeliveredSinks[0] != "healthy" {
+		t.Fatalf("unexpected row after first failure %+v", row)
+	}
+
+	// 2.- Nothing is due before the backoff elapses.
+	if n, _ := relay.RunOnce(context.Background()); n != 0 {
+		t.Fatalf("expected no attempt before backoff got %d", n)
+	}
+
+	// 3.- The second failure doubles the delay and the third attempt delivers, retrying only the failed sink.
+	now = base.Add(time.Second)
+	_, _ = relay.RunOnce(context.Background())
+	if row := store.row("m1"); !row.next.Equal(now.Add(2 * time.Second)) {
+		t.Fatalf("expected doubled backoff got %v", row.next.Sub(now))
+	}
+	now = now.Add(2 * time.Second)
+	_, _ = relay.RunOnce(context.Background())
+	if row := store.row("m1"); row.status != StatusDelivered {
+		t.Fatalf("expected delivered got %+v", row)
+	}
+	if len(healthy.published) != 1 || len(flaky.published) != 1 {
+		t.Fatalf("expected one publication per sink got healthy=%v flaky=%v", healthy.published, flaky.published)
+	}
+
+	// 4.- A message that keeps failing is dead-lettered after the last attempt.
+	store = newMemoryStore(Message{ID: "m2", Topic: "trip.event", Key: "t1", CreatedAt: now})
+	relay = NewRelay(store, []Sink{&recordingSink{failures: 10}}, WithClock(clock), WithMaxAttempts(2), WithBackoff(time.Second, time.Second))
+	for i := 0; i < 2; i++ {
+		_, _ = relay.RunOnce(context.Background())
+		now = now.Add(time.Second)
+	}
+	if row := store.row("m2"); row.status != StatusDead || row.lastError != "recording: sink unavailable" {
+		t.Fatalf("expected dead letter got %+v", row)
+	}
+}
+
+func TestRelayPurgesOncePerInterval(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newMemoryStore()
+	relay := NewRelay(store, nil, WithClock(func() time.Time { return now }), WithPurgeInterval(time.Hour))
+
+	// 1.- The first poll purges, later polls within the interval do not.
+	for i := 0; i < 3; i++ {
+		_, _ = relay.RunOnce(context.Background())
+		now = now.Add(time.Second)
+	}
+	if store.purges != 1 {
+		t.Fatalf("expected one purge got %d", store.purges)
+	}
+
+	// 2.- Once the interval elapses the next poll purges again.
+	now = now.Add(time.Hour)
+	_, _ = relay.RunOnce(context.Background())
+	if store.purges != 2 {
+		t.Fatalf("expected second purge after the interval got %d", store.purges)
+	}
+}
+
+func TestRelaySkipsMessagesClaimedElsewhere(t *testing.T) {
+	now := time.Now()
+	store := newMemoryStore(Message{ID: "m1", CreatedAt: now})
+	sink := &recordingSink{}
+	relay := NewRelay(store, []Sink{sink}, WithClock(func() time.Time { return now }))
+
+	// 1.- Another relay bumps the attempt counter between listing and claiming.
+	due, _ := store.Due(context.Background(), now, 10)
+	if ok, _ := store.Claim(context.Background(), due[0], now); !ok {
+		t.Fatalf("expected first claim to win")
+	}
+	if ok, _ := store.Claim(context.Background(), due[0], now); ok {
+		t.Fatalf("expected stale claim to lose")
+	}
+
+	// 2.- The lease hides the row, so this relay publishes nothing.
+	store.rows["m1"].next = now.Add(time.Minute)
+	if n, err := relay.RunOnce(context.Background()); err != nil || n != 0 || len(sink.published) != 0 {
+		t.Fatalf("expected claimed message skipped got %d %v", n, sink.published)
+	}
+}
+
+func TestBackoffCaps(t *testing.T) {
+	r := NewRelay(nil, nil, WithBackoff(time.Second, 5*time.Second))
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 30: 5 * time.Second} {
+		if got := r.backoff(attempt); got != want {
+			t.Fatalf("attempt %d: expected %v got %v", attempt, want, got)
+		}
+	}
+}
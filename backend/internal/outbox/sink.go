@@ -0,0 +1,187 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink kinds selectable through configuration.
+const (
+	SinkHub     = "ws"
+	SinkWebhook = "webhook"
+	SinkFile    = "file"
+	SinkKafka   = "kafka"
+)
+
+// Sink publishes one message; an error makes the relay retry it on the sinks that failed.
+// Name identifies the sink in the stored delivery progress, so it must be unique and stable.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, msg Message) error
+}
+
+// TripBroadcaster is the part of the websocket hub the hub sink needs.
+type TripBroadcaster interface {
+	BroadcastTrip(ctx context.Context, tripID, msgType string, payload interface{})
+}
+
+// HubSink pushes messages to both participants of the trip named by the message key.
+type HubSink struct {
+	hub TripBroadcaster
+}
+
+// NewHubSink wraps the websocket hub.
+func NewHubSink(hub TripBroadcaster) *HubSink {
+	return &HubSink{hub: hub}
+}
+
+func (s *HubSink) Name() string { return SinkHub }
+
+// Publish broadcasts the payload as a frame typed with the topic.
+func (s *HubSink) Publish(ctx context.Context, msg Message) error {
+	s.hub.BroadcastTrip(ctx, msg.Key, msg.Topic, map[string]interface{}{
+		"type": msg.Topic, "id": msg.ID, "trip_id": msg.Key, "payload": msg.Payload,
+	})
+	return nil
+}
+
+// WebhookSink POSTs each message as JSON to a fixed URL.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink posts to rawURL; a nil client gets a 10 second timeout.
+func NewWebhookSink(rawURL string, client *http.Client) (*WebhookSink, error) {
+	if _, err := url.ParseRequestURI(rawURL); err != nil {
+		return nil, fmt.Errorf("webhook sink url: %w", err)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &WebhookSink{url: rawURL, client: client}, nil
+}
+
+func (s *WebhookSink) Name() string { return SinkWebhook }
+
+// Publish sends the message with its id as Idempotency-Key; any non-2xx answer is a failure.
+func (s *WebhookSink) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, "application/json", body, map[string]string{"Idempotency-Key": msg.ID})
+}
+
+// FileSink appends one JSON line per message, for local development and audits.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens path for appending, creating it when missing.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open outbox file: %w", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Name() string { return SinkFile }
+
+// Publish writes and syncs the line so a delivered mark never precedes the data on disk.
+func (s *FileSink) Publish(_ context.Context, msg Message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close releases the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// KafkaSink produces to a topic through the Kafka REST Proxy v2 API, which Confluent REST Proxy
+// and Redpanda's HTTP proxy both serve. Records are keyed by the message key so a trip stays in one partition.
+type KafkaSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewKafkaSink produces to topic on the proxy at baseURL; a nil client gets a 10 second timeout.
+func NewKafkaSink(baseURL, topic string, client *http.Client) (*KafkaSink, error) {
+	if _, err := url.ParseRequestURI(baseURL); err != nil {
+		return nil, fmt.Errorf("kafka sink url: %w", err)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("kafka sink: topic is required")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &KafkaSink{endpoint: strings.TrimRight(baseURL, "/") + "/topics/" + url.PathEscape(topic), client: client}, nil
+}
+
+func (s *KafkaSink) Name() string { return SinkKafka }
+
+// Publish produces a single JSON record.
+func (s *KafkaSink) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{{"key": msg.Key, "value": msg}},
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.endpoint, "application/vnd.kafka.json.v2+json", body, nil)
+}
+
+// post sends body and treats anything but a 2xx answer as an error carrying the start of the response.
+// Errors never include target, since its userinfo or query may hold credentials that would
+// otherwise reach the logs and outbox.last_error.
+func post(ctx context.Context, client *http.Client, target, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return withoutURL(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer res.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(res.Body, 256))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("endpoint answered %d: %s", res.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// withoutURL strips the request URL a *url.Error carries, keeping the operation and cause.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testMessage() Message {
+	return Message{ID: "m1", Topic: "bid.accepted", Key: "t1", Payload: json.RawMessage(`{"bid_id":"b1"}`), CreatedAt: time.Unix(1735689600, 0).UTC()}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var (
+		got    Message
+		status = http.StatusNoContent
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Idempotency-Key") != "m1" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+	sink, err := NewWebhookSink(receiver.URL+"/hook?token=hooktoken", receiver.Client())
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+
+	// 1.- A 2xx answer acknowledges the envelope.
+	if err := sink.Publish(context.Background(), testMessage()); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if got.ID != "m1" || got.Topic != "bid.accepted" || string(got.Payload) != `{"bid_id":"b1"}` {
+		t.Fatalf("unexpected envelope %+v", got)
+	}
+
+	// 2.- Anything else is a failure the relay retries, reported without the URL's credentials.
+	status = http.StatusServiceUnavailable
+	if err := sink.Publish(context.Background(), testMessage()); err == nil || strings.Contains(err.Error(), "hooktoken") {
+		t.Fatalf("expected redacted error on 503 got %v", err)
+	}
+
+	// 3.- Transport failures leave the URL out too.
+	receiver.Close()
+	if err := sink.Publish(context.Background(), testMessage()); err == nil || strings.Contains(err.Error(), "hooktoken") {
+		t.Fatalf("expected redacted transport error got %v", err)
+	}
+}
+
+func TestKafkaSinkUsesRESTProxyFormat(t *testing.T) {
+	var body struct {
+		Records []struct {
+			Key   string  `json:"key"`
+			Value Message `json:"value"`
+		} `json:"records"`
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/kage.events" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = io.WriteString(w, `{"offsets":[{"partition":0,"offset":1}]}`)
+	}))
+	defer proxy.Close()
+	sink, err := NewKafkaSink(proxy.URL+"/", "kage.events", proxy.Client())
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+	if err := sink.Publish(context.Background(), testMessage()); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if len(body.Records) != 1 || body.Records[0].Key != "t1" || body.Records[0].Value.ID != "m1" {
+		t.Fatalf("unexpected records %+v", body.Records)
+	}
+}
+
+func TestFileSinkAppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	for i := 0; i < 2; i++ {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatalf("new sink: %v", err)
+		}
+		if err := sink.Publish(context.Background(), testMessage()); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		_ = sink.Close()
+	}
+	file, _ := os.Open(path)
+	defer file.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); lines++ {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.ID != "m1" {
+			t.Fatalf("unexpected line %q: %v", scanner.Text(), err)
+		}
+	}
+	if lines != 2 {
+		t.Fatalf("expected reopened file appended to, got %d lines", lines)
+	}
+}
+
+type fakeBroadcaster struct {
+	tripID, msgType string
+	payload         interface{}
+}
+
+func (f *fakeBroadcaster) BroadcastTrip(_ context.Context, tripID, msgType string, payload interface{}) {
+	f.tripID, f.msgType, f.payload = tripID, msgType, payload
+}
+
+func TestHubSinkTargetsTripRoom(t *testing.T) {
+	hub := &fakeBroadcaster{}
+	if err := NewHubSink(hub).Publish(context.Background(), testMessage()); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	frame, _ := json.Marshal(hub.payload)
+	if hub.tripID != "t1" || hub.msgType != "bid.accepted" || string(frame) != `{"id":"m1","payload":{"bid_id":"b1"},"trip_id":"t1","type":"bid.accepted"}` {
+		t.Fatalf("unexpected broadcast %s %s %s", hub.tripID, hub.msgType, frame)
+	}
+}
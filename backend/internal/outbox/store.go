@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"kage/backend/internal/dialect"
+	"kage/backend/internal/tracing"
+)
+
+// Store is the relay's view of the outbox table.
+type Store interface {
+	// Due lists pending messages whose next attempt is at or before now, oldest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]Message, error)
+	// Claim bumps the attempt counter and hides msg until lease; ok is false when another relay claimed it first.
+	Claim(ctx context.Context, msg Message, lease time.Time) (ok bool, err error)
+	// Delivered marks msg published to every sink.
+	Delivered(ctx context.Context, id string, at time.Time) error
+	// Retry schedules another attempt at next, keeping the failure for operators and the
+	// sinks that already accepted msg so the next attempt skips them.
+	Retry(ctx context.Context, id string, next time.Time, cause string, delivered []string) error
+	// DeadLetter parks msg after its last failed attempt.
+	DeadLetter(ctx context.Context, id string, cause string) error
+	// Purge deletes delivered messages older than before.
+	Purge(ctx context.Context, before time.Time) error
+}
+
+// SQLStore implements Store over the outbox table.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// NewSQLStore builds a store for the given database handle and its dialect.
+func NewSQLStore(db *sql.DB, d dialect.Dialect) *SQLStore {
+	return &SQLStore{db: db, dialect: d}
+}
+
+// Due selects pending rows that are ready for an attempt.
+func (s *SQLStore) Due(ctx context.Context, now time.Time, limit int) (out []Message, err error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "SELECT", "outbox")
+	defer func() { tracing.End(span, err) }()
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(
+		`SELECT id, topic, message_key, payload, created_at, attempts, delivered_sinks FROM outbox WHERE status = ? AND next_attempt_at <= ? ORDER BY created_at, id LIMIT ?`),
+		StatusPending, now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			msg       Message
+			payload   string
+			delivered sql.NullString
+		)
+		if err := rows.Scan(&msg.ID, &msg.Topic, &msg.Key, &payload, &msg.CreatedAt, &msg.Attempts, &delivered); err != nil {
+			return nil, err
+		}
+		msg.Payload = []byte(payload)
+		if delivered.String != "" {
+			msg.DeliveredSinks = strings.Split(delivered.String, ",")
+		}
+		out = append(out, msg)
+	}
+	return out, rows.Err()
+}
+
+// Claim uses the attempt counter as an optimistic version so concurrent relays never both win a row.
+func (s *SQLStore) Claim(ctx context.Context, msg Message, lease time.Time) (ok bool, err error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "UPDATE", "outbox")
+	defer func() { tracing.End(span, err) }()
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE outbox SET attempts = attempts + 1, next_attempt_at = ? WHERE id = ? AND status = ? AND attempts = ?`),
+		lease.UTC(), msg.ID, StatusPending, msg.Attempts,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Delivered records the successful publication.
+func (s *SQLStore) Delivered(ctx context.Context, id string, at time.Time) error {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "UPDATE", "outbox")
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE outbox SET status = ?, delivered_at = ?, last_error = NULL, delivered_sinks = NULL WHERE id = ?`),
+		StatusDelivered, at.UTC(), id,
+	)
+	return tracing.End(span, err)
+}
+
+// Retry makes the row due again at next and remembers which sinks are done.
+func (s *SQLStore) Retry(ctx context.Context, id string, next time.Time, cause string, delivered []string) error {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "UPDATE", "outbox")
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE outbox SET next_attempt_at = ?, last_error = ?, delivered_sinks = ? WHERE id = ?`),
+		next.UTC(), cause, strings.Join(delivered, ","), id,
+	)
+	return tracing.End(span, err)
+}
+
+// DeadLetter moves the row out of the pending set; it stays in the table for inspection.
+func (s *SQLStore) DeadLetter(ctx context.Context, id string, cause string) error {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "UPDATE", "outbox")
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE outbox SET status = ?, last_error = ? WHERE id = ?`),
+		StatusDead, cause, id,
+	)
+	return tracing.End(span, err)
+}
+
+// Purge removes delivered rows that are past retention; dead letters are kept.
+func (s *SQLStore) Purge(ctx context.Context, before time.Time) error {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "DELETE", "outbox")
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`DELETE FROM outbox WHERE status = ? AND delivered_at < ?`),
+		StatusDelivered, before.UTC(),
+	)
+	return tracing.End(span, err)
+}
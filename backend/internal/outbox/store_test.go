@@ -0,0 +1,61 @@
+package outbox
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"kage/backend/internal/dialect"
+)
+
+func TestSQLStoreDueAndClaim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+	store := NewSQLStore(db, dialect.Postgres)
+	now := time.Unix(1735689600, 0).UTC()
+
+	// 1.- Due lists pending rows that are ready, oldest first.
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, topic, message_key, payload, created_at, attempts, delivered_sinks FROM outbox WHERE status = $1 AND next_attempt_at <= $2 ORDER BY created_at, id LIMIT $3`)).
+		WithArgs(StatusPending, now, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "message_key", "payload", "created_at", "attempts", "delivered_sinks"}).
+			AddRow("m1", "trip.event", "t1", `{"state":"active"}`, now, 2, "hub,file"))
+	due, err := store.Due(context.Background(), now, 10)
+	if err != nil || len(due) != 1 || due[0].Attempts != 2 || string(due[0].Payload) != `{"state":"active"}` ||
+		len(due[0].DeliveredSinks) != 2 || due[0].DeliveredSinks[1] != "file" {
+		t.Fatalf("unexpected due %+v: %v", due, err)
+	}
+
+	// 2.- Claim guards on the attempt counter it read; losing the race affects no row.
+	claim := regexp.QuoteMeta(`UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $1 WHERE id = $2 AND status = $3 AND attempts = $4`)
+	mock.ExpectExec(claim).WithArgs(now.Add(time.Minute), "m1", StatusPending, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(claim).WithArgs(now.Add(time.Minute), "m1", StatusPending, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	if ok, err := store.Claim(context.Background(), due[0], now.Add(time.Minute)); !ok || err != nil {
+		t.Fatalf("expected claim got %v %v", ok, err)
+	}
+	if ok, err := store.Claim(context.Background(), due[0], now.Add(time.Minute)); ok || err != nil {
+		t.Fatalf("expected lost claim got %v %v", ok, err)
+	}
+
+	// 3.- Retries remember the sinks that already accepted the message.
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET next_attempt_at = $1, last_error = $2, delivered_sinks = $3 WHERE id = $4`)).
+		WithArgs(now.Add(time.Second), "kafka: down", "hub,file", "m1").WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := store.Retry(context.Background(), "m1", now.Add(time.Second), "kafka: down", []string{"hub", "file"}); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+
+	// 4.- Dead letters keep the failure and leave the pending set.
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET status = $1, last_error = $2 WHERE id = $3`)).
+		WithArgs(StatusDead, "boom", "m1").WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := store.DeadLetter(context.Background(), "m1", "boom"); err != nil {
+		t.Fatalf("dead letter: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
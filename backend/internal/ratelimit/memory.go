@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery bounds how many takes pass between scans for idle buckets.
+const sweepEvery = 1024
+
+// MemoryStore keeps buckets in process; limits are per replica.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	takes   int
+}
+
+// NewMemoryStore returns an empty in-process store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*Bucket)}
+}
+
+// Take spends a token from key's bucket, creating it full on first use.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 1.- Periodically forget buckets that have refilled so idle callers do not accumulate.
+	s.takes++
+	if s.takes%sweepEvery == 0 {
+		for k, b := range s.buckets {
+			if b.idle(now) {
+				delete(s.buckets, k)
+			}
+		}
+	}
+
+	// 2.- A changed limit starts a fresh bucket rather than carrying tokens over.
+	b, ok := s.buckets[key]
+	if !ok || b.limit != limit {
+		b = NewBucket(limit)
+		s.buckets[key] = b
+	}
+	return b.Take(now), nil
+}
+
+// Len returns the number of tracked buckets.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
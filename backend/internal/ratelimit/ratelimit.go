@@ -0,0 +1,214 @@
+// Package ratelimit implements token-bucket limits keyed by caller and route.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store kinds selectable through configuration.
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// Limit is a token bucket holding up to Burst tokens and refilling Rate tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Valid reports whether the bucket can ever admit a request.
+func (l Limit) Valid() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// String renders the limit in the "rate:burst" form ParseLimit accepts.
+func (l Limit) String() string {
+	return strconv.FormatFloat(l.Rate, 'g', -1, 64) + ":" + strconv.Itoa(l.Burst)
+}
+
+// Decision is the outcome of taking one token.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// Store keeps bucket state; implementations must make Take atomic per key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error)
+}
+
+// decide derives the caller-facing numbers from the tokens left after a take.
+func decide(limit Limit, tokens float64, allowed bool) Decision {
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		d.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// Bucket is a single token bucket for state owned by one goroutine, such as a websocket reader.
+type Bucket struct {
+	limit   Limit
+	tokens  float64
+	updated time.Time
+}
+
+// NewBucket returns a full bucket.
+func NewBucket(limit Limit) *Bucket {
+	return &Bucket{limit: limit, tokens: float64(limit.Burst)}
+}
+
+// Take refills the bucket for the time elapsed since the last call and spends one token if available.
+func (b *Bucket) Take(now time.Time) Decision {
+	if !b.updated.IsZero() && now.After(b.updated) {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*b.limit.Rate)
+	}
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return decide(b.limit, b.tokens, allowed)
+}
+
+// idle reports whether the bucket would be full again at now, so forgetting it changes nothing.
+func (b *Bucket) idle(now time.Time) bool {
+	return b.tokens+now.Sub(b.updated).Seconds()*b.limit.Rate >= float64(b.limit.Burst)
+}
+
+// Limiter applies per-route limits to caller keys through a Store.
+type Limiter struct {
+	store    Store
+	mu       sync.RWMutex
+	routes   map[string]Limit
+	fallback Limit
+	now      func() time.Time
+}
+
+// Option mutates Limiter configuration.
+type Option func(*Limiter)
+
+// WithRoute limits requests to route, identified by its template such as "/bids/evaluate".
+func WithRoute(route string, limit Limit) Option {
+	return func(l *Limiter) { l.routes[route] = limit }
+}
+
+// WithLimits applies a parsed limit table, honouring the "*" default entry.
+func WithLimits(limits map[string]Limit) Option {
+	return func(l *Limiter) { l.apply(limits) }
+}
+
+func (l *Limiter) apply(limits map[string]Limit) {
+	for route, limit := range limits {
+		if route == "*" {
+			l.fallback = limit
+			continue
+		}
+		l.routes[route] = limit
+	}
+}
+
+// WithDefault limits every route without an explicit entry.
+func WithDefault(limit Limit) Option {
+	return func(l *Limiter) { l.fallback = limit }
+}
+
+// WithClock injects a custom time source for tests.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) { l.now = now }
+}
+
+// NewLimiter builds a limiter over store; routes without a limit are not restricted.
+func NewLimiter(store Store, opts ...Option) *Limiter {
+	l := &Limiter{store: store, routes: make(map[string]Limit), now: time.Now}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// SetLimits replaces every route limit and the default with the table, as WithLimits reads it.
+// Requests already past Take are unaffected; the new limits apply from the next call.
+func (l *Limiter) SetLimits(limits map[string]Limit) {
+	next := &Limiter{routes: make(map[string]Limit)}
+	next.apply(limits)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.routes, l.fallback = next.routes, next.fallback
+}
+
+// Take spends a token of key's bucket for route. ok is false when route is unlimited.
+func (l *Limiter) Take(ctx context.Context, route, key string) (d Decision, ok bool, err error) {
+	l.mu.RLock()
+	limit, found := l.routes[route]
+	if !found {
+		limit = l.fallback
+	}
+	l.mu.RUnlock()
+	if !limit.Valid() {
+		return Decision{Allowed: true}, false, nil
+	}
+	d, err = l.store.Take(ctx, route+"|"+key, limit, l.now())
+	return d, true, err
+}
+
+// ParseLimit decodes "rate:burst", such as "5:10" for five requests per second with bursts of ten.
+func ParseLimit(spec string) (Limit, error) {
+	rateText, burstText, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q: expected rate:burst", spec)
+	}
+	rate, err := strconv.ParseFloat(rateText, 64)
+	if err != nil || rate <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: invalid rate", spec)
+	}
+	burst, err := strconv.Atoi(burstText)
+	if err != nil || burst <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: invalid burst", spec)
+	}
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+// ParseLimits decodes specs such as "/bids/evaluate=5:10,*=50:100".
+// The special route "*" sets the default limit applied to unlisted routes.
+func ParseLimits(spec string) (map[string]Limit, error) {
+	out := make(map[string]Limit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, rest, ok := strings.Cut(entry, "=")
+		if !ok || route == "" {
+			return nil, fmt.Errorf("rate limit entry %q: expected route=rate:burst", entry)
+		}
+		limit, err := ParseLimit(rest)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit entry %q: %w", entry, err)
+		}
+		out[route] = limit
+	}
+	return out, nil
+}
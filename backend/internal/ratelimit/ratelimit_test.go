@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("/bids/evaluate=5:10, *=0.5:2")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if limits["/bids/evaluate"] != (Limit{Rate: 5, Burst: 10}) || limits["*"] != (Limit{Rate: 0.5, Burst: 2}) {
+		t.Fatalf("unexpected limits %v", limits)
+	}
+	if got := limits["*"].String(); got != "0.5:2" {
+		t.Fatalf("expected 0.5:2 got %q", got)
+	}
+	for _, bad := range []string{"/x", "/x=5", "/x=0:1", "/x=1:0", "=1:1"} {
+		if _, err := ParseLimits(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+// exerciseStore drains a 2-token bucket refilling one token per second and checks the reported numbers.
+func exerciseStore(t *testing.T, store Store) {
+	t.Helper()
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewLimiter(store, WithRoute("/bids", Limit{Rate: 1, Burst: 2}), WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+	take := func(key string) Decision {
+		d, ok, err := limiter.Take(ctx, "/bids", key)
+		if err != nil || !ok {
+			t.Fatalf("take: ok=%v err=%v", ok, err)
+		}
+		return d
+	}
+
+	// 1.- The burst is admitted, then the caller is refused with a retry hint.
+	if d := take("alice"); !d.Allowed || d.Remaining != 1 || d.Limit != 2 {
+		t.Fatalf("unexpected first decision %+v", d)
+	}
+	take("alice")
+	d := take("alice")
+	if d.Allowed || d.Remaining != 0 || d.RetryAfter != time.Second || d.Reset != 2*time.Second {
+		t.Fatalf("expected refusal with a one second retry got %+v", d)
+	}
+
+	// 2.- Other callers have their own bucket.
+	if d := take("bob"); !d.Allowed {
+		t.Fatalf("expected separate bucket for bob got %+v", d)
+	}
+
+	// 3.- Tokens refill with time.
+	now = now.Add(1500 * time.Millisecond)
+	if d := take("alice"); !d.Allowed || d.Remaining != 0 {
+		t.Fatalf("expected refilled token got %+v", d)
+	}
+
+	// 4.- Routes without a limit are not restricted.
+	if _, ok, _ := limiter.Take(ctx, "/other", "alice"); ok {
+		t.Fatalf("expected unlimited route")
+	}
+
+	// 5.- Replacing the table drops the old routes and installs the new default.
+	limiter.SetLimits(map[string]Limit{"*": {Rate: 1, Burst: 5}})
+	if d, ok, _ := limiter.Take(ctx, "/other", "alice"); !ok || d.Limit != 5 {
+		t.Fatalf("expected default limit on /other got %+v %v", d, ok)
+	}
+	if d := take("alice"); d.Limit != 5 {
+		t.Fatalf("expected replaced limit on /bids got %+v", d)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	exerciseStore(t, NewMemoryStore())
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	exerciseStore(t, NewRedisStore(client, "kage:ratelimit:"))
+	if ttl := server.TTL("kage:ratelimit:/bids|alice"); ttl <= 0 {
+		t.Fatalf("expected bucket keys to expire got ttl %v", ttl)
+	}
+}
+
+func TestMemoryStoreForgetsIdleBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Unix(0, 0)
+	limit := Limit{Rate: 1, Burst: 1}
+	for i := 0; i < sweepEvery-1; i++ {
+		_, _ = store.Take(context.Background(), fmt.Sprintf("caller-%d", i), limit, now)
+	}
+	_, _ = store.Take(context.Background(), "late", limit, now.Add(time.Minute))
+	if n := store.Len(); n != 1 {
+		t.Fatalf("expected idle buckets swept leaving one got %d", n)
+	}
+}
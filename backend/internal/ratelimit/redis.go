@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and spends a bucket stored as a hash of tokens and last update in milliseconds.
+// It returns {allowed, tokens}, tokens as a string so fractions survive the reply conversion.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+  ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore shares buckets between replicas through Redis.
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisStore keeps buckets under prefix-qualified keys that expire once refilled.
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Take runs the bucket update atomically on the server.
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	res, err := takeScript.Run(ctx, s.client, []string{s.prefix + key}, limit.Rate, limit.Burst, now.UnixMilli()).Slice()
+	if err != nil {
+		return Decision{}, fmt.Errorf("rate limit %s: %w", key, err)
+	}
+	if len(res) != 2 {
+		return Decision{}, fmt.Errorf("rate limit %s: unexpected reply %v", key, res)
+	}
+	allowed, _ := res[0].(int64)
+	text, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return Decision{}, fmt.Errorf("rate limit %s: parse tokens: %w", key, err)
+	}
+	return decide(limit, math.Max(tokens, 0), allowed == 1), nil
+}
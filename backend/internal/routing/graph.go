@@ -0,0 +1,212 @@
+package routing
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"kage/backend/internal/geo"
+)
+
+// DefaultSpeedKmh applies to edges whose source row omits speed_kmh.
+const DefaultSpeedKmh = 30.0
+
+type edge struct {
+	to      int
+	km      float64
+	seconds float64
+}
+
+// Graph is an immutable directed road network.
+type Graph struct {
+	ids         []string
+	lat         []float64
+	lon         []float64
+	adj         [][]edge
+	byID        map[string]int
+	maxSpeedKmh float64
+	snap        *geo.Index
+}
+
+// Builder accumulates nodes and edges before freezing them into a Graph.
+type Builder struct {
+	g *Graph
+}
+
+// NewBuilder starts an empty graph.
+func NewBuilder() *Builder {
+	return &Builder{g: &Graph{byID: make(map[string]int)}}
+}
+
+// AddNode registers a node, updating its coordinate when the id already exists.
+func (b *Builder) AddNode(id string, lat, lon float64) {
+	if i, ok := b.g.byID[id]; ok {
+		b.g.lat[i], b.g.lon[i] = lat, lon
+		return
+	}
+	b.g.byID[id] = len(b.g.ids)
+	b.g.ids = append(b.g.ids, id)
+	b.g.lat = append(b.g.lat, lat)
+	b.g.lon = append(b.g.lon, lon)
+	b.g.adj = append(b.g.adj, nil)
+}
+
+// AddEdge connects two known nodes; a non-positive lengthKm falls back to the great-circle length.
+func (b *Builder) AddEdge(from, to string, lengthKm, speedKmh float64, oneway bool) error {
+	fi, ok := b.g.byID[from]
+	if !ok {
+		return fmt.Errorf("unknown node %q", from)
+	}
+	ti, ok := b.g.byID[to]
+	if !ok {
+		return fmt.Errorf("unknown node %q", to)
+	}
+	if speedKmh <= 0 {
+		return fmt.Errorf("edge %s->%s: speed must be positive", from, to)
+	}
+	if lengthKm <= 0 {
+		lengthKm = geo.DistanceBetween(b.g.lat[fi], b.g.lon[fi], b.g.lat[ti], b.g.lon[ti])
+	}
+	seconds := lengthKm / speedKmh * 3600
+	b.g.adj[fi] = append(b.g.adj[fi], edge{to: ti, km: lengthKm, seconds: seconds})
+	if !oneway {
+		b.g.adj[ti] = append(b.g.adj[ti], edge{to: fi, km: lengthKm, seconds: seconds})
+	}
+	if speedKmh > b.g.maxSpeedKmh {
+		b.g.maxSpeedKmh = speedKmh
+	}
+	return nil
+}
+
+// Build freezes the graph and indexes its nodes for snapping.
+func (b *Builder) Build() (*Graph, error) {
+	g := b.g
+	if len(g.ids) == 0 {
+		return nil, errors.New("road graph has no nodes")
+	}
+	g.snap = geo.NewIndex(7)
+	for i, id := range g.ids {
+		g.snap.Insert(geo.Point{ID: id, Lat: g.lat[i], Lon: g.lon[i]})
+	}
+	b.g = &Graph{byID: make(map[string]int)}
+	return g, nil
+}
+
+// Len returns the number of nodes.
+func (g *Graph) Len() int {
+	return len(g.ids)
+}
+
+// LoadFile reads a CSV edge list from path; see Load for the format.
+func LoadFile(path string) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open road graph: %w", err)
+	}
+	defer f.Close()
+	g, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("load road graph %s: %w", path, err)
+	}
+	return g, nil
+}
+
+// Load parses an OSM-style CSV edge list. The header must name the columns
+// from_id, from_lat, from_lon, to_id, to_lat, to_lon and may add length_m,
+// speed_kmh and oneway (true/false/1/0/yes/no).
+func Load(r io.Reader) (*Graph, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"from_id", "from_lat", "from_lon", "to_id", "to_lat", "to_lon"} {
+		if _, ok := cols[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	b := NewBuilder()
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 1.- Register both endpoints so edge rows are self-contained.
+		fromLat, fromLon, err := parseCoordinate(row, cols, "from")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		toLat, toLon, err := parseCoordinate(row, cols, "to")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		from, to := field(row, cols, "from_id"), field(row, cols, "to_id")
+		b.AddNode(from, fromLat, fromLon)
+		b.AddNode(to, toLat, toLon)
+
+		// 2.- Optional attributes fall back to great-circle length and the default speed.
+		lengthKm, speed, oneway := 0.0, DefaultSpeedKmh, false
+		if v := field(row, cols, "length_m"); v != "" {
+			meters, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: length_m: %w", line, err)
+			}
+			lengthKm = meters / 1000
+		}
+		if v := field(row, cols, "speed_kmh"); v != "" {
+			if speed, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("line %d: speed_kmh: %w", line, err)
+			}
+		}
+		if v := field(row, cols, "oneway"); v != "" {
+			switch strings.ToLower(v) {
+			case "1", "true", "yes":
+				oneway = true
+			case "0", "false", "no":
+			default:
+				return nil, fmt.Errorf("line %d: oneway: invalid value %q", line, v)
+			}
+		}
+		if err := b.AddEdge(from, to, lengthKm, speed, oneway); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return b.Build()
+}
+
+func field(row []string, cols map[string]int, name string) string {
+	i, ok := cols[name]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+func parseCoordinate(row []string, cols map[string]int, prefix string) (float64, float64, error) {
+	lat, err := strconv.ParseFloat(field(row, cols, prefix+"_lat"), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s_lat: %w", prefix, err)
+	}
+	lon, err := strconv.ParseFloat(field(row, cols, prefix+"_lon"), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s_lon: %w", prefix, err)
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("%s coordinate out of range", prefix)
+	}
+	return lat, lon, nil
+}
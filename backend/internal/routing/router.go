@@ -0,0 +1,166 @@
+package routing
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"kage/backend/internal/geo"
+)
+
+// ErrNoRoute occurs when the destination is not reachable over the road graph.
+var ErrNoRoute = errors.New("no route between points")
+
+// ErrOffNetwork occurs when an endpoint is too far from any road to snap onto it.
+var ErrOffNetwork = errors.New("point too far from road network")
+
+// Route summarises the fastest path between two coordinates.
+type Route struct {
+	DistanceKm float64
+	Duration   time.Duration
+	Nodes      []string
+}
+
+// Router answers fastest-path queries over a Graph with A*.
+type Router struct {
+	graph         *Graph
+	maxSnapKm     float64
+	accessSpeedKm float64
+}
+
+// RouterOption mutates Router configuration.
+type RouterOption func(*Router)
+
+// WithMaxSnap limits how far an endpoint may lie from its nearest node.
+func WithMaxSnap(km float64) RouterOption {
+	return func(r *Router) { r.maxSnapKm = km }
+}
+
+// WithAccessSpeed sets the speed used for the straight-line legs between endpoints and their snapped nodes.
+func WithAccessSpeed(kmh float64) RouterOption {
+	return func(r *Router) { r.accessSpeedKm = kmh }
+}
+
+// NewRouter builds a router over the graph with sane defaults.
+func NewRouter(graph *Graph, opts ...RouterOption) *Router {
+	r := &Router{graph: graph, maxSnapKm: 0.5, accessSpeedKm: 15}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Route returns the fastest road path between two coordinates, including the access legs to and from the network.
+func (r *Router) Route(ctx context.Context, fromLat, fromLon, toLat, toLon float64) (Route, error) {
+	// 1.- Snap both endpoints onto their nearest graph nodes.
+	src, srcKm, err := r.snap(fromLat, fromLon)
+	if err != nil {
+		return Route{}, err
+	}
+	dst, dstKm, err := r.snap(toLat, toLon)
+	if err != nil {
+		return Route{}, err
+	}
+
+	// 2.- Search the network and add the off-road access legs.
+	path, km, seconds, err := r.graph.astar(ctx, src, dst)
+	if err != nil {
+		return Route{}, err
+	}
+	access := srcKm + dstKm
+	if r.accessSpeedKm > 0 {
+		seconds += access / r.accessSpeedKm * 3600
+	}
+	nodes := make([]string, len(path))
+	for i, n := range path {
+		nodes[i] = r.graph.ids[n]
+	}
+	return Route{
+		DistanceKm: km + access,
+		Duration:   time.Duration(seconds * float64(time.Second)),
+		Nodes:      nodes,
+	}, nil
+}
+
+func (r *Router) snap(lat, lon float64) (int, float64, error) {
+	nearest := r.graph.snap.Nearest(lat, lon, 1)
+	if len(nearest) == 0 || (r.maxSnapKm > 0 && nearest[0].DistanceKm > r.maxSnapKm) {
+		return 0, 0, ErrOffNetwork
+	}
+	return r.graph.byID[nearest[0].ID], nearest[0].DistanceKm, nil
+}
+
+// astar finds the minimum travel time path; the straight-line distance at the
+// network's top speed keeps the heuristic admissible.
+func (g *Graph) astar(ctx context.Context, src, dst int) ([]int, float64, float64, error) {
+	n := len(g.ids)
+	cost := make([]float64, n)
+	dist := make([]float64, n)
+	prev := make([]int, n)
+	for i := range cost {
+		cost[i] = math.Inf(1)
+		prev[i] = -1
+	}
+	heuristic := func(i int) float64 {
+		if g.maxSpeedKmh <= 0 {
+			return 0
+		}
+		return geo.DistanceBetween(g.lat[i], g.lon[i], g.lat[dst], g.lon[dst]) / g.maxSpeedKmh * 3600
+	}
+
+	cost[src] = 0
+	open := &frontier{{node: src, priority: heuristic(src)}}
+	closed := make([]bool, n)
+	for expanded := 0; open.Len() > 0; expanded++ {
+		if expanded%1024 == 0 && ctx.Err() != nil {
+			return nil, 0, 0, ctx.Err()
+		}
+		cur := heap.Pop(open).(item).node
+		if closed[cur] {
+			continue
+		}
+		if cur == dst {
+			var path []int
+			for at := dst; at != -1; at = prev[at] {
+				path = append(path, at)
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path, dist[dst], cost[dst], nil
+		}
+		closed[cur] = true
+		for _, e := range g.adj[cur] {
+			if closed[e.to] {
+				continue
+			}
+			if next := cost[cur] + e.seconds; next < cost[e.to] {
+				cost[e.to] = next
+				dist[e.to] = dist[cur] + e.km
+				prev[e.to] = cur
+				heap.Push(open, item{node: e.to, priority: next + heuristic(e.to)})
+			}
+		}
+	}
+	return nil, 0, 0, ErrNoRoute
+}
+
+type item struct {
+	node     int
+	priority float64
+}
+
+type frontier []item
+
+func (f frontier) Len() int            { return len(f) }
+func (f frontier) Less(i, j int) bool  { return f[i].priority < f[j].priority }
+func (f frontier) Swap(i, j int)       { f[i], f[j] = f[j], f[i] }
+func (f *frontier) Push(x interface{}) { *f = append(*f, x.(item)) }
+func (f *frontier) Pop() interface{} {
+	old := *f
+	last := old[len(old)-1]
+	*f = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"kage/backend/internal/geo"
+)
+
+// riverGraph models two banks 1 km apart joined only by a bridge 3 km upstream.
+const riverGraph = `from_id,from_lat,from_lon,to_id,to_lat,to_lon,length_m,speed_kmh,oneway
+a,0,0,bridge_south,0.027,0,,50,false
+bridge_south,0.027,0,bridge_north,0.027,0.009,,50,false
+bridge_north,0.027,0.009,b,0,0.009,,50,false
+b,0,0.009,c,-0.01,0.009,,30,true
+`
+
+func TestRouteFollowsRoads(t *testing.T) {
+	graph, err := Load(strings.NewReader(riverGraph))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	router := NewRouter(graph)
+
+	route, err := router.Route(context.Background(), 0, 0, 0, 0.009)
+	if err != nil {
+		t.Fatalf("route: %v", err)
+	}
+	straight := geo.DistanceBetween(0, 0, 0, 0.009)
+	if route.DistanceKm < 6*straight {
+		t.Fatalf("expected detour over the bridge, got %.2f km vs %.2f km straight", route.DistanceKm, straight)
+	}
+	if got := strings.Join(route.Nodes, ","); got != "a,bridge_south,bridge_north,b" {
+		t.Fatalf("unexpected path %s", got)
+	}
+	expected := route.DistanceKm / 50 * 3600
+	if math.Abs(route.Duration.Seconds()-expected) > 1 {
+		t.Fatalf("expected ~%.0fs got %v", expected, route.Duration)
+	}
+}
+
+func TestRouteErrors(t *testing.T) {
+	graph, err := Load(strings.NewReader(riverGraph))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	router := NewRouter(graph)
+
+	if _, err := router.Route(context.Background(), -0.01, 0.009, 0, 0); !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("expected one-way edge to block the route, got %v", err)
+	}
+	if _, err := router.Route(context.Background(), 1, 1, 0, 0); !errors.Is(err, ErrOffNetwork) {
+		t.Fatalf("expected off-network error, got %v", err)
+	}
+}
+
+func TestAStarMatchesDijkstra(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	b := NewBuilder()
+	const size = 30
+	id := func(r, c int) string { return fmt.Sprintf("%d-%d", r, c) }
+	for r := 0; r < size; r++ {
+		for c := 0; c < size; c++ {
+			b.AddNode(id(r, c), 19.4+float64(r)*0.002, -99.1+float64(c)*0.002)
+		}
+	}
+	for r := 0; r < size; r++ {
+		for c := 0; c < size; c++ {
+			if c+1 < size {
+				if err := b.AddEdge(id(r, c), id(r, c+1), 0, 10+rng.Float64()*70, rng.Intn(5) == 0); err != nil {
+					t.Fatalf("edge: %v", err)
+				}
+			}
+			if r+1 < size {
+				if err := b.AddEdge(id(r, c), id(r+1, c), 0, 10+rng.Float64()*70, rng.Intn(5) == 0); err != nil {
+					t.Fatalf("edge: %v", err)
+				}
+			}
+		}
+	}
+	graph, err := b.Build()
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		src, dst := rng.Intn(graph.Len()), rng.Intn(graph.Len())
+		_, _, got, errA := graph.astar(context.Background(), src, dst)
+		want, reachable := dijkstra(graph, src, dst)
+		if !reachable {
+			if !errors.Is(errA, ErrNoRoute) {
+				t.Fatalf("expected no route between %d and %d, got %v", src, dst, errA)
+			}
+			continue
+		}
+		if errA != nil || math.Abs(got-want) > 1e-6 {
+			t.Fatalf("route %d->%d: astar %.3f dijkstra %.3f err %v", src, dst, got, want, errA)
+		}
+	}
+}
+
+func dijkstra(g *Graph, src, dst int) (float64, bool) {
+	cost := make([]float64, g.Len())
+	done := make([]bool, g.Len())
+	for i := range cost {
+		cost[i] = math.Inf(1)
+	}
+	cost[src] = 0
+	for {
+		cur := -1
+		for i := range cost {
+			if !done[i] && !math.IsInf(cost[i], 1) && (cur == -1 || cost[i] < cost[cur]) {
+				cur = i
+			}
+		}
+		if cur == -1 {
+			return 0, false
+		}
+		if cur == dst {
+			return cost[dst], true
+		}
+		done[cur] = true
+		for _, e := range g.adj[cur] {
+			if c := cost[cur] + e.seconds; c < cost[e.to] {
+				cost[e.to] = c
+			}
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"missing column": "from_id,from_lat,from_lon,to_id,to_lat\na,0,0,b,0\n",
+		"bad latitude":   "from_id,from_lat,from_lon,to_id,to_lat,to_lon\na,x,0,b,0,0\n",
+		"out of range":   "from_id,from_lat,from_lon,to_id,to_lat,to_lon\na,91,0,b,0,0\n",
+		"bad oneway":     "from_id,from_lat,from_lon,to_id,to_lat,to_lon,oneway\na,0,0,b,0,0.01,maybe\n",
+		"zero speed":     "from_id,from_lat,from_lon,to_id,to_lat,to_lon,speed_kmh\na,0,0,b,0,0.01,0\n",
+		"no edges":       "from_id,from_lat,from_lon,to_id,to_lat,to_lon\n",
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(strings.NewReader(doc)); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
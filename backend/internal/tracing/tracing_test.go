@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	exporter, restore := UseInMemory()
+	defer restore()
+
+	// 1.- Serve a failing route under the middleware with a W3C traceparent header.
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/trips/:id", func(c *gin.Context) {
+		_, span := Start(c.Request.Context(), "handler")
+		span.End()
+		c.Status(http.StatusBadGateway)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/trips/t1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// 2.- The server span joins the caller's trace, is named by template and carries the 5xx status.
+	spans := exporter.GetSpans()
+	server, ok := SpanNamed(spans, "GET /trips/:id")
+	if !ok {
+		t.Fatalf("server span missing: %+v", spans)
+	}
+	if server.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.SpanKind != trace.SpanKindServer {
+		t.Fatalf("server span did not continue the trace: %+v", server.SpanContext)
+	}
+	if server.Status.Code != codes.Error {
+		t.Fatalf("expected error status got %+v", server.Status)
+	}
+	handler, _ := SpanNamed(spans, "handler")
+	if handler.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatalf("handler span should be a child of the server span")
+	}
+}
+
+func TestEndRecordsErrors(t *testing.T) {
+	exporter, restore := UseInMemory()
+	defer restore()
+
+	_, span := StartSQL(context.Background(), "postgresql", "INSERT", "accepted_bids")
+	if err := End(span, errors.New("duplicate key")); err == nil {
+		t.Fatalf("End must return the error")
+	}
+	got, ok := SpanNamed(exporter.GetSpans(), "sql INSERT accepted_bids")
+	if !ok || got.Status.Code != codes.Error || len(got.Events) != 1 || got.SpanKind != trace.SpanKindClient || !hasAttribute(got.Attributes, "db.system", "postgresql") {
+		t.Fatalf("unexpected sql span %+v", got)
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}); !errors.Is(err, ErrUnknownExporter) {
+		t.Fatalf("expected ErrUnknownExporter got %v", err)
+	}
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone})
+	if err != nil || shutdown(context.Background()) != nil {
+		t.Fatalf("none exporter should be a no-op: %v", err)
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, key, value string) bool {
+	for _, kv := range attrs {
+		if string(kv.Key) == key {
+			return kv.Value.AsString() == value
+		}
+	}
+	return false
+}
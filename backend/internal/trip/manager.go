@@ -3,10 +3,16 @@ package trip
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"kage/backend/internal/contracts"
+	"kage/backend/internal/logging"
+	"kage/backend/internal/tracing"
 )
 
 // ErrInvalidTransition occurs when a lifecycle rule is violated.
@@ -38,35 +44,100 @@ type Manager struct {
 	repo  EventRepository
 	clock Clock
 	trips map[string]*tripState
+	zones map[string][]string
+	crew  map[string]contracts.TripParticipants
+
+	participants ParticipantStore
+	recorder     Recorder
+	logger       *slog.Logger
+	active       int
+}
+
+// Recorder receives trip lifecycle instrumentation.
+type Recorder interface {
+	ObserveTransition(to contracts.TripState)
+	SetActiveTrips(n int)
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) ObserveTransition(contracts.TripState) {}
+func (nopRecorder) SetActiveTrips(int)                    {}
+
+// ParticipantStore loads a trip's participants from its persisted accepted bid.
+type ParticipantStore interface {
+	ParticipantsForTrip(ctx context.Context, tripID string) (contracts.TripParticipants, bool, error)
+}
+
+// Option mutates Manager configuration.
+type Option func(*Manager)
+
+// WithRecorder reports every transition and the number of trips currently active.
+func WithRecorder(r Recorder) Option {
+	return func(m *Manager) {
+		if r != nil {
+			m.recorder = r
+		}
+	}
+}
+
+// WithLogger writes transitions to logger instead of slog.Default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Manager) { m.logger = logging.OrDefault(logger) }
+}
+
+// WithParticipantStore loads participants the manager has not cached, such as after a restart
+// or for trips accepted on another replica.
+func WithParticipantStore(store ParticipantStore) Option {
+	return func(m *Manager) { m.participants = store }
 }
 
 // NewManager constructs a Manager with the provided repository.
-func NewManager(repo EventRepository, clock Clock) *Manager {
+func NewManager(repo EventRepository, clock Clock, opts ...Option) *Manager {
 	if clock == nil {
 		clock = RealClock{}
 	}
-	return &Manager{
-		repo:  repo,
-		clock: clock,
-		trips: make(map[string]*tripState),
+	m := &Manager{
+		repo:     repo,
+		clock:    clock,
+		trips:    make(map[string]*tripState),
+		zones:    make(map[string][]string),
+		crew:     make(map[string]contracts.TripParticipants),
+		recorder: nopRecorder{},
+		logger:   slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	return m
 }
 
 // StartTrip marks the trip as active and records an event.
 func (m *Manager) StartTrip(ctx context.Context, tripID string) error {
+	ctx, span := tracing.Start(ctx, "trip.StartTrip", attribute.String("trip.id", tripID))
+	return tracing.End(span, m.startTrip(ctx, tripID))
+}
+
+func (m *Manager) startTrip(ctx context.Context, tripID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if _, exists := m.trips[tripID]; exists {
 		return ErrInvalidTransition
 	}
 	now := m.clock.Now()
-	st := &tripState{state: contracts.TripStateActive, startedAt: now, lastResumed: now}
+	st := &tripState{state: contracts.TripStatePending, startedAt: now, lastResumed: now}
 	m.trips[tripID] = st
+	m.setState(st, contracts.TripStateActive)
 	return m.persistEvent(ctx, tripID, contracts.TripStateActive, "trip started")
 }
 
 // PauseTrip transitions an active trip into the paused state.
 func (m *Manager) PauseTrip(ctx context.Context, tripID string) error {
+	ctx, span := tracing.Start(ctx, "trip.PauseTrip", attribute.String("trip.id", tripID))
+	return tracing.End(span, m.pauseTrip(ctx, tripID))
+}
+
+func (m *Manager) pauseTrip(ctx context.Context, tripID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	st, ok := m.trips[tripID]
@@ -76,12 +147,17 @@ func (m *Manager) PauseTrip(ctx context.Context, tripID string) error {
 	now := m.clock.Now()
 	st.totalActive += now.Sub(st.lastResumed)
 	st.lastPaused = now
-	st.state = contracts.TripStatePaused
+	m.setState(st, contracts.TripStatePaused)
 	return m.persistEvent(ctx, tripID, contracts.TripStatePaused, "trip paused")
 }
 
 // ResumeTrip moves a paused trip back to active.
 func (m *Manager) ResumeTrip(ctx context.Context, tripID string) error {
+	ctx, span := tracing.Start(ctx, "trip.ResumeTrip", attribute.String("trip.id", tripID))
+	return tracing.End(span, m.resumeTrip(ctx, tripID))
+}
+
+func (m *Manager) resumeTrip(ctx context.Context, tripID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	st, ok := m.trips[tripID]
@@ -91,16 +167,21 @@ func (m *Manager) ResumeTrip(ctx context.Context, tripID string) error {
 	now := m.clock.Now()
 	st.totalPaused += now.Sub(st.lastPaused)
 	st.lastResumed = now
-	st.state = contracts.TripStateActive
+	m.setState(st, contracts.TripStateActive)
 	return m.persistEvent(ctx, tripID, contracts.TripStateActive, "trip resumed")
 }
 
 // CancelTrip stops the trip permanently.
 func (m *Manager) CancelTrip(ctx context.Context, tripID string) error {
+	ctx, span := tracing.Start(ctx, "trip.CancelTrip", attribute.String("trip.id", tripID))
+	return tracing.End(span, m.cancelTrip(ctx, tripID))
+}
+
+func (m *Manager) cancelTrip(ctx context.Context, tripID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	st, ok := m.trips[tripID]
-	if !ok || st.state == contracts.TripStateComplete || st.state == contracts.TripStateCanceled {
+	if !ok || finished(st.state) {
 		return ErrInvalidTransition
 	}
 	now := m.clock.Now()
@@ -110,12 +191,18 @@ func (m *Manager) CancelTrip(ctx context.Context, tripID string) error {
 	if st.state == contracts.TripStatePaused {
 		st.totalPaused += now.Sub(st.lastPaused)
 	}
-	st.state = contracts.TripStateCanceled
+	m.setState(st, contracts.TripStateCanceled)
+	m.forget(tripID)
 	return m.persistEvent(ctx, tripID, contracts.TripStateCanceled, "trip canceled")
 }
 
 // CompleteTrip finalizes the trip and stores a completion event.
 func (m *Manager) CompleteTrip(ctx context.Context, tripID string) error {
+	ctx, span := tracing.Start(ctx, "trip.CompleteTrip", attribute.String("trip.id", tripID))
+	return tracing.End(span, m.completeTrip(ctx, tripID))
+}
+
+func (m *Manager) completeTrip(ctx context.Context, tripID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	st, ok := m.trips[tripID]
@@ -124,15 +211,81 @@ func (m *Manager) CompleteTrip(ctx context.Context, tripID string) error {
 	}
 	now := m.clock.Now()
 	st.totalActive += now.Sub(st.lastResumed)
-	st.state = contracts.TripStateComplete
+	m.setState(st, contracts.TripStateComplete)
+	m.forget(tripID)
 	return m.persistEvent(ctx, tripID, contracts.TripStateComplete, "trip completed")
 }
 
+// TagZones records the distinct geofence ids the trip's pickup falls into; no ids clears the tags.
+func (m *Manager) TagZones(tripID string, zoneIDs []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tags []string
+	for _, id := range zoneIDs {
+		if !slices.Contains(tags, id) {
+			tags = append(tags, id)
+		}
+	}
+	if len(tags) == 0 {
+		delete(m.zones, tripID)
+		return
+	}
+	m.zones[tripID] = tags
+}
+
+// AssignParticipants records the rider and the driver whose bid won the trip.
+func (m *Manager) AssignParticipants(tripID string, p contracts.TripParticipants) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.crew[tripID] = p
+}
+
+// Participants returns who rides and drives the trip, if a bid has been accepted for it.
+// Misses fall back to the participant store; a failing lookup reports no participants.
+func (m *Manager) Participants(ctx context.Context, tripID string) (contracts.TripParticipants, bool) {
+	// 1.- Serve trips whose bid was accepted here and that are still running.
+	m.mu.RLock()
+	p, ok := m.crew[tripID]
+	m.mu.RUnlock()
+	if ok || m.participants == nil {
+		return p, ok
+	}
+
+	// 2.- Load the persisted accepted bid instead.
+	p, ok, err := m.participants.ParticipantsForTrip(ctx, tripID)
+	if err != nil {
+		m.logger.LogAttrs(logging.WithTripID(ctx, tripID), slog.LevelWarn, "load trip participants failed", slog.String("error", err.Error()))
+		return contracts.TripParticipants{}, false
+	}
+	if !ok {
+		return p, false
+	}
+
+	// 3.- Cache them only while this manager runs the trip, so finished trips are not kept.
+	m.mu.Lock()
+	if st, running := m.trips[tripID]; running && !finished(st.state) {
+		m.crew[tripID] = p
+	}
+	m.mu.Unlock()
+	return p, true
+}
+
+// forget drops per-trip lookups once the trip has ended. Callers hold m.mu.
+func (m *Manager) forget(tripID string) {
+	delete(m.crew, tripID)
+	delete(m.zones, tripID)
+}
+
+func finished(state contracts.TripState) bool {
+	return state == contracts.TripStateComplete || state == contracts.TripStateCanceled
+}
+
 // Metrics describes durations for auditing.
 type Metrics struct {
 	TotalActive time.Duration
 	TotalPaused time.Duration
 	StartedAt   time.Time
+	ZoneIDs     []string `json:",omitempty"`
 }
 
 // MetricsFor retrieves aggregated trip timing metrics.
@@ -147,13 +300,33 @@ func (m *Manager) MetricsFor(tripID string) (Metrics, bool) {
 		TotalActive: st.totalActive,
 		TotalPaused: st.totalPaused,
 		StartedAt:   st.startedAt,
+		ZoneIDs:     append([]string(nil), m.zones[tripID]...),
 	}, true
 }
 
+// setState applies a transition and reports it. Callers hold m.mu.
+func (m *Manager) setState(st *tripState, to contracts.TripState) {
+	if st.state == contracts.TripStateActive {
+		m.active--
+	}
+	if to == contracts.TripStateActive {
+		m.active++
+	}
+	st.state = to
+	m.recorder.ObserveTransition(to)
+	m.recorder.SetActiveTrips(m.active)
+}
+
 func (m *Manager) persistEvent(ctx context.Context, tripID string, state contracts.TripState, notes string) error {
+	ctx = logging.WithTripID(ctx, tripID)
+	m.logger.LogAttrs(ctx, slog.LevelInfo, notes, slog.String("state", string(state)))
 	if m.repo == nil {
 		return nil
 	}
 	event := contracts.TripEvent{TripID: tripID, State: state, OccurredAt: m.clock.Now(), Notes: notes}
-	return m.repo.RecordEvent(ctx, event)
+	if err := m.repo.RecordEvent(ctx, event); err != nil {
+		m.logger.LogAttrs(ctx, slog.LevelWarn, "record trip event failed", slog.String("state", string(state)), slog.String("error", err.Error()))
+		return err
+	}
+	return nil
 }
@@ -1,11 +1,16 @@
 package trip
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"testing"
 	"time"
 
 	"kage/backend/internal/contracts"
+	"kage/backend/internal/logging"
 )
 
 type recordingRepo struct {
@@ -83,3 +88,158 @@ func TestInvalidTransitions(t *testing.T) {
 		t.Fatalf("expected invalid transition error")
 	}
 }
+
+func TestAssignParticipants(t *testing.T) {
+	mgr := NewManager(nil, nil)
+	if _, ok := mgr.Participants(context.Background(), "t1"); ok {
+		t.Fatalf("expected no participants before a bid is accepted")
+	}
+	mgr.AssignParticipants("t1", contracts.TripParticipants{RiderID: "r1", DriverID: "d1"})
+	p, ok := mgr.Participants(context.Background(), "t1")
+	if !ok || !p.Includes("r1") || !p.Includes("d1") || p.Includes("d2") || p.Includes("") {
+		t.Fatalf("unexpected participants %+v", p)
+	}
+}
+
+// participantStore serves persisted participants and counts lookups.
+type participantStore struct {
+	rows    map[string]contracts.TripParticipants
+	err     error
+	lookups int
+}
+
+func (s *participantStore) ParticipantsForTrip(_ context.Context, tripID string) (contracts.TripParticipants, bool, error) {
+	s.lookups++
+	p, ok := s.rows[tripID]
+	return p, ok, s.err
+}
+
+func TestParticipantsFallBackToStore(t *testing.T) {
+	crew := contracts.TripParticipants{RiderID: "r1", DriverID: "d1"}
+	store := &participantStore{rows: map[string]contracts.TripParticipants{"t1": crew}}
+	mgr := NewManager(nil, nil, WithParticipantStore(store))
+	ctx := context.Background()
+
+	// 1.- A trip accepted before a restart or on another replica is loaded from the store.
+	if p, ok := mgr.Participants(ctx, "t1"); !ok || p != crew {
+		t.Fatalf("expected stored participants got %+v %v", p, ok)
+	}
+	if _, ok := mgr.Participants(ctx, "t2"); ok {
+		t.Fatalf("expected no participants for a trip without an accepted bid")
+	}
+
+	// 2.- Only trips running here are cached; ending one forgets it again.
+	if err := mgr.StartTrip(ctx, "t1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	before := store.lookups
+	mgr.Participants(ctx, "t1")
+	mgr.Participants(ctx, "t1")
+	if store.lookups != before+1 {
+		t.Fatalf("expected one lookup for a running trip got %d", store.lookups-before)
+	}
+	mgr.TagZones("t1", []string{"city"})
+	if err := mgr.CompleteTrip(ctx, "t1"); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if len(mgr.crew) != 0 || len(mgr.zones) != 0 {
+		t.Fatalf("expected completed trip forgotten got crew %v zones %v", mgr.crew, mgr.zones)
+	}
+
+	// 3.- A failing store denies rather than erroring out.
+	store.err = errors.New("database down")
+	if _, ok := mgr.Participants(ctx, "t1"); ok {
+		t.Fatalf("expected lookup failure to deny")
+	}
+}
+
+func TestCancelForgetsParticipants(t *testing.T) {
+	mgr := NewManager(nil, nil)
+	if err := mgr.StartTrip(context.Background(), "t1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	mgr.AssignParticipants("t1", contracts.TripParticipants{RiderID: "r1", DriverID: "d1"})
+	if err := mgr.CancelTrip(context.Background(), "t1"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if _, ok := mgr.Participants(context.Background(), "t1"); ok {
+		t.Fatalf("expected canceled trip forgotten")
+	}
+}
+
+func TestTagZonesDedupesAndEvicts(t *testing.T) {
+	mgr := NewManager(nil, nil)
+	if err := mgr.StartTrip(context.Background(), "t1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	// 1.- Repeated ids are stored once.
+	mgr.TagZones("t1", []string{"city", "airport", "city"})
+	if m, _ := mgr.MetricsFor("t1"); len(m.ZoneIDs) != 2 || m.ZoneIDs[0] != "city" || m.ZoneIDs[1] != "airport" {
+		t.Fatalf("expected distinct zones got %v", m.ZoneIDs)
+	}
+
+	// 2.- Retagging with no zones drops the entry instead of keeping an empty one.
+	mgr.TagZones("t1", nil)
+	if _, ok := mgr.zones["t1"]; ok {
+		t.Fatalf("expected empty zone tags to be evicted")
+	}
+}
+
+type countingRecorder struct {
+	transitions map[contracts.TripState]int
+	active      int
+}
+
+func (r *countingRecorder) ObserveTransition(to contracts.TripState) { r.transitions[to]++ }
+func (r *countingRecorder) SetActiveTrips(n int)                     { r.active = n }
+
+func TestManagerRecordsTransitions(t *testing.T) {
+	// 1.- Move two trips through different paths.
+	rec := &countingRecorder{transitions: make(map[contracts.TripState]int)}
+	m := NewManager(nil, nil, WithRecorder(rec))
+	ctx := context.Background()
+	for _, step := range []func() error{
+		func() error { return m.StartTrip(ctx, "a") },
+		func() error { return m.StartTrip(ctx, "b") },
+		func() error { return m.PauseTrip(ctx, "a") },
+		func() error { return m.ResumeTrip(ctx, "a") },
+		func() error { return m.CompleteTrip(ctx, "a") },
+		func() error { return m.PauseTrip(ctx, "b") },
+	} {
+		if err := step(); err != nil {
+			t.Fatalf("step failed: %v", err)
+		}
+	}
+
+	// 2.- Rejected transitions are not counted and paused trips are not active.
+	if err := m.ResumeTrip(ctx, "a"); err == nil {
+		t.Fatalf("expected invalid transition")
+	}
+	if rec.transitions[contracts.TripStateActive] != 3 || rec.transitions[contracts.TripStatePaused] != 2 || rec.transitions[contracts.TripStateComplete] != 1 {
+		t.Fatalf("unexpected transitions %v", rec.transitions)
+	}
+	if rec.active != 0 {
+		t.Fatalf("expected no active trips got %d", rec.active)
+	}
+	if err := m.CancelTrip(ctx, "b"); err != nil || rec.transitions[contracts.TripStateCanceled] != 1 || rec.active != 0 {
+		t.Fatalf("cancel not recorded: %v %v %d", err, rec.transitions, rec.active)
+	}
+}
+
+func TestTransitionLogsCarryCorrelationIDs(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewManager(nil, nil, WithLogger(logging.New(&buf, new(slog.LevelVar))))
+	ctx := logging.WithPrincipal(logging.WithRequestID(context.Background(), "req-9"), "driver-1")
+
+	if err := m.StartTrip(ctx, "trip-log"); err != nil {
+		t.Fatalf("start trip: %v", err)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode log: %v", err)
+	}
+	if record[logging.KeyTripID] != "trip-log" || record[logging.KeyRequestID] != "req-9" || record[logging.KeyPrincipal] != "driver-1" || record["state"] != string(contracts.TripStateActive) {
+		t.Fatalf("unexpected log record %v", record)
+	}
+}
@@ -5,28 +5,61 @@ import (
 	"database/sql"
 
 	"kage/backend/internal/contracts"
+	"kage/backend/internal/dialect"
+	"kage/backend/internal/outbox"
+	"kage/backend/internal/tracing"
 )
 
+// TopicTripEvent is the outbox topic announcing a persisted trip transition.
+const TopicTripEvent = "trip.event"
+
 // EventRepository persists trip lifecycle events.
 type EventRepository interface {
 	RecordEvent(ctx context.Context, event contracts.TripEvent) error
 }
 
-// SQLEventRepository writes trip events using database/sql.
+// SQLEventRepository writes trip events to MySQL/MariaDB or PostgreSQL using database/sql.
 type SQLEventRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect dialect.Dialect
+	insert  string
 }
 
-// NewSQLEventRepository builds a repository backed by MariaDB.
-func NewSQLEventRepository(db *sql.DB) *SQLEventRepository {
-	return &SQLEventRepository{db: db}
+// NewSQLEventRepository builds a repository for the given database handle and its dialect.
+func NewSQLEventRepository(db *sql.DB, d dialect.Dialect) *SQLEventRepository {
+	return &SQLEventRepository{
+		db:      db,
+		dialect: d,
+		insert: d.Upsert("trip_events",
+			[]string{"trip_id", "state", "occurred_at", "notes"},
+			[]string{"trip_id", "occurred_at", "state"},
+			nil),
+	}
 }
 
-// RecordEvent inserts a trip event row.
-func (r *SQLEventRepository) RecordEvent(ctx context.Context, event contracts.TripEvent) error {
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO trip_events (trip_id, state, occurred_at, notes) VALUES (?, ?, ?, ?)`,
-		event.TripID, event.State, event.OccurredAt, event.Notes,
-	)
-	return err
+// RecordEvent inserts a trip event row; recording the same event twice keeps the first row.
+// A new row enqueues a trip.event outbox message in the same transaction.
+func (r *SQLEventRepository) RecordEvent(ctx context.Context, event contracts.TripEvent) (err error) {
+	ctx, span := tracing.StartSQL(ctx, r.dialect.Name(), "INSERT", "trip_events")
+	defer func() { tracing.End(span, err) }()
+	msg, err := outbox.NewMessage(TopicTripEvent, event.TripID, event, event.OccurredAt)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	res, err := tx.ExecContext(ctx, r.insert, event.TripID, event.State, event.OccurredAt, event.Notes)
+	if err != nil {
+		return err
+	}
+	// A replayed event stays silent; drivers that cannot count rows notify anyway.
+	if n, countErr := res.RowsAffected(); countErr != nil || n > 0 {
+		if err := outbox.Enqueue(ctx, tx, r.dialect, msg); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
@@ -2,44 +2,100 @@ package trip
 
 import (
 	"context"
+	"errors"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"kage/backend/internal/contracts"
+	"kage/backend/internal/dialect"
 )
 
 func TestSQLEventRepositoryRecordEvent(t *testing.T) {
-	//1.- Prepare the sqlmock database connection to capture executed queries.
+	tests := []struct {
+		dialect dialect.Dialect
+		query   string
+		outbox  string
+	}{
+		{dialect.MySQL,
+			`INSERT INTO trip_events (trip_id, state, occurred_at, notes) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE trip_id = trip_id`,
+			`INSERT INTO outbox (id, topic, message_key, payload, created_at, status, attempts, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, 0, ?)`},
+		{dialect.Postgres,
+			`INSERT INTO trip_events (trip_id, state, occurred_at, notes) VALUES ($1, $2, $3, $4) ON CONFLICT (trip_id, occurred_at, state) DO NOTHING`,
+			`INSERT INTO outbox (id, topic, message_key, payload, created_at, status, attempts, next_attempt_at) VALUES ($1, $2, $3, $4, $5, $6, 0, $7)`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.dialect.Name(), func(t *testing.T) {
+			//1.- Prepare the sqlmock database connection to capture executed queries.
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			repo := NewSQLEventRepository(db, tc.dialect)
+			event := contracts.TripEvent{
+				TripID:     "trip-456",
+				State:      contracts.TripStateComplete,
+				OccurredAt: time.Unix(1735689600, 0).UTC(),
+				Notes:      "fare settled",
+			}
+
+			//2.- Expect the idempotent insert and its outbox row in one transaction.
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(tc.query)).
+				WithArgs(
+					event.TripID,
+					event.State,
+					event.OccurredAt,
+					event.Notes,
+				).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta(tc.outbox)).
+				WithArgs(sqlmock.AnyArg(), TopicTripEvent, event.TripID,
+					`{"trip_id":"trip-456","state":"complete","occurred_at":"2025-01-01T00:00:00Z","notes":"fare settled"}`,
+					event.OccurredAt, "pending", event.OccurredAt).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			if err := repo.RecordEvent(context.Background(), event); err != nil {
+				t.Fatalf("RecordEvent: %v", err)
+			}
+
+			//3.- A replayed event writes nothing new and enqueues nothing.
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(tc.query)).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+			if err := repo.RecordEvent(context.Background(), event); err != nil {
+				t.Fatalf("RecordEvent replay: %v", err)
+			}
+
+			//4.- Ensure the mocked expectations were satisfied once the calls complete.
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestSQLEventRepositoryRollsBackWithoutOutboxRow(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("create sqlmock: %v", err)
 	}
 	defer db.Close()
 
-	repo := NewSQLEventRepository(db)
-	event := contracts.TripEvent{
-		TripID:     "trip-456",
-		State:      contracts.TripStateComplete,
-		OccurredAt: time.Unix(1735689600, 0).UTC(),
-		Notes:      "fare settled",
-	}
+	//1.- A failed outbox insert must undo the event so neither exists without the other.
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO trip_events`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO outbox`).WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
 
-	//2.- Expect the INSERT into trip_events with the event payload.
-	mock.ExpectExec(`INSERT INTO trip_events \(trip_id, state, occurred_at, notes\) VALUES \(\?, \?, \?, \?\)`).
-		WithArgs(
-			event.TripID,
-			event.State,
-			event.OccurredAt,
-			event.Notes,
-		).
-		WillReturnResult(sqlmock.NewResult(0, 1))
-
-	if err := repo.RecordEvent(context.Background(), event); err != nil {
-		t.Fatalf("RecordEvent: %v", err)
+	event := contracts.TripEvent{TripID: "trip-456", State: contracts.TripStateActive, OccurredAt: time.Now().UTC()}
+	if err := NewSQLEventRepository(db, dialect.MySQL).RecordEvent(context.Background(), event); err == nil {
+		t.Fatalf("expected outbox failure to surface")
 	}
-
-	//3.- Ensure the mocked expectations were satisfied once the call completes.
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("unmet expectations: %v", err)
 	}
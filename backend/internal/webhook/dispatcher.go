@@ -0,0 +1,302 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/logging"
+	"kage/backend/internal/outbox"
+	"kage/backend/internal/trip"
+)
+
+// DriverLookup resolves the driver assigned to a trip so trip events can be filtered by driver.
+type DriverLookup interface {
+	DriverForTrip(ctx context.Context, tripID string) (string, error)
+}
+
+// Dispatcher turns outbox messages into deliveries and sends them to partner endpoints.
+type Dispatcher struct {
+	store        Store
+	drivers      DriverLookup
+	client       *http.Client
+	batchSize    int
+	pollInterval time.Duration
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	lease        time.Duration
+	now          func() time.Time
+	logger       *slog.Logger
+}
+
+// Option mutates Dispatcher configuration.
+type Option func(*Dispatcher)
+
+// WithDriverLookup enables driver filters on trip events.
+func WithDriverLookup(drivers DriverLookup) Option {
+	return func(d *Dispatcher) { d.drivers = drivers }
+}
+
+// WithHTTPClient replaces the default client, which times out after 10 seconds.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		if client != nil {
+			d.client = client
+		}
+	}
+}
+
+// WithPollInterval sets the pause between polls that found nothing left to do.
+func WithPollInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		if interval > 0 {
+			d.pollInterval = interval
+		}
+	}
+}
+
+// WithMaxAttempts sets how many failed attempts mark a delivery failed.
+func WithMaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.maxAttempts = n
+		}
+	}
+}
+
+// WithBackoff doubles the retry delay from base after every failure, capped at max.
+func WithBackoff(base, max time.Duration) Option {
+	return func(d *Dispatcher) {
+		if base > 0 && max >= base {
+			d.baseBackoff, d.maxBackoff = base, max
+		}
+	}
+}
+
+// WithClock injects the time source used for scheduling and signing.
+func WithClock(now func() time.Time) Option {
+	return func(d *Dispatcher) { d.now = now }
+}
+
+// WithLogger reports failed attempts.
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Dispatcher) { d.logger = logging.OrDefault(logger) }
+}
+
+// NewDispatcher builds a dispatcher over store.
+func NewDispatcher(store Store, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:        store,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		batchSize:    50,
+		pollInterval: time.Second,
+		maxAttempts:  8,
+		baseBackoff:  5 * time.Second,
+		maxBackoff:   time.Hour,
+		lease:        time.Minute,
+		now:          time.Now,
+		logger:       slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Name identifies the dispatcher as an outbox sink.
+func (d *Dispatcher) Name() string { return "webhooks" }
+
+// Publish stores a delivery for every subscription matching msg. It never calls partners itself,
+// so a slow endpoint cannot hold up the outbox; Run sends the deliveries.
+func (d *Dispatcher) Publish(ctx context.Context, msg outbox.Message) error {
+	// 1.- Name the event and find the driver it concerns.
+	event, driverID, err := d.describe(ctx, msg)
+	if err != nil || event == "" {
+		return err
+	}
+
+	// 2.- Fan out to the matching subscriptions.
+	subs, err := d.store.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	now := d.now().UTC()
+	var deliveries []Delivery
+	for _, sub := range subs {
+		if !sub.Matches(event, driverID) {
+			continue
+		}
+		deliveries = append(deliveries, Delivery{
+			ID: NewID(), SubscriptionID: sub.ID, MessageID: msg.ID, Event: event, Payload: msg.Payload,
+			Status: StatusPending, NextAttemptAt: now, CreatedAt: now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return d.store.EnqueueDeliveries(ctx, deliveries)
+}
+
+// describe maps an outbox message onto a partner event; unknown topics yield an empty event.
+func (d *Dispatcher) describe(ctx context.Context, msg outbox.Message) (event, driverID string, err error) {
+	switch msg.Topic {
+	case bidding.TopicBidAccepted:
+		var bid struct {
+			DriverID string `json:"driver_id"`
+		}
+		if err := json.Unmarshal(msg.Payload, &bid); err != nil {
+			return "", "", fmt.Errorf("decode %s: %w", msg.Topic, err)
+		}
+		return EventBidAccepted, bid.DriverID, nil
+	case trip.TopicTripEvent:
+		var ev struct {
+			State string `json:"state"`
+		}
+		if err := json.Unmarshal(msg.Payload, &ev); err != nil {
+			return "", "", fmt.Errorf("decode %s: %w", msg.Topic, err)
+		}
+		if d.drivers != nil {
+			if driverID, err = d.drivers.DriverForTrip(ctx, msg.Key); err != nil {
+				return "", "", fmt.Errorf("look up driver of trip %s: %w", msg.Key, err)
+			}
+		}
+		return "trip." + ev.State, driverID, nil
+	}
+	return "", "", nil
+}
+
+// Run sends due deliveries until ctx is done, draining full batches without waiting.
+func (d *Dispatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		n, err := d.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.WarnContext(ctx, "webhook dispatch poll failed", "error", err)
+		}
+		wait := d.pollInterval
+		if err == nil && n == d.batchSize {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// RunOnce attempts one batch of due deliveries and returns how many it attempted.
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	now := d.now()
+	due, err := d.store.DueDeliveries(ctx, now, d.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("list due webhook deliveries: %w", err)
+	}
+	attempted := 0
+	for _, delivery := range due {
+		ok, err := d.store.ClaimDelivery(ctx, delivery, now.Add(d.lease))
+		if err != nil {
+			return attempted, fmt.Errorf("claim webhook delivery %s: %w", delivery.ID, err)
+		}
+		if !ok {
+			continue
+		}
+		delivery.Attempts++
+		attempted++
+		if err := d.store.RecordAttempt(ctx, d.attempt(ctx, delivery)); err != nil {
+			return attempted, fmt.Errorf("record webhook delivery %s: %w", delivery.ID, err)
+		}
+	}
+	return attempted, nil
+}
+
+// attempt sends one delivery and returns it updated with the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) Delivery {
+	// 1.- Deliveries outlive removed or paused subscriptions; give up on them.
+	sub, err := d.store.GetSubscription(ctx, delivery.SubscriptionID)
+	switch {
+	case errors.Is(err, ErrNotFound) || (err == nil && !sub.Active):
+		delivery.Status, delivery.LastStatus, delivery.LastError = StatusFailed, 0, "subscription removed or inactive"
+		return delivery
+	case err != nil:
+		return d.fail(ctx, delivery, 0, err)
+	}
+
+	// 2.- Sign and send.
+	status, err := d.send(ctx, sub, delivery)
+	if err != nil {
+		return d.fail(ctx, delivery, status, err)
+	}
+	delivery.Status, delivery.LastStatus, delivery.LastError, delivery.DeliveredAt = StatusSucceeded, status, "", d.now().UTC()
+	return delivery
+}
+
+// fail schedules a retry with exponential backoff, or marks the delivery failed after the last attempt.
+func (d *Dispatcher) fail(ctx context.Context, delivery Delivery, status int, err error) Delivery {
+	delivery.LastStatus, delivery.LastError = status, err.Error()
+	attrs := []any{"delivery_id", delivery.ID, "subscription_id", delivery.SubscriptionID, "event", delivery.Event, "attempt", delivery.Attempts, "error", err}
+	if delivery.Attempts >= d.maxAttempts {
+		delivery.Status = StatusFailed
+		d.logger.ErrorContext(ctx, "webhook delivery failed permanently", attrs...)
+		return delivery
+	}
+	delay := d.backoff(delivery.Attempts)
+	delivery.Status, delivery.NextAttemptAt = StatusPending, d.now().UTC().Add(delay)
+	d.logger.WarnContext(ctx, "webhook delivery failed", append(attrs, "retry_in", delay.String())...)
+	return delivery
+}
+
+// send POSTs the signed envelope; any non-2xx status is an error.
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, delivery Delivery) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"id": delivery.MessageID, "event": delivery.Event, "created_at": delivery.CreatedAt, "data": delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kage-webhooks/1")
+	req.Header.Set(HeaderID, delivery.ID)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, body))
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(res.Body, 256))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("endpoint answered %d: %s", res.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return res.StatusCode, nil
+}
+
+// backoff is the delay after the given failed attempt: base, 2*base, 4*base, ... up to max.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.baseBackoff
+	for i := 1; i < attempt && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.maxBackoff {
+		delay = d.maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"kage/backend/internal/bidding"
+	"kage/backend/internal/outbox"
+	"kage/backend/internal/trip"
+)
+
+type driverMap map[string]string
+
+func (m driverMap) DriverForTrip(_ context.Context, tripID string) (string, error) {
+	return m[tripID], nil
+}
+
+// receiver records signed deliveries and answers with the queued statuses, then 204.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	now      func() time.Time
+	mu       sync.Mutex
+	statuses []int
+	events   []string
+	bodies   []map[string]json.RawMessage
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := Verify(rc.secret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Minute, rc.now()); err != nil {
+		rc.t.Errorf("verify delivery %s: %v", r.Header.Get(HeaderID), err)
+	}
+	var envelope map[string]json.RawMessage
+	_ = json.Unmarshal(body, &envelope)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.events = append(rc.events, r.Header.Get(HeaderEvent))
+	rc.bodies = append(rc.bodies, envelope)
+	status := http.StatusNoContent
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestDispatcherSignsAndRetries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1735689600, 0).UTC()
+	clock := func() time.Time { return now }
+	rc := &receiver{t: t, secret: "0123456789abcdef", now: clock, statuses: []int{http.StatusInternalServerError}}
+	partner := httptest.NewServer(rc)
+	defer partner.Close()
+
+	store := NewMemoryStore()
+	_ = store.CreateSubscription(ctx, Subscription{ID: "s1", URL: partner.URL, Secret: rc.secret, Events: []string{EventBidAccepted}, Active: true})
+	dispatcher := NewDispatcher(store, WithHTTPClient(partner.Client()), WithClock(clock), WithBackoff(time.Second, time.Minute))
+
+	// 1.- Publishing only records a pending delivery; partners are called by RunOnce.
+	msg, _ := outbox.NewMessage(bidding.TopicBidAccepted, "t1", map[string]string{"bid_id": "b1", "driver_id": "d1"}, now)
+	if err := dispatcher.Publish(ctx, msg); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := dispatcher.Publish(ctx, msg); err != nil {
+		t.Fatalf("republish: %v", err)
+	}
+	if len(rc.events) != 0 {
+		t.Fatalf("expected no calls before dispatching")
+	}
+
+	// 2.- A 500 schedules a retry after the base backoff.
+	if n, err := dispatcher.RunOnce(ctx); n != 1 || err != nil {
+		t.Fatalf("expected one attempt got %d %v", n, err)
+	}
+	log, _ := store.ListDeliveries(ctx, "s1", "", 10)
+	if len(log) != 1 || log[0].Status != StatusPending || log[0].LastStatus != http.StatusInternalServerError || !log[0].NextAttemptAt.Equal(now.Add(time.Second)) {
+		t.Fatalf("unexpected delivery after failure %+v", log)
+	}
+	if n, _ := dispatcher.RunOnce(ctx); n != 0 {
+		t.Fatalf("expected nothing due during backoff got %d", n)
+	}
+
+	// 3.- The retry succeeds and carries the same message id for partner de-duplication.
+	now = now.Add(time.Second)
+	if n, err := dispatcher.RunOnce(ctx); n != 1 || err != nil {
+		t.Fatalf("expected retry got %d %v", n, err)
+	}
+	log, _ = store.ListDeliveries(ctx, "s1", StatusSucceeded, 10)
+	if len(log) != 1 || log[0].Attempts != 2 || !log[0].DeliveredAt.Equal(now) {
+		t.Fatalf("unexpected delivery after retry %+v", log)
+	}
+	if len(rc.bodies) != 2 || string(rc.bodies[1]["id"]) != `"`+msg.ID+`"` || string(rc.bodies[1]["data"]) != string(msg.Payload) {
+		t.Fatalf("unexpected envelopes %v", rc.bodies)
+	}
+}
+
+func TestDispatcherFiltersByEventAndDriver(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1735689600, 0).UTC()
+	clock := func() time.Time { return now }
+	rc := &receiver{t: t, secret: "0123456789abcdef", now: clock}
+	partner := httptest.NewServer(rc)
+	defer partner.Close()
+
+	store := NewMemoryStore()
+	_ = store.CreateSubscription(ctx, Subscription{ID: "all", URL: partner.URL, Secret: rc.secret, Events: Events, Active: true})
+	_ = store.CreateSubscription(ctx, Subscription{ID: "d2-only", URL: partner.URL, Secret: rc.secret, Events: []string{EventTripComplete}, DriverIDs: []string{"d2"}, Active: true})
+	_ = store.CreateSubscription(ctx, Subscription{ID: "paused", URL: partner.URL, Secret: rc.secret, Events: Events, Active: false})
+	dispatcher := NewDispatcher(store, WithHTTPClient(partner.Client()), WithClock(clock), WithDriverLookup(driverMap{"t1": "d1", "t2": "d2"}))
+
+	// 1.- Trip events take the driver from the lookup and the event name from the state.
+	for _, tripID := range []string{"t1", "t2"} {
+		msg, _ := outbox.NewMessage(trip.TopicTripEvent, tripID, map[string]string{"trip_id": tripID, "state": "complete"}, now)
+		if err := dispatcher.Publish(ctx, msg); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	// 2.- Unknown topics are ignored.
+	other, _ := outbox.NewMessage("driver.rated", "d1", map[string]int{"stars": 5}, now)
+	if err := dispatcher.Publish(ctx, other); err != nil {
+		t.Fatalf("publish unknown topic: %v", err)
+	}
+
+	for id, want := range map[string]int{"all": 2, "d2-only": 1, "paused": 0} {
+		if log, _ := store.ListDeliveries(ctx, id, "", 10); len(log) != want {
+			t.Fatalf("expected %d deliveries for %s got %+v", want, id, log)
+		}
+	}
+	if n, err := dispatcher.RunOnce(ctx); n != 3 || err != nil {
+		t.Fatalf("expected three attempts got %d %v", n, err)
+	}
+	for _, event := range rc.events {
+		if event != EventTripComplete {
+			t.Fatalf("unexpected event header %q", event)
+		}
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1735689600, 0).UTC()
+	partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer partner.Close()
+	store := NewMemoryStore()
+	_ = store.CreateSubscription(ctx, Subscription{ID: "s1", URL: partner.URL, Secret: "0123456789abcdef", Events: Events, Active: true})
+	_ = store.EnqueueDeliveries(ctx, []Delivery{{ID: "d1", SubscriptionID: "s1", MessageID: "m1", Event: EventBidAccepted, Status: StatusPending, NextAttemptAt: now}})
+	dispatcher := NewDispatcher(store, WithHTTPClient(partner.Client()), WithClock(func() time.Time { return now }), WithMaxAttempts(2), WithBackoff(time.Second, time.Second))
+
+	// 1.- The last allowed attempt marks the delivery failed.
+	for i := 0; i < 2; i++ {
+		_, _ = dispatcher.RunOnce(ctx)
+		now = now.Add(time.Second)
+	}
+	d, _ := store.GetDelivery(ctx, "s1", "d1")
+	if d.Status != StatusFailed || d.Attempts != 2 || d.LastStatus != http.StatusBadGateway {
+		t.Fatalf("unexpected delivery %+v", d)
+	}
+
+	// 2.- Replay resets the budget so operators can retry after the partner recovers.
+	d, _ = store.ReplayDelivery(ctx, "s1", "d1", now)
+	if d.Status != StatusPending || d.Attempts != 0 {
+		t.Fatalf("unexpected replay %+v", d)
+	}
+}
+
+func TestBackoffDoublesUpToCap(t *testing.T) {
+	d := NewDispatcher(NewMemoryStore(), WithBackoff(time.Second, 5*time.Second))
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := d.backoff(attempt); got != want {
+			t.Fatalf("attempt %d: expected %v got %v", attempt, want, got)
+		}
+	}
+}
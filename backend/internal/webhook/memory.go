@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps subscriptions and deliveries in process memory.
+type MemoryStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]Subscription
+	deliveries    map[string]Delivery
+}
+
+// NewMemoryStore constructs an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{subscriptions: make(map[string]Subscription), deliveries: make(map[string]Delivery)}
+}
+
+func (s *MemoryStore) CreateSubscription(_ context.Context, sub Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[sub.ID] = cloneSubscription(sub)
+	return nil
+}
+
+func (s *MemoryStore) GetSubscription(_ context.Context, id string) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	return cloneSubscription(sub), nil
+}
+
+// ListSubscriptions returns subscriptions oldest first.
+func (s *MemoryStore) ListSubscriptions(_ context.Context) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		out = append(out, cloneSubscription(sub))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+func (s *MemoryStore) UpdateSubscription(_ context.Context, sub Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscriptions[sub.ID]; !ok {
+		return ErrNotFound
+	}
+	s.subscriptions[sub.ID] = cloneSubscription(sub)
+	return nil
+}
+
+func (s *MemoryStore) DeleteSubscription(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscriptions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.subscriptions, id)
+	for key, d := range s.deliveries {
+		if d.SubscriptionID == id {
+			delete(s.deliveries, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) EnqueueDeliveries(_ context.Context, deliveries []Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range deliveries {
+		duplicate := false
+		for _, existing := range s.deliveries {
+			if existing.SubscriptionID == d.SubscriptionID && existing.MessageID == d.MessageID {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			s.deliveries[d.ID] = d
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) DueDeliveries(_ context.Context, now time.Time, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Delivery
+	for _, d := range s.deliveries {
+		if d.Status == StatusPending && !d.NextAttemptAt.After(now) {
+			out = append(out, d)
+		}
+	}
+	sortDeliveries(out, false)
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) ClaimDelivery(_ context.Context, d Delivery, lease time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.deliveries[d.ID]
+	if !ok || stored.Status != StatusPending || stored.Attempts != d.Attempts {
+		return false, nil
+	}
+	stored.Attempts++
+	stored.NextAttemptAt = lease
+	s.deliveries[d.ID] = stored
+	return true, nil
+}
+
+func (s *MemoryStore) RecordAttempt(_ context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.deliveries[d.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Status, stored.LastStatus, stored.LastError = d.Status, d.LastStatus, d.LastError
+	stored.NextAttemptAt, stored.DeliveredAt = d.NextAttemptAt, d.DeliveredAt
+	s.deliveries[d.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetDelivery(_ context.Context, subscriptionID, id string) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok || d.SubscriptionID != subscriptionID {
+		return Delivery{}, ErrNotFound
+	}
+	return d, nil
+}
+
+// ListDeliveries returns the newest deliveries first.
+func (s *MemoryStore) ListDeliveries(_ context.Context, subscriptionID, status string, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Delivery
+	for _, d := range s.deliveries {
+		if d.SubscriptionID == subscriptionID && (status == "" || d.Status == status) {
+			out = append(out, d)
+		}
+	}
+	sortDeliveries(out, true)
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) ReplayDelivery(_ context.Context, subscriptionID, id string, now time.Time) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok || d.SubscriptionID != subscriptionID {
+		return Delivery{}, ErrNotFound
+	}
+	d.Status, d.Attempts, d.NextAttemptAt, d.DeliveredAt = StatusPending, 0, now, time.Time{}
+	s.deliveries[id] = d
+	return d, nil
+}
+
+func cloneSubscription(sub Subscription) Subscription {
+	sub.Events = append([]string(nil), sub.Events...)
+	sub.DriverIDs = append([]string(nil), sub.DriverIDs...)
+	return sub
+}
+
+func sortDeliveries(out []Delivery, newestFirst bool) {
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt) != newestFirst
+		}
+		return out[i].ID < out[j].ID
+	})
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderID        = "Kage-Webhook-Id"
+	HeaderEvent     = "Kage-Webhook-Event"
+	HeaderTimestamp = "Kage-Webhook-Timestamp"
+	HeaderSignature = "Kage-Webhook-Signature"
+)
+
+// ErrBadSignature occurs when a signature does not match the body or is too old.
+var ErrBadSignature = errors.New("webhook signature mismatch")
+
+// Sign returns "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" under secret.
+// Binding the timestamp into the MAC lets receivers reject replays of old requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the timestamp and signature headers of a received delivery; tolerance bounds clock skew and replay age.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrBadSignature
+	}
+	if !strings.HasPrefix(signature, "sha256=") || !hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature)) {
+		return ErrBadSignature
+	}
+	return nil
+}
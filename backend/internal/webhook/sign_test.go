@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	body := []byte(`{"id":"m1"}`)
+	signature := Sign("0123456789abcdef", now.Unix(), body)
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	// 1.- A fresh, untouched request verifies.
+	if err := Verify("0123456789abcdef", ts, signature, body, time.Minute, now.Add(30*time.Second)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// 2.- Tampered bodies, wrong secrets, and stale timestamps are rejected.
+	cases := map[string]error{
+		"body":   Verify("0123456789abcdef", ts, signature, []byte(`{"id":"m2"}`), time.Minute, now),
+		"secret": Verify("fedcba9876543210", ts, signature, body, time.Minute, now),
+		"stale":  Verify("0123456789abcdef", ts, signature, body, time.Minute, now.Add(2*time.Minute)),
+		"ts":     Verify("0123456789abcdef", strconv.FormatInt(now.Unix()+1, 10), signature, body, time.Minute, now),
+		"format": Verify("0123456789abcdef", "yesterday", signature, body, time.Minute, now),
+	}
+	for name, err := range cases {
+		if !errors.Is(err, ErrBadSignature) {
+			t.Fatalf("%s: expected ErrBadSignature got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"kage/backend/internal/dialect"
+	"kage/backend/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const deliveryColumns = `id, subscription_id, message_id, event, payload, status, attempts, next_attempt_at, last_status, last_error, created_at, delivered_at`
+
+// SQLStore persists subscriptions and deliveries using database/sql.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+	enqueue string
+}
+
+// NewSQLStore builds a store for the given database handle and its dialect.
+func NewSQLStore(db *sql.DB, d dialect.Dialect) *SQLStore {
+	return &SQLStore{
+		db:      db,
+		dialect: d,
+		enqueue: d.Upsert("webhook_deliveries",
+			[]string{"id", "subscription_id", "message_id", "event", "payload", "status", "attempts", "next_attempt_at", "created_at"},
+			[]string{"subscription_id", "message_id"},
+			nil),
+	}
+}
+
+func (s *SQLStore) CreateSubscription(ctx context.Context, sub Subscription) error {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "INSERT", "webhook_subscriptions")
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`INSERT INTO webhook_subscriptions (id, url, secret, events, driver_ids, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		sub.ID, sub.URL, sub.Secret, strings.Join(sub.Events, ","), strings.Join(sub.DriverIDs, ","), sub.Active, sub.CreatedAt, sub.UpdatedAt,
+	)
+	return tracing.End(span, err)
+}
+
+func (s *SQLStore) GetSubscription(ctx context.Context, id string) (sub Subscription, err error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "SELECT", "webhook_subscriptions")
+	defer func() { endIgnoringNotFound(span, err) }()
+	sub, err = scanSubscription(s.db.QueryRowContext(ctx, s.dialect.Rebind(
+		`SELECT id, url, secret, events, driver_ids, active, created_at, updated_at FROM webhook_subscriptions WHERE id = ?`), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Subscription{}, ErrNotFound
+	}
+	return sub, err
+}
+
+func (s *SQLStore) ListSubscriptions(ctx context.Context) (out []Subscription, err error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "SELECT", "webhook_subscriptions")
+	defer func() { tracing.End(span, err) }()
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, url, secret, events, driver_ids, active, created_at, updated_at FROM webhook_subscriptions ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sub)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) UpdateSubscription(ctx context.Context, sub Subscription) (err error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "UPDATE", "webhook_subscriptions")
+	defer func() { endIgnoringNotFound(span, err) }()
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE webhook_subscriptions SET url = ?, secret = ?, events = ?, driver_ids = ?, active = ?, updated_at = ? WHERE id = ?`),
+		sub.URL, sub.Secret, strings.Join(sub.Events, ","), strings.Join(sub.DriverIDs, ","), sub.Active, sub.UpdatedAt, sub.ID,
+	)
+	return affected(res, err)
+}
+
+// DeleteSubscription removes the subscription and its delivery log in one transaction.
+func (s *SQLStore) DeleteSubscription(ctx context.Context, id string) (err error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "DELETE", "webhook_subscriptions")
+	defer func() { endIgnoringNotFound(span, err) }()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, s.dialect.Rebind(`DELETE FROM webhook_deliveries WHERE subscription_id = ?`), id); err != nil {
+		return err
+	}
+	if err := affected(tx.ExecContext(ctx, s.dialect.Rebind(`DELETE FROM webhook_subscriptions WHERE id = ?`), id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// EnqueueDeliveries inserts every delivery in one transaction; the unique key drops repeats of an outbox message.
+func (s *SQLStore) EnqueueDeliveries(ctx context.Context, deliveries []Delivery) (err error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "INSERT", "webhook_deliveries")
+	defer func() { tracing.End(span, err) }()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, d := range deliveries {
+		if _, err := tx.ExecContext(ctx, s.enqueue,
+			d.ID, d.SubscriptionID, d.MessageID, d.Event, string(d.Payload), d.Status, d.Attempts, d.NextAttemptAt, d.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	return s.queryDeliveries(ctx, s.dialect.Rebind(
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE status = ? AND next_attempt_at <= ? ORDER BY created_at, id LIMIT ?`),
+		StatusPending, now.UTC(), limit)
+}
+
+// ClaimDelivery uses the attempt counter as an optimistic version so concurrent workers never both send an attempt.
+func (s *SQLStore) ClaimDelivery(ctx context.Context, d Delivery, lease time.Time) (bool, error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "UPDATE", "webhook_deliveries")
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = ? WHERE id = ? AND status = ? AND attempts = ?`),
+		lease.UTC(), d.ID, StatusPending, d.Attempts,
+	)
+	if tracing.End(span, err) != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *SQLStore) RecordAttempt(ctx context.Context, d Delivery) error {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "UPDATE", "webhook_deliveries")
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE webhook_deliveries SET status = ?, next_attempt_at = ?, last_status = ?, last_error = ?, delivered_at = ? WHERE id = ?`),
+		d.Status, d.NextAttemptAt.UTC(), nullInt(d.LastStatus), nullString(d.LastError), nullTime(d.DeliveredAt), d.ID,
+	)
+	return tracing.End(span, err)
+}
+
+func (s *SQLStore) GetDelivery(ctx context.Context, subscriptionID, id string) (Delivery, error) {
+	out, err := s.queryDeliveries(ctx, s.dialect.Rebind(
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE subscription_id = ? AND id = ?`), subscriptionID, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if len(out) == 0 {
+		return Delivery{}, ErrNotFound
+	}
+	return out[0], nil
+}
+
+func (s *SQLStore) ListDeliveries(ctx context.Context, subscriptionID, status string, limit int) ([]Delivery, error) {
+	query, args := `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE subscription_id = ?`, []interface{}{subscriptionID}
+	if status != "" {
+		query, args = query+` AND status = ?`, append(args, status)
+	}
+	return s.queryDeliveries(ctx, s.dialect.Rebind(query+` ORDER BY created_at DESC, id LIMIT ?`), append(args, limit)...)
+}
+
+func (s *SQLStore) ReplayDelivery(ctx context.Context, subscriptionID, id string, now time.Time) (Delivery, error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "UPDATE", "webhook_deliveries")
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?, delivered_at = NULL WHERE subscription_id = ? AND id = ?`),
+		StatusPending, now.UTC(), subscriptionID, id,
+	)
+	err = affected(res, err)
+	endIgnoringNotFound(span, err)
+	if err != nil {
+		return Delivery{}, err
+	}
+	return s.GetDelivery(ctx, subscriptionID, id)
+}
+
+func (s *SQLStore) queryDeliveries(ctx context.Context, query string, args ...interface{}) (out []Delivery, err error) {
+	ctx, span := tracing.StartSQL(ctx, s.dialect.Name(), "SELECT", "webhook_deliveries")
+	defer func() { tracing.End(span, err) }()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			d           Delivery
+			payload     string
+			lastStatus  sql.NullInt64
+			lastError   sql.NullString
+			deliveredAt sql.NullTime
+		)
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.MessageID, &d.Event, &payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &lastStatus, &lastError, &d.CreatedAt, &deliveredAt); err != nil {
+			return nil, err
+		}
+		d.Payload = []byte(payload)
+		d.LastStatus, d.LastError, d.DeliveredAt = int(lastStatus.Int64), lastError.String, deliveredAt.Time
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubscription(row scanner) (Subscription, error) {
+	var (
+		sub               Subscription
+		events, driverIDs string
+	)
+	if err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &events, &driverIDs, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt); err != nil {
+		return Subscription{}, err
+	}
+	sub.Events, sub.DriverIDs = splitList(events), splitList(driverIDs)
+	return sub, nil
+}
+
+func splitList(joined string) []string {
+	if joined == "" {
+		return nil
+	}
+	return strings.Split(joined, ",")
+}
+
+// affected turns an update or delete that matched no row into ErrNotFound.
+func affected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// endIgnoringNotFound ends span, not flagging a missing row as a span error.
+func endIgnoringNotFound(span trace.Span, err error) {
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func nullInt(v int) sql.NullInt64 { return sql.NullInt64{Int64: int64(v), Valid: v != 0} }
+
+func nullString(v string) sql.NullString { return sql.NullString{String: v, Valid: v != ""} }
+
+func nullTime(v time.Time) sql.NullTime { return sql.NullTime{Time: v.UTC(), Valid: !v.IsZero()} }
@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"kage/backend/internal/dialect"
+)
+
+func TestSQLStoreSubscriptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+	store := NewSQLStore(db, dialect.MySQL)
+	ctx := context.Background()
+	now := time.Unix(1735689600, 0).UTC()
+	sub := Subscription{ID: "s1", URL: "https://partner.example", Secret: "0123456789abcdef", Events: []string{EventBidAccepted, EventTripComplete}, DriverIDs: []string{"d1"}, Active: true, CreatedAt: now, UpdatedAt: now}
+
+	// 1.- Filters are stored comma-joined and split again on read.
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO webhook_subscriptions (id, url, secret, events, driver_ids, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)).
+		WithArgs("s1", sub.URL, sub.Secret, "bid.accepted,trip.complete", "d1", true, now, now).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := store.CreateSubscription(ctx, sub); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	columns := []string{"id", "url", "secret", "events", "driver_ids", "active", "created_at", "updated_at"}
+	mock.ExpectQuery(`FROM webhook_subscriptions WHERE id = \?`).WithArgs("s1").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("s1", sub.URL, sub.Secret, "bid.accepted,trip.complete", "", true, now, now))
+	got, err := store.GetSubscription(ctx, "s1")
+	if err != nil || len(got.Events) != 2 || got.DriverIDs != nil {
+		t.Fatalf("unexpected subscription %+v: %v", got, err)
+	}
+
+	// 2.- Missing rows surface as ErrNotFound.
+	mock.ExpectQuery(`FROM webhook_subscriptions WHERE id = \?`).WithArgs("nope").WillReturnError(sql.ErrNoRows)
+	if _, err := store.GetSubscription(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+	mock.ExpectExec(`UPDATE webhook_subscriptions SET`).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := store.UpdateSubscription(ctx, Subscription{ID: "nope"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+
+	// 3.- Deleting removes the delivery log in the same transaction.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM webhook_deliveries WHERE subscription_id = ?`)).WithArgs("s1").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM webhook_subscriptions WHERE id = ?`)).WithArgs("s1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := store.DeleteSubscription(ctx, "s1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSQLStoreDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+	store := NewSQLStore(db, dialect.Postgres)
+	ctx := context.Background()
+	now := time.Unix(1735689600, 0).UTC()
+
+	// 1.- Enqueueing ignores a repeat of the same message for the same subscription.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO webhook_deliveries (id, subscription_id, message_id, event, payload, status, attempts, next_attempt_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (subscription_id, message_id) DO NOTHING`)).
+		WithArgs("d1", "s1", "m1", EventBidAccepted, `{}`, StatusPending, 0, now, now).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := store.EnqueueDeliveries(ctx, []Delivery{{ID: "d1", SubscriptionID: "s1", MessageID: "m1", Event: EventBidAccepted, Payload: []byte(`{}`), Status: StatusPending, NextAttemptAt: now, CreatedAt: now}}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	// 2.- The log filters by status and keeps nullable outcome columns.
+	columns := []string{"id", "subscription_id", "message_id", "event", "payload", "status", "attempts", "next_attempt_at", "last_status", "last_error", "created_at", "delivered_at"}
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM webhook_deliveries WHERE subscription_id = $1 AND status = $2 ORDER BY created_at DESC, id LIMIT $3`)).
+		WithArgs("s1", StatusFailed, 20).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("d1", "s1", "m1", EventBidAccepted, `{}`, StatusFailed, 8, now, 502, "bad gateway", now, nil))
+	log, err := store.ListDeliveries(ctx, "s1", StatusFailed, 20)
+	if err != nil || len(log) != 1 || log[0].LastStatus != 502 || !log[0].DeliveredAt.IsZero() {
+		t.Fatalf("unexpected log %+v: %v", log, err)
+	}
+
+	// 3.- Claims guard on the attempt counter; losing the race affects no row.
+	claim := regexp.QuoteMeta(`UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = $1 WHERE id = $2 AND status = $3 AND attempts = $4`)
+	mock.ExpectExec(claim).WithArgs(now.Add(time.Minute), "d1", StatusPending, 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	if ok, err := store.ClaimDelivery(ctx, Delivery{ID: "d1"}, now.Add(time.Minute)); ok || err != nil {
+		t.Fatalf("expected lost claim got %v %v", ok, err)
+	}
+
+	// 4.- Replaying an unknown delivery reports it missing.
+	mock.ExpectExec(`UPDATE webhook_deliveries SET status = \$1, attempts = 0`).WithArgs(StatusPending, now, "s1", "nope").WillReturnResult(sqlmock.NewResult(0, 0))
+	if _, err := store.ReplayDelivery(ctx, "s1", "nope", now); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Package webhook notifies partner endpoints about bid and trip events. Subscriptions
+// filter by event and driver; every matching outbox message becomes a delivery row
+// that is POSTed with an HMAC-SHA256 signature and retried with exponential backoff.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"kage/backend/internal/contracts"
+)
+
+// Events partners can subscribe to.
+const (
+	EventBidAccepted  = "bid.accepted"
+	EventTripActive   = "trip.active"
+	EventTripPaused   = "trip.paused"
+	EventTripCanceled = "trip.canceled"
+	EventTripComplete = "trip.complete"
+)
+
+// Events lists every subscribable event.
+var Events = []string{EventBidAccepted, EventTripActive, EventTripPaused, EventTripCanceled, EventTripComplete}
+
+// Delivery states.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrNotFound occurs when a subscription or delivery does not exist.
+var ErrNotFound = errors.New("webhook not found")
+
+// Subscription is one partner endpoint and the events it wants.
+type Subscription struct {
+	ID     string
+	URL    string
+	Secret string
+	Events []string
+	// DriverIDs limits notifications to these drivers; empty matches every driver.
+	DriverIDs []string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Validate reports every invalid field at once.
+func (s Subscription) Validate() error {
+	invalid := &contracts.ValidationError{}
+	if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid.Fields = append(invalid.Fields, contracts.FieldError{Field: "url", Reason: "must be an absolute http or https URL"})
+	}
+	if len(s.Events) == 0 {
+		invalid.Fields = append(invalid.Fields, contracts.FieldError{Field: "events", Reason: "must list at least one event"})
+	}
+	for _, event := range s.Events {
+		if !slices.Contains(Events, event) {
+			invalid.Fields = append(invalid.Fields, contracts.FieldError{Field: "events", Reason: "unknown event " + event})
+		}
+	}
+	if len(s.Secret) < 16 {
+		invalid.Fields = append(invalid.Fields, contracts.FieldError{Field: "secret", Reason: "must be at least 16 characters"})
+	}
+	if len(invalid.Fields) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// Matches reports whether the subscription wants event about driverID.
+func (s Subscription) Matches(event, driverID string) bool {
+	if !s.Active || !slices.Contains(s.Events, event) {
+		return false
+	}
+	return len(s.DriverIDs) == 0 || slices.Contains(s.DriverIDs, driverID)
+}
+
+// Delivery is one notification to one subscription and the log of its attempts.
+type Delivery struct {
+	ID             string
+	SubscriptionID string
+	// MessageID is the outbox message id; partners de-duplicate on it.
+	MessageID     string
+	Event         string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	// LastStatus is the HTTP status of the latest attempt, zero when the request failed outright.
+	LastStatus  int
+	LastError   string
+	CreatedAt   time.Time
+	DeliveredAt time.Time
+}
+
+// Store persists subscriptions and deliveries.
+type Store interface {
+	CreateSubscription(ctx context.Context, sub Subscription) error
+	GetSubscription(ctx context.Context, id string) (Subscription, error)
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	UpdateSubscription(ctx context.Context, sub Subscription) error
+	// DeleteSubscription removes the subscription together with its delivery log.
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// EnqueueDeliveries stores pending deliveries, skipping ones already stored for the same subscription and message.
+	EnqueueDeliveries(ctx context.Context, deliveries []Delivery) error
+	// DueDeliveries lists pending deliveries whose next attempt is at or before now, oldest first.
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	// ClaimDelivery bumps the attempt counter and hides d until lease; ok is false when another worker won.
+	ClaimDelivery(ctx context.Context, d Delivery, lease time.Time) (ok bool, err error)
+	// RecordAttempt stores the outcome of the latest attempt; status is pending (with next), succeeded or failed.
+	RecordAttempt(ctx context.Context, d Delivery) error
+	GetDelivery(ctx context.Context, subscriptionID, id string) (Delivery, error)
+	// ListDeliveries returns the newest deliveries of a subscription, optionally filtered by status.
+	ListDeliveries(ctx context.Context, subscriptionID, status string, limit int) ([]Delivery, error)
+	// ReplayDelivery resets a delivery to pending with a fresh attempt budget, due at now.
+	ReplayDelivery(ctx context.Context, subscriptionID, id string, now time.Time) (Delivery, error)
+}
+
+// NewID returns a random identifier for subscriptions and deliveries.
+func NewID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// NewSecret returns a random signing secret.
+func NewSecret() string {
+	var b [24]byte
+	_, _ = rand.Read(b[:])
+	return "whsec_" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,344 @@
+package ws
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what happens when a client's outbound buffer is full.
+type OverflowPolicy string
+
+const (
+	// PolicyDisconnect drops the slow client entirely.
+	PolicyDisconnect OverflowPolicy = "disconnect"
+	// PolicyDropOldest evicts the oldest queued message of the same type.
+	PolicyDropOldest OverflowPolicy = "drop_oldest"
+	// PolicyDropNewest discards the incoming message.
+	PolicyDropNewest OverflowPolicy = "drop_newest"
+	// PolicyCoalesce replaces a queued message sharing the same key with the latest one.
+	PolicyCoalesce OverflowPolicy = "coalesce_latest"
+)
+
+// Backpressure configures buffering for a single message type.
+type Backpressure struct {
+	BufferSize int
+	Policy     OverflowPolicy
+}
+
+// DefaultBackpressure mirrors the historical 8-slot disconnect behaviour.
+var DefaultBackpressure = Backpressure{BufferSize: 8, Policy: PolicyDisconnect}
+
+// String renders the policy in the "size:policy" form ParseBackpressure accepts for one entry.
+func (b Backpressure) String() string {
+	return strconv.Itoa(b.BufferSize) + ":" + string(b.Policy)
+}
+
+// Valid reports whether the policy is one of the supported values.
+func (p OverflowPolicy) Valid() bool {
+	switch p {
+	case PolicyDisconnect, PolicyDropOldest, PolicyDropNewest, PolicyCoalesce:
+		return true
+	default:
+		return false
+	}
+}
+
+// BackpressureStats exposes counters for messages affected by overflow policies.
+type BackpressureStats struct {
+	Dropped      uint64            `json:"dropped"`
+	Coalesced    uint64            `json:"coalesced"`
+	Disconnected uint64            `json:"disconnected"`
+	DroppedBy    map[string]uint64 `json:"dropped_by_type"`
+	CoalescedBy  map[string]uint64 `json:"coalesced_by_type"`
+}
+
+type backpressureCounters struct {
+	dropped      atomic.Uint64
+	coalesced    atomic.Uint64
+	disconnected atomic.Uint64
+	mu           sync.Mutex
+	droppedBy    map[string]uint64
+	coalescedBy  map[string]uint64
+	recorder     Recorder
+}
+
+func newBackpressureCounters() *backpressureCounters {
+	return &backpressureCounters{droppedBy: make(map[string]uint64), coalescedBy: make(map[string]uint64), recorder: nopRecorder{}}
+}
+
+func (c *backpressureCounters) record(msgType string, outcome enqueueOutcome) {
+	switch outcome {
+	case outcomeDropped:
+		c.dropped.Add(1)
+		c.mu.Lock()
+		c.droppedBy[msgType]++
+		c.mu.Unlock()
+		c.recorder.MessageDropped(msgType)
+	case outcomeCoalesced:
+		c.coalesced.Add(1)
+		c.mu.Lock()
+		c.coalescedBy[msgType]++
+		c.mu.Unlock()
+	case outcomeOverflow:
+		c.disconnected.Add(1)
+	}
+}
+
+func (c *backpressureCounters) snapshot() BackpressureStats {
+	stats := BackpressureStats{
+		Dropped:      c.dropped.Load(),
+		Coalesced:    c.coalesced.Load(),
+		Disconnected: c.disconnected.Load(),
+		DroppedBy:    make(map[string]uint64),
+		CoalescedBy:  make(map[string]uint64),
+	}
+	c.mu.Lock()
+	for k, v := range c.droppedBy {
+		stats.DroppedBy[k] = v
+	}
+	for k, v := range c.coalescedBy {
+		stats.CoalescedBy[k] = v
+	}
+	c.mu.Unlock()
+	return stats
+}
+
+type enqueueOutcome int
+
+const (
+	outcomeQueued enqueueOutcome = iota
+	outcomeDropped
+	outcomeCoalesced
+	outcomeOverflow
+)
+
+type queuedMessage struct {
+	key string
+	msg Message
+}
+
+// sendQueue buffers outbound payloads per message type while preserving arrival order.
+type sendQueue struct {
+	mu      sync.Mutex
+	items   *list.List
+	perType map[string]int
+	keyed   map[string]*list.Element
+	notify  chan struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+func newSendQueue() *sendQueue {
+	return &sendQueue{
+		items:   list.New(),
+		perType: make(map[string]int),
+		keyed:   make(map[string]*list.Element),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+func (q *sendQueue) enqueue(msg Message, bp Backpressure) enqueueOutcome {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return outcomeDropped
+	}
+	size := bp.BufferSize
+	if size <= 0 {
+		size = DefaultBackpressure.BufferSize
+	}
+
+	// 1.- Coalesce when a message with the same key is still pending, moving it to the back so
+	// it is written after everything queued before it and ids keep increasing.
+	coalesceKey := msg.Type + "\x00" + msg.Key
+	if bp.Policy == PolicyCoalesce {
+		if el, ok := q.keyed[coalesceKey]; ok {
+			item := el.Value.(*queuedMessage)
+			item.msg.ID = msg.ID
+			item.msg.Payload = msg.Payload
+			q.items.MoveToBack(el)
+			return outcomeCoalesced
+		}
+	}
+
+	// 2.- Apply the overflow policy once the per-type buffer is full.
+	outcome := outcomeQueued
+	if q.perType[msg.Type] >= size {
+		switch bp.Policy {
+		case PolicyDropNewest:
+			return outcomeDropped
+		case PolicyDropOldest, PolicyCoalesce:
+			q.evictOldest(msg.Type)
+			outcome = outcomeDropped
+		default:
+			return outcomeOverflow
+		}
+	}
+
+	// 3.- Append the message and wake the writer.
+	item := &queuedMessage{key: coalesceKey, msg: msg}
+	el := q.items.PushBack(item)
+	q.perType[msg.Type]++
+	if bp.Policy == PolicyCoalesce {
+		q.keyed[coalesceKey] = el
+	}
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return outcome
+}
+
+func (q *sendQueue) evictOldest(msgType string) {
+	for el := q.items.Front(); el != nil; el = el.Next() {
+		if el.Value.(*queuedMessage).msg.Type == msgType {
+			q.remove(el)
+			return
+		}
+	}
+}
+
+func (q *sendQueue) remove(el *list.Element) {
+	item := q.items.Remove(el).(*queuedMessage)
+	q.perType[item.msg.Type]--
+	if q.keyed[item.key] == el {
+		delete(q.keyed, item.key)
+	}
+}
+
+// drainMessages pops every pending message in arrival order.
+func (q *sendQueue) drainMessages() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	messages := make([]Message, 0, q.items.Len())
+	for el := q.items.Front(); el != nil; el = q.items.Front() {
+		messages = append(messages, el.Value.(*queuedMessage).msg)
+		q.remove(el)
+	}
+	return messages
+}
+
+// drain pops every pending payload in arrival order.
+func (q *sendQueue) drain() []interface{} {
+	messages := q.drainMessages()
+	payloads := make([]interface{}, len(messages))
+	for i, msg := range messages {
+		payloads[i] = msg.Payload
+	}
+	return payloads
+}
+
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.done)
+}
+
+// ParseBackpressure decodes specs such as "location=32:coalesce_latest,update=8:disconnect".
+// The special type "*" sets the default policy applied to unlisted message types.
+func ParseBackpressure(spec string) (map[string]Backpressure, error) {
+	out := make(map[string]Backpressure)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		msgType, rest, ok := strings.Cut(entry, "=")
+		if !ok || msgType == "" {
+			return nil, fmt.Errorf("backpressure entry %q: expected type=size:policy", entry)
+		}
+		sizeText, policyText, ok := strings.Cut(rest, ":")
+		if !ok {
+			return nil, fmt.Errorf("backpressure entry %q: expected type=size:policy", entry)
+		}
+		size, err := strconv.Atoi(sizeText)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("backpressure entry %q: invalid buffer size", entry)
+		}
+		policy := OverflowPolicy(policyText)
+		if !policy.Valid() {
+			return nil, fmt.Errorf("backpressure entry %q: unknown policy %q", entry, policyText)
+		}
+		out[msgType] = Backpressure{BufferSize: size, Policy: policy}
+	}
+	return out, nil
+}
+
+// backpressureTable maps message types to policies; it is replaced, never mutated, once the hub runs.
+type backpressureTable struct {
+	byType   map[string]Backpressure
+	fallback Backpressure
+}
+
+// defaultBackpressureTable coalesces high-frequency updates and disconnects on everything else.
+func defaultBackpressureTable() *backpressureTable {
+	return &backpressureTable{
+		byType: map[string]Backpressure{
+			MessageTypeLocation:   {BufferSize: 32, Policy: PolicyCoalesce},
+			MessageTypeChatTyping: {BufferSize: 4, Policy: PolicyCoalesce},
+			MessageTypeFirehose:   {BufferSize: 256, Policy: PolicyDropOldest},
+			MessageTypeHeatmap:    {BufferSize: 1, Policy: PolicyCoalesce},
+		},
+		fallback: DefaultBackpressure,
+	}
+}
+
+// with returns a copy of t with policies applied, honouring the "*" default entry.
+func (t *backpressureTable) with(policies map[string]Backpressure) *backpressureTable {
+	next := &backpressureTable{byType: make(map[string]Backpressure, len(t.byType)+len(policies)), fallback: t.fallback}
+	for msgType, bp := range t.byType {
+		next.byType[msgType] = bp
+	}
+	for msgType, bp := range policies {
+		if msgType == "*" {
+			next.fallback = bp
+			continue
+		}
+		next.byType[msgType] = bp
+	}
+	return next
+}
+
+// WithBackpressurePolicies applies a parsed policy table, honouring the "*" default entry.
+func WithBackpressurePolicies(policies map[string]Backpressure) Option {
+	return func(h *Hub) { h.backpressure.Store(h.backpressure.Load().with(policies)) }
+}
+
+// WithBackpressure configures buffering and overflow handling for a message type.
+func WithBackpressure(msgType string, bp Backpressure) Option {
+	return WithBackpressurePolicies(map[string]Backpressure{msgType: bp})
+}
+
+// WithDefaultBackpressure configures buffering for message types without an explicit policy.
+func WithDefaultBackpressure(bp Backpressure) Option {
+	return WithBackpressurePolicies(map[string]Backpressure{"*": bp})
+}
+
+// SetBackpressurePolicies replaces the policies given at construction with the built-in
+// defaults overlaid by policies. Connected clients keep their queues; the next enqueue
+// of each message type uses the new buffer size and overflow policy.
+func (h *Hub) SetBackpressurePolicies(policies map[string]Backpressure) {
+	h.backpressure.Store(defaultBackpressureTable().with(policies))
+}
+
+// Stats returns the dropped, coalesced, and disconnect counters accumulated by overflow policies.
+func (h *Hub) Stats() BackpressureStats {
+	return h.counters.snapshot()
+}
+
+func (h *Hub) backpressureFor(msgType string) Backpressure {
+	table := h.backpressure.Load()
+	if bp, ok := table.byType[msgType]; ok {
+		return bp
+	}
+	return table.fallback
+}
@@ -0,0 +1,210 @@
+package ws
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSendQueuePolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   OverflowPolicy
+		messages []Message
+		outcomes []enqueueOutcome
+		expected []interface{}
+	}{
+		{
+			name:     "disconnect",
+			policy:   PolicyDisconnect,
+			messages: []Message{{Type: "update", Payload: 1}, {Type: "update", Payload: 2}, {Type: "update", Payload: 3}},
+			outcomes: []enqueueOutcome{outcomeQueued, outcomeQueued, outcomeOverflow},
+			expected: []interface{}{1, 2},
+		},
+		{
+			name:     "drop oldest",
+			policy:   PolicyDropOldest,
+			messages: []Message{{Type: "update", Payload: 1}, {Type: "update", Payload: 2}, {Type: "update", Payload: 3}},
+			outcomes: []enqueueOutcome{outcomeQueued, outcomeQueued, outcomeDropped},
+			expected: []interface{}{2, 3},
+		},
+		{
+			name:     "drop newest",
+			policy:   PolicyDropNewest,
+			messages: []Message{{Type: "update", Payload: 1}, {Type: "update", Payload: 2}, {Type: "update", Payload: 3}},
+			outcomes: []enqueueOutcome{outcomeQueued, outcomeQueued, outcomeDropped},
+			expected: []interface{}{1, 2},
+		},
+		{
+			name:   "coalesce per key",
+			policy: PolicyCoalesce,
+			messages: []Message{
+				{Type: "location", Key: "d1", Payload: "d1-a"},
+				{Type: "location", Key: "d2", Payload: "d2-a"},
+				{Type: "location", Key: "d1", Payload: "d1-b"},
+			},
+			outcomes: []enqueueOutcome{outcomeQueued, outcomeQueued, outcomeCoalesced},
+			expected: []interface{}{"d2-a", "d1-b"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// 1.- Enqueue messages into a two-slot buffer and compare each outcome.
+			q := newSendQueue()
+			bp := Backpressure{BufferSize: 2, Policy: tc.policy}
+			for i, msg := range tc.messages {
+				if got := q.enqueue(msg, bp); got != tc.outcomes[i] {
+					t.Fatalf("message %d: expected outcome %d got %d", i, tc.outcomes[i], got)
+				}
+			}
+
+			// 2.- Drain the queue and verify the surviving payload order.
+			got := q.drain()
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %v got %v", tc.expected, got)
+			}
+			for i := range got {
+				if got[i] != tc.expected[i] {
+					t.Fatalf("expected %v got %v", tc.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSendQueueCoalescingKeepsIDsIncreasing(t *testing.T) {
+	// 1.- A coalesced location update lands after a chat message queued behind the original.
+	q := newSendQueue()
+	coalesce := Backpressure{BufferSize: 4, Policy: PolicyCoalesce}
+	q.enqueue(Message{ID: 1, Type: "location", Key: "d1", Payload: "a"}, coalesce)
+	q.enqueue(Message{ID: 2, Type: "chat", Payload: "hi"}, DefaultBackpressure)
+	q.enqueue(Message{ID: 3, Type: "location", Key: "d1", Payload: "b"}, coalesce)
+
+	// 2.- The writer sees ids in order, so a client resuming from 3 has already seen 2.
+	got := q.drainMessages()
+	if len(got) != 2 || got[0].ID != 2 || got[1].ID != 3 || got[1].Payload != "b" {
+		t.Fatalf("expected chat 2 then location 3 got %+v", got)
+	}
+}
+
+func TestSendQueueIsolatesTypes(t *testing.T) {
+	q := newSendQueue()
+	strict := Backpressure{BufferSize: 1, Policy: PolicyDropNewest}
+	if got := q.enqueue(Message{Type: "chat", Payload: "a"}, strict); got != outcomeQueued {
+		t.Fatalf("expected chat queued got %d", got)
+	}
+	if got := q.enqueue(Message{Type: "update", Payload: "b"}, strict); got != outcomeQueued {
+		t.Fatalf("expected update queued despite full chat buffer got %d", got)
+	}
+}
+
+func TestParseBackpressure(t *testing.T) {
+	policies, err := ParseBackpressure("location=32:coalesce_latest, *=16:drop_oldest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if policies["location"] != (Backpressure{BufferSize: 32, Policy: PolicyCoalesce}) {
+		t.Fatalf("unexpected location policy: %+v", policies["location"])
+	}
+	if got := policies["location"].String(); got != "32:coalesce_latest" {
+		t.Fatalf("expected 32:coalesce_latest got %q", got)
+	}
+	if policies["*"] != (Backpressure{BufferSize: 16, Policy: PolicyDropOldest}) {
+		t.Fatalf("unexpected default policy: %+v", policies["*"])
+	}
+	if _, err := ParseBackpressure("location=0:disconnect"); err == nil {
+		t.Fatalf("expected error for zero buffer size")
+	}
+	if _, err := ParseBackpressure("location=4:explode"); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+}
+
+func TestHubStatsCountOverflow(t *testing.T) {
+	h := NewHub(nil, WithBackpressure("location", Backpressure{BufferSize: 1, Policy: PolicyCoalesce}))
+	defer close(h.shutdown)
+	client := &Client{hub: h, send: newSendQueue(), room: "trip-1", role: RoleRider}
+	h.addClient(client)
+
+	h.push(Message{RoomID: "trip-1", Role: RoleRider, Type: "location", Key: "d1", Payload: 1})
+	h.push(Message{RoomID: "trip-1", Role: RoleRider, Type: "location", Key: "d1", Payload: 2})
+	h.push(Message{RoomID: "trip-1", Role: RoleRider, Type: "location", Key: "d2", Payload: 3})
+
+	stats := h.Stats()
+	if stats.Coalesced != 1 || stats.CoalescedBy["location"] != 1 {
+		t.Fatalf("expected one coalesced message got %+v", stats)
+	}
+	if stats.Dropped != 1 || stats.DroppedBy["location"] != 1 {
+		t.Fatalf("expected one dropped message got %+v", stats)
+	}
+}
+
+func TestHubSetBackpressurePolicies(t *testing.T) {
+	h := NewHub(nil, WithBackpressure("location", Backpressure{BufferSize: 1, Policy: PolicyDropNewest}))
+	defer close(h.shutdown)
+	client := &Client{hub: h, send: newSendQueue(), room: "trip-1", role: RoleRider}
+	h.addClient(client)
+
+	// 1.- Swapping the table drops construction overrides in favour of the built-in location policy.
+	h.SetBackpressurePolicies(map[string]Backpressure{"*": {BufferSize: 2, Policy: PolicyDropNewest}})
+	h.push(Message{RoomID: "trip-1", Role: RoleRider, Type: "location", Key: "d1", Payload: 1})
+	h.push(Message{RoomID: "trip-1", Role: RoleRider, Type: "location", Key: "d2", Payload: 2})
+
+	// 2.- The connected client picks up the new default for unlisted types.
+	for i := 0; i < 3; i++ {
+		h.push(Message{RoomID: "trip-1", Role: RoleRider, Type: "custom", Payload: i})
+	}
+	stats := h.Stats()
+	if stats.DroppedBy["location"] != 0 || stats.DroppedBy["custom"] != 1 {
+		t.Fatalf("expected one custom drop got %+v", stats)
+	}
+}
+
+type countingRecorder struct {
+	mu      sync.Mutex
+	open    map[Role]int
+	dropped map[string]int
+}
+
+func (r *countingRecorder) ConnectionOpened(role Role) {
+	r.mu.Lock()
+	r.open[role]++
+	r.mu.Unlock()
+}
+
+func (r *countingRecorder) ConnectionClosed(role Role) {
+	r.mu.Lock()
+	r.open[role]--
+	r.mu.Unlock()
+}
+
+func (r *countingRecorder) MessageDropped(msgType string) {
+	r.mu.Lock()
+	r.dropped[msgType]++
+	r.mu.Unlock()
+}
+
+func TestHubReportsConnectionsAndDrops(t *testing.T) {
+	// 1.- Register a rider and a driver and overflow the rider's location queue.
+	rec := &countingRecorder{open: make(map[Role]int), dropped: make(map[string]int)}
+	h := NewHub(nil, WithRecorder(rec), WithBackpressure("location", Backpressure{BufferSize: 1, Policy: PolicyDropNewest}))
+	defer close(h.shutdown)
+	rider := &Client{hub: h, send: newSendQueue(), room: "trip-1", role: RoleRider}
+	driver := &Client{hub: h, send: newSendQueue(), room: "trip-1", role: RoleDriver}
+	h.addClient(rider)
+	h.addClient(driver)
+	h.push(Message{RoomID: "trip-1", Role: RoleRider, Type: "location", Payload: 1})
+	h.push(Message{RoomID: "trip-1", Role: RoleRider, Type: "location", Payload: 2})
+
+	// 2.- Closing a connection twice only decrements once.
+	h.removeClient(rider)
+	h.removeClient(rider)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.open[RoleRider] != 0 || rec.open[RoleDriver] != 1 {
+		t.Fatalf("unexpected connections %v", rec.open)
+	}
+	if rec.dropped["location"] != 1 {
+		t.Fatalf("unexpected drops %v", rec.dropped)
+	}
+}
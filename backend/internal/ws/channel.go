@@ -0,0 +1,27 @@
+package ws
+
+// MessageTypeHeatmap carries periodic demand snapshots on the driver-wide channel.
+const MessageTypeHeatmap = "heatmap"
+
+// BroadcastRole delivers a message to every connection of the role, including those outside any trip room.
+// Channel messages are neither replayed nor mirrored to the ops firehose.
+func (h *Hub) BroadcastRole(role Role, msgType string, payload interface{}) {
+	h.broadcast <- Message{Role: role, Type: msgType, Key: msgType, Payload: payload, channel: true}
+}
+
+// pushChannel enqueues a role-wide message on every client subscribed to the role's channel.
+func (h *Hub) pushChannel(msg Message) {
+	bp := h.backpressureFor(msg.Type)
+	msg = h.stamp(msg)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.channels[msg.Role] {
+		outcome := h.enqueue(client, msg, bp)
+		h.counters.record(msg.Type, outcome)
+		if outcome == outcomeOverflow {
+			go func(c *Client) {
+				c.hub.unregister <- c
+			}(client)
+		}
+	}
+}
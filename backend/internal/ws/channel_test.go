@@ -0,0 +1,81 @@
+package ws
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestBroadcastRoleReachesEveryDriverRoom(t *testing.T) {
+	h := NewHub(nil, WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	d1 := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleDriver}
+	d2 := &Client{hub: h, send: newSendQueue(), room: "t2", role: RoleDriver}
+	rider := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleRider}
+	h.addClient(d1)
+	h.addClient(d2)
+	h.addClient(rider)
+
+	// Successive snapshots coalesce so slow drivers only see the newest one.
+	h.BroadcastRole(RoleDriver, MessageTypeHeatmap, map[string]interface{}{"seq": 1})
+	h.BroadcastRole(RoleDriver, MessageTypeHeatmap, map[string]interface{}{"seq": 2})
+	h.Broadcast(Message{RoomID: "t1", Role: RoleRider, Type: "noop"})
+
+	for _, d := range []*Client{d1, d2} {
+		frames := waitForFrames(t, d, 1)
+		if len(frames) != 1 || frames[0].(map[string]interface{})["seq"] != 2 {
+			t.Fatalf("expected only the latest snapshot, got %v", frames)
+		}
+	}
+	if frames := waitForFrames(t, rider, 1); len(frames) != 1 {
+		t.Fatalf("expected rider to see only its room traffic, got %v", frames)
+	}
+}
+
+func TestIdleDriverReceivesRoleChannel(t *testing.T) {
+	// 1.- A driver connects without joining any trip room.
+	gin.SetMode(gin.TestMode)
+	h := NewHub(nil, WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	router := gin.New()
+	h.RegisterRoutes(router)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/driver", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for h.subscribers(RoleDriver) != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if h.count("") != 0 {
+		t.Fatalf("idle driver must not occupy a room")
+	}
+
+	// 2.- A heatmap snapshot reaches it, while room-scoped frames are refused.
+	h.BroadcastRole(RoleDriver, MessageTypeHeatmap, map[string]interface{}{"type": MessageTypeHeatmap})
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	var frame map[string]interface{}
+	if err := conn.ReadJSON(&frame); err != nil || frame["type"] != MessageTypeHeatmap {
+		t.Fatalf("expected heatmap snapshot got %v %v", frame, err)
+	}
+	if err := conn.WriteJSON(map[string]string{"type": MessageTypeChat, "body": "hi"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := conn.ReadJSON(&frame); err != nil || frame["error"] != errNoRoom.Error() {
+		t.Fatalf("expected chat to be refused got %v %v", frame, err)
+	}
+}
+
+// subscribers returns how many clients listen on the role's channel.
+func (h *Hub) subscribers(role Role) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.channels[role])
+}
@@ -0,0 +1,115 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"kage/backend/internal/chat"
+)
+
+// Chat frame types exchanged between riders and drivers.
+const (
+	MessageTypeChat       = "chat"
+	MessageTypeChatAck    = "chat.ack"
+	MessageTypeChatRead   = "chat.read"
+	MessageTypeChatTyping = "chat.typing"
+)
+
+const chatStoreTimeout = 5 * time.Second
+
+// WithChat configures chat persistence and an optional moderation hook.
+func WithChat(store chat.Store, moderator chat.Moderator) Option {
+	return func(h *Hub) {
+		if store != nil {
+			h.chatStore = store
+		}
+		h.moderator = moderator
+	}
+}
+
+type chatFrame struct {
+	Type            string    `json:"type"`
+	ClientMessageID string    `json:"client_message_id"`
+	MessageID       string    `json:"message_id"`
+	Body            string    `json:"body"`
+	SentAt          time.Time `json:"sent_at"`
+	Typing          bool      `json:"typing"`
+}
+
+func (h *Hub) handleChat(ctx context.Context, c *Client, data []byte) error {
+	var frame chatFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, chatStoreTimeout)
+	defer cancel()
+	now := time.Now().UTC()
+
+	switch frame.Type {
+	case MessageTypeChatTyping:
+		// 1.- Typing indicators are ephemeral and only reach the counterpart.
+		h.BroadcastContext(ctx, Message{RoomID: c.room, Role: counterpart(c.role), Type: MessageTypeChatTyping, Key: c.principal.ID, Payload: map[string]interface{}{
+			"type": MessageTypeChatTyping, "trip_id": c.room, "sender_id": c.principal.ID, "typing": frame.Typing,
+		}})
+		return nil
+
+	case MessageTypeChatRead:
+		// 2.- Read receipts are persisted and echoed to both participants.
+		if err := h.chatStore.MarkRead(ctx, c.room, frame.MessageID, c.principal.ID, now); err != nil {
+			return err
+		}
+		h.BroadcastTrip(ctx, c.room, MessageTypeChatRead, map[string]interface{}{
+			"type": MessageTypeChatRead, "trip_id": c.room, "message_id": frame.MessageID, "reader_id": c.principal.ID, "read_at": now,
+		})
+		return nil
+	}
+
+	// 3.- Build, validate, moderate, and persist the chat message before delivery.
+	msg := chat.Message{
+		ID:              chat.NewID(),
+		ClientMessageID: frame.ClientMessageID,
+		TripID:          c.room,
+		SenderID:        c.principal.ID,
+		SenderRole:      string(c.role),
+		Body:            frame.Body,
+		SentAt:          frame.SentAt,
+		ReceivedAt:      now,
+	}
+	if msg.SentAt.IsZero() {
+		msg.SentAt = now
+	}
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	msg, err := chat.Apply(ctx, h.moderator, msg)
+	if err != nil {
+		return err
+	}
+	stored, err := h.chatStore.Save(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	// 4.- Acknowledge the sender and fan out only when the message is new.
+	h.enqueue(c, Message{Type: MessageTypeChatAck, Payload: map[string]interface{}{
+		"type": MessageTypeChatAck, "client_message_id": stored.ClientMessageID, "message_id": stored.ID, "received_at": stored.ReceivedAt,
+	}}, h.backpressureFor(MessageTypeChatAck))
+	if stored.ID == msg.ID {
+		h.BroadcastTrip(ctx, c.room, MessageTypeChat, map[string]interface{}{"type": MessageTypeChat, "message": stored})
+	}
+	return nil
+}
+
+// BroadcastTrip delivers a payload to both the rider and driver rooms of a trip; the ops firehose sees it once.
+func (h *Hub) BroadcastTrip(ctx context.Context, tripID, msgType string, payload interface{}) {
+	h.BroadcastContext(ctx, Message{RoomID: tripID, Role: RoleRider, Type: msgType, Payload: payload})
+	h.BroadcastContext(ctx, Message{RoomID: tripID, Role: RoleDriver, Type: msgType, Payload: payload, mirror: true})
+}
+
+func counterpart(role Role) Role {
+	if role == RoleDriver {
+		return RoleRider
+	}
+	return RoleDriver
+}
@@ -0,0 +1,89 @@
+package ws
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/chat"
+)
+
+func waitForFrames(t *testing.T, c *Client, n int) []interface{} {
+	t.Helper()
+	var frames []interface{}
+	deadline := time.Now().Add(time.Second)
+	for len(frames) < n && time.Now().Before(deadline) {
+		frames = append(frames, c.send.drain()...)
+		time.Sleep(time.Millisecond)
+	}
+	if len(frames) < n {
+		t.Fatalf("expected %d frames got %d: %v", n, len(frames), frames)
+	}
+	return frames
+}
+
+func TestChatDeliveryAndModeration(t *testing.T) {
+	// 1.- Wire a hub with a memory store and a moderator that blocks a keyword.
+	store := chat.NewMemoryStore()
+	moderator := chat.ModeratorFunc(func(_ context.Context, m chat.Message) (chat.Decision, error) {
+		if strings.Contains(m.Body, "forbidden") {
+			return chat.Decision{Action: chat.ActionBlock}, nil
+		}
+		return chat.Decision{Action: chat.ActionAllow}, nil
+	})
+	h := NewHub(nil, WithChat(store, moderator), WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	rider := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleRider, principal: auth.Principal{ID: "r1", Role: auth.RoleRider}}
+	driver := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleDriver, principal: auth.Principal{ID: "d1", Role: auth.RoleDriver}}
+	h.addClient(rider)
+	h.addClient(driver)
+
+	// 2.- A valid message is acked to the sender, delivered to both rooms, and persisted.
+	if err := rider.handleFrame([]byte(`{"type":"chat","client_message_id":"c1","body":"hello"}`)); err != nil {
+		t.Fatalf("handle frame: %v", err)
+	}
+	waitForFrames(t, rider, 2)
+	driverFrames := waitForFrames(t, driver, 1)
+	delivered := driverFrames[0].(map[string]interface{})["message"].(chat.Message)
+	if delivered.SenderID != "r1" || delivered.Body != "hello" {
+		t.Fatalf("unexpected delivered message %+v", delivered)
+	}
+	history, _ := store.History(context.Background(), "t1", time.Time{}, 10)
+	if len(history) != 1 {
+		t.Fatalf("expected message persisted, got %d", len(history))
+	}
+
+	// 3.- Blocked messages only produce an error frame for the sender.
+	if err := rider.handleFrame([]byte(`{"type":"chat","client_message_id":"c2","body":"forbidden words"}`)); err != nil {
+		t.Fatalf("handle frame: %v", err)
+	}
+	frames := waitForFrames(t, rider, 1)
+	if frames[0].(map[string]interface{})["type"] != "error" {
+		t.Fatalf("expected error frame got %v", frames[0])
+	}
+	if history, _ := store.History(context.Background(), "t1", time.Time{}, 10); len(history) != 1 {
+		t.Fatalf("blocked message must not be persisted")
+	}
+}
+
+func TestChatTypingReachesCounterpartOnly(t *testing.T) {
+	h := NewHub(nil, WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	rider := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleRider, principal: auth.Principal{ID: "r1"}}
+	driver := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleDriver, principal: auth.Principal{ID: "d1"}}
+	h.addClient(rider)
+	h.addClient(driver)
+
+	if err := driver.handleFrame([]byte(`{"type":"chat.typing","typing":true}`)); err != nil {
+		t.Fatalf("handle frame: %v", err)
+	}
+	frames := waitForFrames(t, rider, 1)
+	if frames[0].(map[string]interface{})["sender_id"] != "d1" {
+		t.Fatalf("unexpected typing frame %v", frames[0])
+	}
+	if pending := driver.send.drain(); len(pending) != 0 {
+		t.Fatalf("typing indicator must not echo to sender: %v", pending)
+	}
+}
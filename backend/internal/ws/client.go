@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/logging"
+)
+
+// Client maintains websocket state for a single connection.
+type Client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send *sendQueue
+	room string
+	role Role
+	mu   sync.Mutex
+
+	principal   auth.Principal
+	resumeAfter uint64
+	filter      atomic.Pointer[FirehoseFilter]
+
+	// ctx carries the upgrade request's id, the room and the principal into frame handling and logs.
+	ctx context.Context
+}
+
+// connContext detaches the upgrade request's context from its cancellation and tags it for logging.
+func connContext(r *http.Request, room string, principal auth.Principal) context.Context {
+	ctx := context.WithoutCancel(r.Context())
+	return logging.WithPrincipal(logging.WithTripID(ctx, room), principal.ID)
+}
+
+// context returns the connection context, defaulting for clients built without an upgrade.
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+func (h *Hub) handleUpgrade(w http.ResponseWriter, r *http.Request, role Role, room string) {
+	principal, status, err := h.authorize(r, role)
+	if err == nil {
+		status, err = http.StatusForbidden, h.admit(r.Context(), principal, role, room)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	ctx := connContext(r, room, principal)
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.logger.LogAttrs(ctx, slog.LevelWarn, "websocket upgrade failed", slog.String("error", err.Error()))
+		return
+	}
+	client := &Client{
+		hub:  h,
+		conn: conn,
+		send: newSendQueue(),
+		room: room,
+		role: role,
+
+		principal: principal,
+		ctx:       ctx,
+	}
+	h.register <- client
+	go client.writePump()
+	client.readPump()
+}
+
+// WithKeepalive drops websocket clients that send nothing, not even a pong, for readTimeout,
+// and pings every pingInterval, which must be shorter, to keep healthy idle connections alive.
+func WithKeepalive(readTimeout, pingInterval time.Duration) Option {
+	return func(h *Hub) {
+		if readTimeout > 0 && pingInterval > 0 && pingInterval < readTimeout {
+			h.readTimeout, h.pingInterval = readTimeout, pingInterval
+		}
+	}
+}
+
+func (c *Client) readPump() {
+	c.hub.logger.LogAttrs(c.context(), slog.LevelInfo, "websocket connected", slog.String("role", string(c.role)))
+	defer func() {
+		c.hub.unregister <- c
+		_ = c.conn.Close()
+		c.hub.logger.LogAttrs(c.context(), slog.LevelInfo, "websocket disconnected", slog.String("role", string(c.role)))
+	}()
+	c.conn.SetReadLimit(1 << 16)
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.hub.readTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.hub.readTimeout))
+	})
+	frames := c.hub.newFrameBucket()
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if frames != nil && !frames.Take(time.Now()).Allowed {
+			c.hub.counters.record(MessageTypeFrame, outcomeDropped)
+			c.reject(errFrameRate)
+			continue
+		}
+		if err := c.handleFrame(data); err != nil {
+			break
+		}
+	}
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(c.hub.pingInterval)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+	for {
+		select {
+		case <-c.send.done:
+			return
+		case <-c.send.notify:
+			for _, payload := range c.send.drain() {
+				c.mu.Lock()
+				if err := c.conn.WriteJSON(payload); err != nil {
+					c.mu.Unlock()
+					return
+				}
+				c.mu.Unlock()
+			}
+		case <-ticker.C:
+			c.mu.Lock()
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.mu.Unlock()
+				return
+			}
+			c.mu.Unlock()
+		}
+	}
+}
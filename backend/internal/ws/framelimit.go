@@ -0,0 +1,26 @@
+package ws
+
+import (
+	"errors"
+
+	"kage/backend/internal/ratelimit"
+)
+
+// MessageTypeFrame labels inbound frames discarded by the per-connection frame limit.
+const MessageTypeFrame = "frame"
+
+// errFrameRate is reported to clients whose frames exceed the connection's limit.
+var errFrameRate = errors.New("frame rate limit exceeded, frame discarded")
+
+// WithFrameLimit caps inbound frames per connection; excess frames are rejected without being handled.
+func WithFrameLimit(limit ratelimit.Limit) Option {
+	return func(h *Hub) { h.frameLimit = limit }
+}
+
+// newFrameBucket returns the connection's bucket, or nil when frames are unlimited.
+func (h *Hub) newFrameBucket() *ratelimit.Bucket {
+	if !h.frameLimit.Valid() {
+		return nil
+	}
+	return ratelimit.NewBucket(h.frameLimit)
+}
@@ -0,0 +1,52 @@
+package ws
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"kage/backend/internal/ratelimit"
+)
+
+func TestFrameLimitRejectsExcessFrames(t *testing.T) {
+	// 1.- Allow two frames per connection with a refill too slow to matter during the test.
+	gin.SetMode(gin.TestMode)
+	h := NewHub(nil, WithLocationFanout(0, 0), WithFrameLimit(ratelimit.Limit{Rate: 0.001, Burst: 2}))
+	defer close(h.shutdown)
+	router := gin.New()
+	h.RegisterRoutes(router)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/rider/t1", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// 2.- The third frame is discarded and the sender is told why.
+	for i := 0; i < 3; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping"}`)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var frame map[string]interface{}
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("expected a rate limit error frame: %v", err)
+		}
+		if frame["type"] == "error" {
+			if frame["error"] != errFrameRate.Error() {
+				t.Fatalf("unexpected error frame %v", frame)
+			}
+			break
+		}
+	}
+	if dropped := h.Stats().DroppedBy[MessageTypeFrame]; dropped != 1 {
+		t.Fatalf("expected one discarded frame got %d", dropped)
+	}
+}
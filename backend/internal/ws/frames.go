@@ -0,0 +1,71 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"kage/backend/internal/tracing"
+)
+
+// errNoRoom rejects room-scoped frames from connections that have not joined a trip.
+var errNoRoom = errors.New("join a trip room to send this frame")
+
+// handleFrame routes typed frames to their channel and echoes everything else to the room.
+func (c *Client) handleFrame(data []byte) error {
+	var payload interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(data, &envelope)
+	ctx, span := tracing.Start(c.context(), "ws.frame",
+		attribute.String("ws.room", c.room), attribute.String("ws.role", string(c.role)), attribute.String("ws.type", envelope.Type))
+	defer span.End()
+
+	if c.role == RoleOps {
+		if envelope.Type != MessageTypeSubscribe {
+			c.reject(errors.New("ops connections only accept subscribe frames"))
+			return nil
+		}
+		if err := c.hub.handleSubscribe(c, data); err != nil {
+			c.reject(err)
+		}
+		return nil
+	}
+
+	// Idle connections may only report their position until they join a trip.
+	if c.room == "" && envelope.Type != MessageTypeLocation {
+		c.reject(errNoRoom)
+		return nil
+	}
+
+	switch envelope.Type {
+	case MessageTypeLocation:
+		var loc DriverLocation
+		if err := json.Unmarshal(data, &loc); err != nil {
+			c.reject(err)
+			return nil
+		}
+		if err := c.hub.handleLocation(c, loc); err != nil {
+			c.reject(err)
+		}
+	case MessageTypeChat, MessageTypeChatRead, MessageTypeChatTyping:
+		if err := c.hub.handleChat(ctx, c, data); err != nil {
+			c.reject(err)
+		}
+	default:
+		c.hub.BroadcastContext(ctx, Message{RoomID: c.room, Role: c.role, Type: "update", Payload: payload})
+	}
+	return nil
+}
+
+// reject reports a frame error back to the sending client only.
+func (c *Client) reject(err error) {
+	c.hub.logger.LogAttrs(c.context(), slog.LevelDebug, "websocket frame rejected", slog.String("role", string(c.role)), slog.String("error", err.Error()))
+	c.hub.enqueue(c, Message{Type: "error", Payload: map[string]interface{}{"type": "error", "error": err.Error()}}, c.hub.backpressureFor("error"))
+}
@@ -2,14 +2,25 @@ package ws
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+
+	"kage/backend/internal/chat"
+	"kage/backend/internal/logging"
+	"kage/backend/internal/ratelimit"
+	"kage/backend/internal/tracing"
 )
 
 // Role identifies the type of actor participating in the hub.
@@ -27,17 +38,16 @@ type Message struct {
 	RoomID  string
 	Role    Role
 	Type    string
+	Key     string
 	Payload interface{}
-}
-
-// Client maintains websocket state for a single connection.
-type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan interface{}
-	room string
-	role Role
-	mu   sync.Mutex
+	// ID is the hub-assigned sequence number used for SSE resumption.
+	ID uint64
+	// mirror marks duplicate deliveries that the ops firehose should skip.
+	mirror bool
+	// channel marks role-wide deliveries that ignore RoomID.
+	channel bool
+	// trace carries the sender's span context across the hub loop.
+	trace propagation.MapCarrier
 }
 
 // Hub orchestrates rider and driver communication.
@@ -46,26 +56,73 @@ type Hub struct {
 	register   chan *Client
 	unregister chan *Client
 	broadcast  chan Message
+	probe      chan chan struct{}
 	shutdown   chan struct{}
 	rooms      map[string]map[*Client]struct{}
-	logger     *log.Logger
+	channels   map[Role]map[*Client]struct{}
+	firehose   map[*Client]struct{}
+	logger     *slog.Logger
 	mu         sync.RWMutex
+
+	backpressure atomic.Pointer[backpressureTable]
+	counters     *backpressureCounters
+	recorder     Recorder
+
+	locations        *LocationTracker
+	locationInterval time.Duration
+
+	authenticator Authenticator
+	participants  ParticipantLookup
+	chatStore     chat.Store
+	moderator     chat.Moderator
+
+	seq        atomic.Uint64
+	replay     map[string]*replayRing
+	replaySize int
+	replayTTL  time.Duration
+	heartbeat  time.Duration
+
+	readTimeout  time.Duration
+	pingInterval time.Duration
+
+	frameLimit ratelimit.Limit
 }
 
+// Option mutates Hub configuration.
+type Option func(*Hub)
+
 // NewHub constructs a hub with its background goroutine.
-func NewHub(logger *log.Logger) *Hub {
-	if logger == nil {
-		logger = log.Default()
-	}
+func NewHub(logger *slog.Logger, opts ...Option) *Hub {
+	logger = logging.OrDefault(logger)
 	h := &Hub{
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan Message),
-		shutdown:   make(chan struct{}),
-		rooms:      make(map[string]map[*Client]struct{}),
-		logger:     logger,
+		register:         make(chan *Client),
+		unregister:       make(chan *Client),
+		broadcast:        make(chan Message),
+		probe:            make(chan chan struct{}),
+		shutdown:         make(chan struct{}),
+		rooms:            make(map[string]map[*Client]struct{}),
+		channels:         make(map[Role]map[*Client]struct{}),
+		firehose:         make(map[*Client]struct{}),
+		logger:           logger,
+		counters:         newBackpressureCounters(),
+		recorder:         nopRecorder{},
+		locations:        NewLocationTracker(0.01, 15*time.Second, 5*time.Minute),
+		locationInterval: time.Second,
+		chatStore:        chat.NewMemoryStore(),
+		replay:           make(map[string]*replayRing),
+		replaySize:       256,
+		replayTTL:        10 * time.Minute,
+		heartbeat:        15 * time.Second,
+		readTimeout:      60 * time.Second,
+		pingInterval:     50 * time.Second,
+	}
+	h.backpressure.Store(defaultBackpressureTable())
+	for _, opt := range opts {
+		opt(h)
 	}
+	h.counters.recorder = h.recorder
 	go h.loop()
+	go h.fanOutLocations()
 	return h
 }
 
@@ -75,13 +132,44 @@ func (h *Hub) RegisterRoutes(router *gin.Engine) {
 	router.GET("/ws/:role/:room", func(c *gin.Context) {
 		role := Role(c.Param("role"))
 		room := c.Param("room")
+		if role != RoleRider && role != RoleDriver {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be rider or driver"})
+			return
+		}
 		h.handleUpgrade(c.Writer, c.Request, role, room)
 	})
 
+	// Idle connections outside any trip still receive their role channel, such as heatmap snapshots for drivers.
+	router.GET("/ws/:role", func(c *gin.Context) {
+		role := Role(c.Param("role"))
+		if role != RoleRider && role != RoleDriver {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be rider or driver"})
+			return
+		}
+		h.handleUpgrade(c.Writer, c.Request, role, "")
+	})
+
+	router.GET("/trips/:id/events/stream", h.handleEventStream)
+
 	router.GET("/ws/rooms/:room/occupants", func(c *gin.Context) {
 		room := c.Param("room")
 		c.JSON(http.StatusOK, gin.H{"room": room, "occupants": h.count(room)})
 	})
+
+	router.GET("/ws/drivers/:id/location", h.requireOps, func(c *gin.Context) {
+		loc, ok := h.locations.Latest(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "location not found"})
+			return
+		}
+		c.JSON(http.StatusOK, loc)
+	})
+
+	router.GET("/ws/stats", h.requireOps, func(c *gin.Context) {
+		c.JSON(http.StatusOK, h.Stats())
+	})
+
+	h.registerOpsRoutes(router)
 }
 
 // Broadcast delivers a message to all participants in the specified room.
@@ -89,6 +177,13 @@ func (h *Hub) Broadcast(msg Message) {
 	h.broadcast <- msg
 }
 
+// BroadcastContext is Broadcast carrying the trace in ctx, so fan-out appears under the sender's span.
+func (h *Hub) BroadcastContext(ctx context.Context, msg Message) {
+	msg.trace = propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, msg.trace)
+	h.broadcast <- msg
+}
+
 // Shutdown stops the hub loop gracefully.
 func (h *Hub) Shutdown(ctx context.Context) {
 	close(h.shutdown)
@@ -104,81 +199,48 @@ func (h *Hub) Shutdown(ctx context.Context) {
 	}
 }
 
-func (h *Hub) handleUpgrade(w http.ResponseWriter, r *http.Request, role Role, room string) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		h.logger.Printf("upgrade error: %v", err)
-		return
-	}
-	client := &Client{
-		hub:  h,
-		conn: conn,
-		send: make(chan interface{}, 8),
-		room: room,
-		role: role,
-	}
-	h.register <- client
-	go client.writePump()
-	client.readPump()
-}
+// ErrHubStopped occurs when the hub loop has been shut down.
+var ErrHubStopped = errors.New("hub stopped")
 
-func (c *Client) readPump() {
-	defer func() {
-		c.hub.unregister <- c
-		_ = c.conn.Close()
-	}()
-	c.conn.SetReadLimit(1 << 16)
-	_ = c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	c.conn.SetPongHandler(func(string) error {
-		return c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	})
-	for {
-		var payload interface{}
-		if err := c.conn.ReadJSON(&payload); err != nil {
-			break
-		}
-		c.hub.Broadcast(Message{RoomID: c.room, Role: c.role, Type: "update", Payload: payload})
+// Ping round-trips through the hub loop, proving it still services its channels.
+func (h *Hub) Ping(ctx context.Context) error {
+	reply := make(chan struct{})
+	select {
+	case h.probe <- reply:
+	case <-h.shutdown:
+		return ErrHubStopped
+	case <-ctx.Done():
+		return fmt.Errorf("hub loop unresponsive: %w", ctx.Err())
 	}
+	<-reply
+	return nil
 }
 
-func (c *Client) writePump() {
-	ticker := time.NewTicker(50 * time.Second)
-	defer func() {
-		ticker.Stop()
-		_ = c.conn.Close()
-	}()
-	for {
-		select {
-		case payload, ok := <-c.send:
-			if !ok {
-				return
-			}
-			c.mu.Lock()
-			if err := c.conn.WriteJSON(payload); err != nil {
-				c.mu.Unlock()
-				return
-			}
-			c.mu.Unlock()
-		case <-ticker.C:
-			c.mu.Lock()
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				c.mu.Unlock()
-				return
-			}
-			c.mu.Unlock()
-		}
+// Check implements health.Checker by pinging the loop and reporting the room count.
+func (h *Hub) Check(ctx context.Context) (string, error) {
+	if err := h.Ping(ctx); err != nil {
+		return "", err
 	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return fmt.Sprintf("%d rooms, %d firehose clients", len(h.rooms), len(h.firehose)), nil
 }
 
 func (h *Hub) loop() {
+	sweep := time.NewTicker(time.Minute)
+	defer sweep.Stop()
 	for {
 		select {
+		case now := <-sweep.C:
+			h.expireReplay(now)
 		case client := <-h.register:
 			h.addClient(client)
 		case client := <-h.unregister:
 			h.removeClient(client)
 		case msg := <-h.broadcast:
 			h.push(msg)
+		case reply := <-h.probe:
+			close(reply)
 		case <-h.shutdown:
 			return
 		}
@@ -201,43 +263,86 @@ func (h *Hub) loopDrain() {
 }
 
 func (h *Hub) addClient(client *Client) {
-	room := h.roomKey(client.role, client.room)
+	if client.role == RoleOps {
+		h.mu.Lock()
+		h.firehose[client] = struct{}{}
+		h.mu.Unlock()
+		h.recorder.ConnectionOpened(client.role)
+		return
+	}
 	h.mu.Lock()
-	if _, ok := h.rooms[room]; !ok {
-		h.rooms[room] = make(map[*Client]struct{})
+	// 1.- Every rider and driver hears its role channel, whether or not it has joined a trip.
+	if _, ok := h.channels[client.role]; !ok {
+		h.channels[client.role] = make(map[*Client]struct{})
+	}
+	h.channels[client.role][client] = struct{}{}
+
+	// 2.- Clients connected to a trip also join its room and catch up on what they missed.
+	if client.room != "" {
+		room := h.roomKey(client.role, client.room)
+		if _, ok := h.rooms[room]; !ok {
+			h.rooms[room] = make(map[*Client]struct{})
+		}
+		h.rooms[room][client] = struct{}{}
+		h.replayTo(client, room)
 	}
-	h.rooms[room][client] = struct{}{}
 	h.mu.Unlock()
+	h.recorder.ConnectionOpened(client.role)
 }
 
 func (h *Hub) removeClient(client *Client) {
-	room := h.roomKey(client.role, client.room)
+	if client.role == RoleOps {
+		h.mu.Lock()
+		if _, ok := h.firehose[client]; ok {
+			delete(h.firehose, client)
+			client.send.close()
+			h.recorder.ConnectionClosed(client.role)
+		}
+		h.mu.Unlock()
+		return
+	}
 	h.mu.Lock()
-	if clients, ok := h.rooms[room]; ok {
-		if _, exists := clients[client]; exists {
+	if _, ok := h.channels[client.role][client]; ok {
+		delete(h.channels[client.role], client)
+		room := h.roomKey(client.role, client.room)
+		if clients, ok := h.rooms[room]; ok {
 			delete(clients, client)
-			close(client.send)
 			if len(clients) == 0 {
 				delete(h.rooms, room)
 			}
 		}
+		client.send.close()
+		h.recorder.ConnectionClosed(client.role)
 	}
 	h.mu.Unlock()
 }
 
 func (h *Hub) push(msg Message) {
+	if msg.trace != nil {
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), msg.trace)
+		_, span := tracing.Start(ctx, "ws.broadcast",
+			attribute.String("ws.room", msg.RoomID), attribute.String("ws.role", string(msg.Role)), attribute.String("ws.type", msg.Type))
+		defer span.End()
+	}
+	if msg.channel {
+		h.pushChannel(msg)
+		return
+	}
 	room := h.roomKey(msg.Role, msg.RoomID)
+	bp := h.backpressureFor(msg.Type)
+	msg = h.record(room, msg)
 	h.mu.RLock()
 	clients := h.rooms[room]
 	for client := range clients {
-		select {
-		case client.send <- msg.Payload:
-		default:
+		outcome := h.enqueue(client, msg, bp)
+		h.counters.record(msg.Type, outcome)
+		if outcome == outcomeOverflow {
 			go func(c *Client) {
 				c.hub.unregister <- c
 			}(client)
 		}
 	}
+	h.pushFirehose(msg)
 	h.mu.RUnlock()
 }
 
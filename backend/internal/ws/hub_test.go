@@ -0,0 +1,62 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/tracing"
+)
+
+func TestPingReportsLoopState(t *testing.T) {
+	// 1.- A running hub answers the probe and describes its rooms.
+	h := NewHub(nil, WithLocationFanout(0, 0))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	detail, err := h.Check(ctx)
+	if err != nil || detail != "0 rooms, 0 firehose clients" {
+		t.Fatalf("unexpected check result %q %v", detail, err)
+	}
+
+	// 2.- Once shut down the loop no longer answers.
+	h.Shutdown(ctx)
+	if err := h.Ping(ctx); !errors.Is(err, ErrHubStopped) {
+		t.Fatalf("expected ErrHubStopped got %v", err)
+	}
+}
+
+func TestBroadcastCarriesFrameTrace(t *testing.T) {
+	exporter, restore := tracing.UseInMemory()
+	defer restore()
+
+	// 1.- A typing frame is handled under a ws.frame span and fanned out through the loop.
+	h := NewHub(nil, WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	rider := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleRider, principal: auth.Principal{ID: "r1"}}
+	driver := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleDriver, principal: auth.Principal{ID: "d1"}}
+	h.addClient(rider)
+	h.addClient(driver)
+	if err := driver.handleFrame([]byte(`{"type":"chat.typing","typing":true}`)); err != nil {
+		t.Fatalf("handle frame: %v", err)
+	}
+	waitForFrames(t, rider, 1)
+
+	// 2.- The loop's broadcast span is a child of the frame span despite crossing goroutines.
+	frame, ok := tracing.SpanNamed(exporter.GetSpans(), "ws.frame")
+	if !ok {
+		t.Fatalf("frame span missing")
+	}
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if broadcast, ok := tracing.SpanNamed(exporter.GetSpans(), "ws.broadcast"); ok {
+			if broadcast.Parent.SpanID() != frame.SpanContext.SpanID() || broadcast.SpanContext.TraceID() != frame.SpanContext.TraceID() {
+				t.Fatalf("broadcast span not linked to frame: %+v", broadcast.Parent)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("broadcast span missing")
+}
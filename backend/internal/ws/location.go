@@ -0,0 +1,218 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/geo"
+)
+
+// MessageTypeLocation tags driver GPS frames and the rider fan-out derived from them.
+const MessageTypeLocation = "location"
+
+// ErrInvalidLocation signals coordinates outside the valid WGS84 range.
+var ErrInvalidLocation = errors.New("invalid driver location")
+
+// ErrDriverMismatch occurs when a driver publishes a fix on behalf of another driver.
+var ErrDriverMismatch = errors.New("driver_id does not match the authenticated driver")
+
+// DriverLocation is the latest GPS fix reported by a driver.
+type DriverLocation struct {
+	DriverID   string    `json:"driver_id"`
+	TripID     string    `json:"trip_id"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Heading    float64   `json:"heading,omitempty"`
+	SpeedKmh   float64   `json:"speed_kmh,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Validate rejects missing identifiers and out-of-range or non-finite coordinates.
+func (l DriverLocation) Validate() error {
+	if l.DriverID == "" {
+		return errors.New("driver_id is required")
+	}
+	if err := (geo.LatLng{Lat: l.Latitude, Lon: l.Longitude}).Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidLocation, err)
+	}
+	return nil
+}
+
+type trackedLocation struct {
+	latest   DriverLocation
+	sent     DriverLocation
+	sentAt   time.Time
+	hasSent  bool
+	pending  bool
+	updateAt time.Time
+}
+
+// LocationTracker keeps the latest fix per driver and decides which updates reach riders.
+type LocationTracker struct {
+	mu            sync.RWMutex
+	drivers       map[string]*trackedLocation
+	byTrip        map[string]string
+	minDistanceKm float64
+	maxSilence    time.Duration
+	ttl           time.Duration
+}
+
+// NewLocationTracker constructs a tracker suppressing moves shorter than minDistanceKm.
+func NewLocationTracker(minDistanceKm float64, maxSilence, ttl time.Duration) *LocationTracker {
+	return &LocationTracker{
+		drivers:       make(map[string]*trackedLocation),
+		byTrip:        make(map[string]string),
+		minDistanceKm: minDistanceKm,
+		maxSilence:    maxSilence,
+		ttl:           ttl,
+	}
+}
+
+// Update validates and records a driver fix received at now.
+func (t *LocationTracker) Update(loc DriverLocation, now time.Time) error {
+	if err := loc.Validate(); err != nil {
+		return err
+	}
+	if loc.RecordedAt.IsZero() {
+		loc.RecordedAt = now
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.drivers[loc.DriverID]
+	if !ok {
+		entry = &trackedLocation{}
+		t.drivers[loc.DriverID] = entry
+	}
+	entry.latest = loc
+	if loc.TripID != "" {
+		t.byTrip[loc.TripID] = loc.DriverID
+	}
+	entry.pending = true
+	entry.updateAt = now
+	return nil
+}
+
+// Latest returns the most recent fix stored for the driver.
+func (t *LocationTracker) Latest(driverID string) (DriverLocation, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	entry, ok := t.drivers[driverID]
+	if !ok {
+		return DriverLocation{}, false
+	}
+	return entry.latest, true
+}
+
+// TripPosition returns the latest fix of the driver serving the trip.
+func (t *LocationTracker) TripPosition(tripID string) (DriverLocation, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	entry, ok := t.drivers[t.byTrip[tripID]]
+	if !ok || entry.latest.TripID != tripID {
+		return DriverLocation{}, false
+	}
+	return entry.latest, true
+}
+
+// Due returns the fixes that should be fanned out at now and marks them as sent.
+func (t *LocationTracker) Due(now time.Time) []DriverLocation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var due []DriverLocation
+	for driverID, entry := range t.drivers {
+		// 1.- Forget drivers that have stopped reporting.
+		if t.ttl > 0 && now.Sub(entry.updateAt) > t.ttl {
+			delete(t.drivers, driverID)
+			if t.byTrip[entry.latest.TripID] == driverID {
+				delete(t.byTrip, entry.latest.TripID)
+			}
+			continue
+		}
+		if !entry.pending {
+			continue
+		}
+
+		// 2.- Suppress small moves unless riders have not heard anything for too long.
+		if entry.hasSent && entry.sent.TripID == entry.latest.TripID {
+			moved := geo.DistanceBetween(entry.sent.Latitude, entry.sent.Longitude, entry.latest.Latitude, entry.latest.Longitude)
+			silentFor := now.Sub(entry.sentAt)
+			if moved < t.minDistanceKm && (t.maxSilence <= 0 || silentFor < t.maxSilence) {
+				continue
+			}
+		}
+		entry.sent = entry.latest
+		entry.sentAt = now
+		entry.hasSent = true
+		entry.pending = false
+		due = append(due, entry.latest)
+	}
+	return due
+}
+
+// WithLocationFanout configures how often and how selectively driver fixes reach riders.
+func WithLocationFanout(interval time.Duration, minDistanceKm float64) Option {
+	return func(h *Hub) {
+		h.locationInterval = interval
+		h.locations.minDistanceKm = minDistanceKm
+	}
+}
+
+// Locations exposes the tracker holding the latest driver positions.
+func (h *Hub) Locations() *LocationTracker {
+	return h.locations
+}
+
+func (h *Hub) handleLocation(c *Client, loc DriverLocation) error {
+	if c.role != RoleDriver {
+		return errors.New("only drivers may publish locations")
+	}
+
+	// 1.- Authenticated drivers publish as themselves; service principals may relay for any driver.
+	if h.authenticator != nil && c.principal.Role != auth.RoleService {
+		if loc.DriverID != "" && loc.DriverID != c.principal.ID {
+			return ErrDriverMismatch
+		}
+		loc.DriverID = c.principal.ID
+	}
+
+	// 2.- Only the driver assigned to the trip feeds its riders; others are tracked without a trip.
+	loc.TripID = ""
+	if h.assignedDriver(c.context(), c.room, loc.DriverID) {
+		loc.TripID = c.room
+	}
+	return h.locations.Update(loc, time.Now())
+}
+
+func (h *Hub) fanOutLocations() {
+	if h.locationInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(h.locationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.shutdown:
+			return
+		case now := <-ticker.C:
+			// 1.- Emit each due fix to the rider room of the trip the driver is serving.
+			for _, loc := range h.locations.Due(now) {
+				if loc.TripID == "" {
+					continue
+				}
+				msg := Message{RoomID: loc.TripID, Role: RoleRider, Type: MessageTypeLocation, Key: loc.DriverID, Payload: locationFrame(loc)}
+				select {
+				case h.broadcast <- msg:
+				case <-h.shutdown:
+					return
+				}
+			}
+		}
+	}
+}
+
+func locationFrame(loc DriverLocation) map[string]interface{} {
+	return map[string]interface{}{"type": MessageTypeLocation, "location": loc}
+}
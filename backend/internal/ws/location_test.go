@@ -0,0 +1,152 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/contracts"
+)
+
+// tripRoster serves fixed trip participants to the hub.
+type tripRoster map[string]contracts.TripParticipants
+
+func (r tripRoster) Participants(_ context.Context, tripID string) (contracts.TripParticipants, bool) {
+	p, ok := r[tripID]
+	return p, ok
+}
+
+func TestDriverLocationValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		loc   DriverLocation
+		valid bool
+	}{
+		{"valid", DriverLocation{DriverID: "d1", Latitude: 19.43, Longitude: -99.13}, true},
+		{"missing driver", DriverLocation{Latitude: 1, Longitude: 1}, false},
+		{"latitude out of range", DriverLocation{DriverID: "d1", Latitude: 200, Longitude: 1}, false},
+		{"longitude out of range", DriverLocation{DriverID: "d1", Latitude: 1, Longitude: -181}, false},
+		{"nan", DriverLocation{DriverID: "d1", Latitude: math.NaN(), Longitude: 1}, false},
+		{"inf", DriverLocation{DriverID: "d1", Latitude: 1, Longitude: math.Inf(1)}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.loc.Validate()
+			if tc.valid && err != nil {
+				t.Fatalf("expected valid got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("expected validation error")
+			}
+		})
+	}
+}
+
+func TestLocationTrackerSuppressesSmallMoves(t *testing.T) {
+	// 1.- Configure a 50 m suppression threshold and a 10 s keepalive.
+	tracker := NewLocationTracker(0.05, 10*time.Second, time.Minute)
+	start := time.Unix(0, 0)
+
+	if err := tracker.Update(DriverLocation{DriverID: "d1", TripID: "t1", Latitude: 0, Longitude: 0}, start); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if due := tracker.Due(start); len(due) != 1 {
+		t.Fatalf("expected first fix to be sent, got %d", len(due))
+	}
+
+	// 2.- A ~11 m move is suppressed while a ~1.1 km move is delivered.
+	_ = tracker.Update(DriverLocation{DriverID: "d1", TripID: "t1", Latitude: 0.0001, Longitude: 0}, start.Add(time.Second))
+	if due := tracker.Due(start.Add(time.Second)); len(due) != 0 {
+		t.Fatalf("expected small move suppressed, got %d", len(due))
+	}
+	_ = tracker.Update(DriverLocation{DriverID: "d1", TripID: "t1", Latitude: 0.01, Longitude: 0}, start.Add(2*time.Second))
+	if due := tracker.Due(start.Add(2 * time.Second)); len(due) != 1 {
+		t.Fatalf("expected large move sent, got %d", len(due))
+	}
+
+	// 3.- After the keepalive window a stationary driver is re-sent.
+	_ = tracker.Update(DriverLocation{DriverID: "d1", TripID: "t1", Latitude: 0.01, Longitude: 0}, start.Add(13*time.Second))
+	if due := tracker.Due(start.Add(13 * time.Second)); len(due) != 1 {
+		t.Fatalf("expected keepalive fix after silence, got %d", len(due))
+	}
+
+	latest, ok := tracker.Latest("d1")
+	if !ok || latest.Latitude != 0.01 {
+		t.Fatalf("unexpected latest location: %+v", latest)
+	}
+}
+
+func TestLocationTrackerExpiresIdleDrivers(t *testing.T) {
+	tracker := NewLocationTracker(0, 0, time.Minute)
+	start := time.Unix(0, 0)
+	_ = tracker.Update(DriverLocation{DriverID: "d1", Latitude: 1, Longitude: 1}, start)
+	tracker.Due(start.Add(2 * time.Minute))
+	if _, ok := tracker.Latest("d1"); ok {
+		t.Fatalf("expected idle driver to be evicted")
+	}
+}
+
+func TestHandleFrameRejectsRiderLocations(t *testing.T) {
+	h := NewHub(nil, WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	rider := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleRider}
+
+	if err := rider.handleFrame([]byte(`{"type":"location","driver_id":"d1","latitude":1,"longitude":1}`)); err != nil {
+		t.Fatalf("handle frame: %v", err)
+	}
+	if _, ok := h.Locations().Latest("d1"); ok {
+		t.Fatalf("rider frames must not update driver locations")
+	}
+	if frames := rider.send.drain(); len(frames) != 1 {
+		t.Fatalf("expected an error frame for the rider, got %d", len(frames))
+	}
+
+	driver := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleDriver}
+	if err := driver.handleFrame([]byte(`{"type":"location","driver_id":"d1","latitude":1,"longitude":1}`)); err != nil {
+		t.Fatalf("handle frame: %v", err)
+	}
+	loc, ok := h.Locations().Latest("d1")
+	if !ok || loc.TripID != "t1" {
+		t.Fatalf("expected driver location tagged with trip, got %+v", loc)
+	}
+}
+
+func TestHandleLocationRejectsSpoofedDrivers(t *testing.T) {
+	roster := tripRoster{"t1": {RiderID: "r1", DriverID: "d1"}}
+	h := NewHub(nil, WithAuthenticator(auth.NewValidator("top-secret")), WithParticipants(roster), WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+
+	// 1.- A driver claiming another driver's id is refused and nothing is recorded.
+	intruder := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleDriver, principal: auth.Principal{ID: "d2", Role: auth.RoleDriver}}
+	err := h.handleLocation(intruder, DriverLocation{DriverID: "d1", Latitude: 1, Longitude: 1})
+	if !errors.Is(err, ErrDriverMismatch) {
+		t.Fatalf("expected driver mismatch got %v", err)
+	}
+	if _, ok := h.Locations().Latest("d1"); ok {
+		t.Fatalf("spoofed fix must not update the victim's location")
+	}
+
+	// 2.- Publishing as itself in a trip it does not drive records the fix without the trip.
+	if err := h.handleLocation(intruder, DriverLocation{Latitude: 1, Longitude: 1}); err != nil {
+		t.Fatalf("handle location: %v", err)
+	}
+	if loc, ok := h.Locations().Latest("d2"); !ok || loc.TripID != "" {
+		t.Fatalf("expected unassigned driver tracked without trip, got %+v", loc)
+	}
+	if _, ok := h.Locations().TripPosition("t1"); ok {
+		t.Fatalf("unassigned driver must not feed the trip's riders")
+	}
+
+	// 3.- The assigned driver is stamped with the trip even when the frame omits driver_id.
+	driver := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleDriver, principal: auth.Principal{ID: "d1", Role: auth.RoleDriver}}
+	if err := h.handleLocation(driver, DriverLocation{Latitude: 2, Longitude: 2}); err != nil {
+		t.Fatalf("handle location: %v", err)
+	}
+	if loc, ok := h.Locations().TripPosition("t1"); !ok || loc.DriverID != "d1" {
+		t.Fatalf("expected assigned driver position, got %+v", loc)
+	}
+}
@@ -0,0 +1,231 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/geo"
+	"kage/backend/internal/logging"
+)
+
+// RoleOps identifies operations dashboard connections.
+const RoleOps Role = "ops"
+
+// Ops frame and message types.
+const (
+	MessageTypeFirehose  = "firehose"
+	MessageTypeSubscribe = "subscribe"
+	MessageTypeNotice    = "system.notice"
+)
+
+// FirehoseFilter selects which room traffic an ops subscriber receives.
+// Trip selection (all, ids, or bounding box) is combined with the optional event type filter.
+type FirehoseFilter struct {
+	AllTrips   bool             `json:"all"`
+	TripIDs    []string         `json:"trips,omitempty"`
+	BBox       *geo.BoundingBox `json:"bbox,omitempty"`
+	EventTypes []string         `json:"types,omitempty"`
+	tripSet    map[string]bool
+	typeSet    map[string]bool
+}
+
+func (f *FirehoseFilter) compile() *FirehoseFilter {
+	f.tripSet = make(map[string]bool, len(f.TripIDs))
+	for _, id := range f.TripIDs {
+		f.tripSet[id] = true
+	}
+	f.typeSet = make(map[string]bool, len(f.EventTypes))
+	for _, t := range f.EventTypes {
+		f.typeSet[t] = true
+	}
+	if len(f.TripIDs) == 0 && f.BBox == nil {
+		f.AllTrips = true
+	}
+	return f
+}
+
+// matches reports whether msg passes the filter, using the tracker to place trips for bbox filters.
+func (f *FirehoseFilter) matches(msg Message, locations *LocationTracker) bool {
+	if len(f.typeSet) > 0 && !f.typeSet[msg.Type] {
+		return false
+	}
+	if f.AllTrips || f.tripSet[msg.RoomID] {
+		return true
+	}
+	if f.BBox != nil {
+		if pos, ok := locations.TripPosition(msg.RoomID); ok {
+			return f.BBox.Contains(pos.Latitude, pos.Longitude)
+		}
+	}
+	return false
+}
+
+// parseFirehoseFilter reads ?trips=a,b&types=chat,location&bbox=minLat,minLon,maxLat,maxLon.
+func parseFirehoseFilter(c *gin.Context) (*FirehoseFilter, error) {
+	f := &FirehoseFilter{}
+	if v := c.Query("trips"); v != "" {
+		f.TripIDs = strings.Split(v, ",")
+	}
+	if v := c.Query("types"); v != "" {
+		f.EventTypes = strings.Split(v, ",")
+	}
+	if v := c.Query("bbox"); v != "" {
+		box, err := geo.ParseBoundingBox(v)
+		if err != nil {
+			return nil, err
+		}
+		f.BBox = &box
+	}
+	return f.compile(), nil
+}
+
+func (h *Hub) registerOpsRoutes(router *gin.Engine) {
+	router.GET("/ws/ops/firehose", h.handleFirehose)
+	router.POST("/ws/ops/rooms/:room/kick", h.requireOps, h.handleKick)
+	router.POST("/ws/ops/rooms/:room/notice", h.requireOps, h.handleNotice)
+}
+
+// requireOps aborts requests from principals without the ops or service role.
+func (h *Hub) requireOps(c *gin.Context) {
+	if h.authenticator == nil {
+		return
+	}
+	principal, err := h.authenticator.Authenticate(bearerFromRequest(c.Request))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	c.Request = c.Request.WithContext(logging.WithPrincipal(c.Request.Context(), principal.ID))
+	if principal.Role != auth.RoleOps && principal.Role != auth.RoleService {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "ops role required"})
+	}
+}
+
+func (h *Hub) handleFirehose(c *gin.Context) {
+	// 1.- Authenticate as ops and parse the initial subscription filter.
+	principal, status, err := h.authorize(c.Request, RoleOps)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := parseFirehoseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 2.- Upgrade and register the connection as a firehose subscriber.
+	ctx := connContext(c.Request, "", principal)
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.logger.LogAttrs(ctx, slog.LevelWarn, "websocket upgrade failed", slog.String("error", err.Error()))
+		return
+	}
+	client := &Client{hub: h, conn: conn, send: newSendQueue(), role: RoleOps, principal: principal, ctx: ctx}
+	client.filter.Store(filter)
+	h.register <- client
+	go client.writePump()
+	client.readPump()
+}
+
+// handleSubscribe replaces an ops client's filter from a {"type":"subscribe", ...} frame.
+func (h *Hub) handleSubscribe(c *Client, data []byte) error {
+	if c.role != RoleOps {
+		return errors.New("only ops connections may subscribe")
+	}
+	var frame struct {
+		FirehoseFilter
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return err
+	}
+	c.filter.Store(frame.FirehoseFilter.compile())
+	return nil
+}
+
+// pushFirehose mirrors a room message to every matching ops subscriber. Callers hold h.mu.
+func (h *Hub) pushFirehose(msg Message) {
+	if msg.mirror || len(h.firehose) == 0 {
+		return
+	}
+	envelope := msg
+	envelope.Type = MessageTypeFirehose
+	envelope.Payload = map[string]interface{}{
+		"type": MessageTypeFirehose, "id": msg.ID, "trip_id": msg.RoomID, "role": msg.Role, "event": msg.Type, "payload": msg.Payload,
+	}
+	bp := h.backpressureFor(MessageTypeFirehose)
+	for client := range h.firehose {
+		filter := client.filter.Load()
+		if filter == nil || !filter.matches(msg, h.locations) {
+			continue
+		}
+		h.counters.record(MessageTypeFirehose, h.enqueue(client, envelope, bp))
+	}
+}
+
+// Kick disconnects every client of the principal from the room and returns how many were removed.
+func (h *Hub) Kick(room, principalID string) int {
+	kicked := 0
+	h.mu.Lock()
+	for _, role := range []Role{RoleRider, RoleDriver} {
+		key := h.roomKey(role, room)
+		for client := range h.rooms[key] {
+			if client.principal.ID != principalID {
+				continue
+			}
+			delete(h.rooms[key], client)
+			delete(h.channels[role], client)
+			client.send.close()
+			h.recorder.ConnectionClosed(role)
+			kicked++
+		}
+		if len(h.rooms[key]) == 0 {
+			delete(h.rooms, key)
+		}
+	}
+	h.mu.Unlock()
+	return kicked
+}
+
+func (h *Hub) handleKick(c *gin.Context) {
+	var body struct {
+		PrincipalID string `json:"principal_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.PrincipalID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "principal_id is required"})
+		return
+	}
+	kicked := h.Kick(c.Param("room"), body.PrincipalID)
+	h.logger.LogAttrs(logging.WithTripID(c.Request.Context(), c.Param("room")), slog.LevelInfo, "websocket clients kicked",
+		slog.String("target", body.PrincipalID), slog.Int("kicked", kicked))
+	if kicked == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "client not found in room"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"room": c.Param("room"), "kicked": kicked})
+}
+
+func (h *Hub) handleNotice(c *gin.Context) {
+	var body struct {
+		Text  string `json:"text"`
+		Level string `json:"level"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text is required"})
+		return
+	}
+	if body.Level == "" {
+		body.Level = "info"
+	}
+	h.BroadcastTrip(c.Request.Context(), c.Param("room"), MessageTypeNotice, map[string]interface{}{
+		"type": MessageTypeNotice, "trip_id": c.Param("room"), "level": body.Level, "text": body.Text,
+	})
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/geo"
+)
+
+func TestFirehoseFilterMatches(t *testing.T) {
+	tracker := NewLocationTracker(0, 0, 0)
+	_ = tracker.Update(DriverLocation{DriverID: "d1", TripID: "inside", Latitude: 19.4, Longitude: -99.1}, time.Now())
+	_ = tracker.Update(DriverLocation{DriverID: "d2", TripID: "outside", Latitude: 40, Longitude: -3}, time.Now())
+	box := &geo.BoundingBox{MinLat: 19, MinLon: -100, MaxLat: 20, MaxLon: -99}
+
+	tests := []struct {
+		name     string
+		filter   *FirehoseFilter
+		msg      Message
+		expected bool
+	}{
+		{"all trips", &FirehoseFilter{}, Message{RoomID: "any", Type: "update"}, true},
+		{"trip id hit", &FirehoseFilter{TripIDs: []string{"t1"}}, Message{RoomID: "t1", Type: "chat"}, true},
+		{"trip id miss", &FirehoseFilter{TripIDs: []string{"t1"}}, Message{RoomID: "t2", Type: "chat"}, false},
+		{"event type miss", &FirehoseFilter{EventTypes: []string{"chat"}}, Message{RoomID: "t1", Type: "location"}, false},
+		{"bbox inside", &FirehoseFilter{BBox: box}, Message{RoomID: "inside", Type: "update"}, true},
+		{"bbox outside", &FirehoseFilter{BBox: box}, Message{RoomID: "outside", Type: "update"}, false},
+		{"bbox unknown trip", &FirehoseFilter{BBox: box}, Message{RoomID: "unknown", Type: "update"}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.compile().matches(tc.msg, tracker); got != tc.expected {
+				t.Fatalf("expected %v got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFirehoseMirrorsRoomTraffic(t *testing.T) {
+	// 1.- Register an ops subscriber limited to chat events on trip t1.
+	h := NewHub(nil, WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	ops := &Client{hub: h, send: newSendQueue(), role: RoleOps}
+	ops.filter.Store((&FirehoseFilter{TripIDs: []string{"t1"}, EventTypes: []string{MessageTypeChat}}).compile())
+	h.addClient(ops)
+
+	// 2.- Only the matching message reaches the firehose, once despite the dual-room broadcast.
+	h.BroadcastTrip(context.Background(), "t1", MessageTypeChat, "hello")
+	h.BroadcastTrip(context.Background(), "t2", MessageTypeChat, "elsewhere")
+	h.Broadcast(Message{RoomID: "t1", Role: RoleRider, Type: "update", Payload: "ignored"})
+	frames := waitForFrames(t, ops, 1)
+	time.Sleep(10 * time.Millisecond)
+	frames = append(frames, ops.send.drain()...)
+	if len(frames) != 1 {
+		t.Fatalf("expected exactly one firehose frame got %v", frames)
+	}
+	envelope := frames[0].(map[string]interface{})
+	if envelope["trip_id"] != "t1" || envelope["payload"] != "hello" {
+		t.Fatalf("unexpected envelope %v", envelope)
+	}
+
+	// 3.- A subscribe frame swaps the filter at runtime.
+	if err := ops.handleFrame([]byte(`{"type":"subscribe","trips":["t2"]}`)); err != nil {
+		t.Fatalf("handle frame: %v", err)
+	}
+	h.Broadcast(Message{RoomID: "t2", Role: RoleRider, Type: "update", Payload: "now visible"})
+	if frames := waitForFrames(t, ops, 1); frames[0].(map[string]interface{})["payload"] != "now visible" {
+		t.Fatalf("unexpected frame after resubscribe %v", frames[0])
+	}
+}
+
+func TestOpsKickAndNotice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validator := auth.NewValidator("top-secret")
+	h := NewHub(nil, WithAuthenticator(validator), WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	router := gin.New()
+	h.RegisterRoutes(router)
+	rider := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleRider, principal: auth.Principal{ID: "r1"}}
+	driver := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleDriver, principal: auth.Principal{ID: "d1"}}
+	h.addClient(rider)
+	h.addClient(driver)
+	opsToken := validator.Issue(auth.Principal{ID: "ops-1", Role: auth.RoleOps}, time.Minute)
+	riderToken := validator.Issue(auth.Principal{ID: "r1", Role: auth.RoleRider}, time.Minute)
+
+	post := func(path, token, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	// 1.- Non-ops principals are refused.
+	if code := post("/ws/ops/rooms/t1/kick", riderToken, `{"principal_id":"d1"}`); code != http.StatusForbidden {
+		t.Fatalf("expected 403 got %d", code)
+	}
+
+	// 2.- Notices reach every participant of the room.
+	if code := post("/ws/ops/rooms/t1/notice", opsToken, `{"text":"driver delayed"}`); code != http.StatusAccepted {
+		t.Fatalf("expected 202 got %d", code)
+	}
+	waitForFrames(t, rider, 1)
+	waitForFrames(t, driver, 1)
+
+	// 3.- Kicking removes the client and closes its queue.
+	if code := post("/ws/ops/rooms/t1/kick", opsToken, `{"principal_id":"d1"}`); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	select {
+	case <-driver.send.done:
+	default:
+		t.Fatalf("expected kicked client queue closed")
+	}
+	if n := h.count("t1"); n != 1 {
+		t.Fatalf("expected one occupant left got %d", n)
+	}
+}
+
+func TestKickReleasesConnectionGauge(t *testing.T) {
+	// 1.- Kick a rider out of its room.
+	rec := &countingRecorder{open: make(map[Role]int), dropped: make(map[string]int)}
+	h := NewHub(nil, WithRecorder(rec), WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	rider := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleRider, principal: auth.Principal{ID: "r1"}}
+	h.addClient(rider)
+	if kicked := h.Kick("t1", "r1"); kicked != 1 {
+		t.Fatalf("expected one kick got %d", kicked)
+	}
+
+	// 2.- The gauge drops once, even when the read pump unregisters the client afterwards.
+	h.removeClient(rider)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.open[RoleRider] != 0 {
+		t.Fatalf("expected no rider connections got %v", rec.open)
+	}
+}
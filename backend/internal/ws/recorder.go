@@ -0,0 +1,23 @@
+package ws
+
+// Recorder receives hub instrumentation.
+type Recorder interface {
+	ConnectionOpened(role Role)
+	ConnectionClosed(role Role)
+	MessageDropped(msgType string)
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) ConnectionOpened(Role) {}
+func (nopRecorder) ConnectionClosed(Role) {}
+func (nopRecorder) MessageDropped(string) {}
+
+// WithRecorder reports connections per role and messages dropped by backpressure.
+func WithRecorder(r Recorder) Option {
+	return func(h *Hub) {
+		if r != nil {
+			h.recorder = r
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package ws
+
+import "time"
+
+// replayRing keeps the most recent messages of a room so resuming subscribers can catch up.
+type replayRing struct {
+	entries   []Message
+	next      int
+	full      bool
+	lastWrite time.Time
+}
+
+func newReplayRing(size int) *replayRing {
+	return &replayRing{entries: make([]Message, size)}
+}
+
+func (r *replayRing) add(msg Message, now time.Time) {
+	r.entries[r.next] = msg
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.lastWrite = now
+}
+
+// since returns buffered messages with an ID greater than lastID in delivery order.
+func (r *replayRing) since(lastID uint64) []Message {
+	var ordered []Message
+	if r.full {
+		ordered = append(ordered, r.entries[r.next:]...)
+	}
+	ordered = append(ordered, r.entries[:r.next]...)
+	var out []Message
+	for _, msg := range ordered {
+		if msg.ID > lastID {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// WithReplayBuffer keeps the last size messages per room for ttl so subscribers can resume.
+func WithReplayBuffer(size int, ttl time.Duration) Option {
+	return func(h *Hub) {
+		h.replaySize = size
+		h.replayTTL = ttl
+	}
+}
+
+// stamp assigns msg the next sequence ID unless it already carries one.
+func (h *Hub) stamp(msg Message) Message {
+	if msg.ID == 0 {
+		msg.ID = h.seq.Add(1)
+	}
+	return msg
+}
+
+// enqueue stamps msg and queues it for the client. Every outbound frame goes through here,
+// so SSE subscribers always see increasing ids whether the frame was broadcast or sent directly.
+func (h *Hub) enqueue(client *Client, msg Message, bp Backpressure) enqueueOutcome {
+	return client.send.enqueue(h.stamp(msg), bp)
+}
+
+// record stamps msg with the next sequence ID and appends it to the room's replay ring.
+// It must only be called from the hub loop.
+func (h *Hub) record(room string, msg Message) Message {
+	msg = h.stamp(msg)
+	if h.replaySize <= 0 {
+		return msg
+	}
+	ring, ok := h.replay[room]
+	if !ok {
+		ring = newReplayRing(h.replaySize)
+		h.replay[room] = ring
+	}
+	ring.add(msg, time.Now())
+	return msg
+}
+
+// replayTo queues buffered messages the client missed since its Last-Event-ID.
+func (h *Hub) replayTo(client *Client, room string) {
+	ring, ok := h.replay[room]
+	if !ok || client.resumeAfter == 0 {
+		return
+	}
+	for _, msg := range ring.since(client.resumeAfter) {
+		h.counters.record(msg.Type, h.enqueue(client, msg, h.backpressureFor(msg.Type)))
+	}
+}
+
+// expireReplay forgets rooms that have been quiet for longer than the replay TTL.
+func (h *Hub) expireReplay(now time.Time) {
+	if h.replayTTL <= 0 {
+		return
+	}
+	for room, ring := range h.replay {
+		if now.Sub(ring.lastWrite) > h.replayTTL {
+			delete(h.replay, room)
+		}
+	}
+}
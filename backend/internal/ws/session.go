@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"kage/backend/internal/auth"
+	"kage/backend/internal/contracts"
+)
+
+// ErrForbiddenRole occurs when a principal tries to join a room under a role it does not hold.
+var ErrForbiddenRole = errors.New("principal may not join as this role")
+
+// ErrNotParticipant occurs when a principal tries to join a trip room it neither rides nor drives.
+var ErrNotParticipant = errors.New("principal is not a participant of this trip")
+
+// Authenticator resolves the principal behind an Authorization header value.
+type Authenticator interface {
+	Authenticate(token string) (auth.Principal, error)
+}
+
+// ParticipantLookup resolves who rides and who drives a trip.
+type ParticipantLookup interface {
+	Participants(ctx context.Context, tripID string) (contracts.TripParticipants, bool)
+}
+
+// WithParticipants limits trip rooms and their location fan-out to the trip's own rider and driver.
+func WithParticipants(p ParticipantLookup) Option {
+	return func(h *Hub) { h.participants = p }
+}
+
+// assignedDriver reports whether driverID drives the trip; without a lookup every driver is trusted.
+func (h *Hub) assignedDriver(ctx context.Context, tripID, driverID string) bool {
+	if h.participants == nil {
+		return true
+	}
+	p, ok := h.participants.Participants(ctx, tripID)
+	return ok && p.DriverID == driverID
+}
+
+// admit checks that principal is the trip's rider or driver, matching role.
+// Idle connections join no room, service and ops principals join any room, and without authentication there is no identity to check.
+func (h *Hub) admit(ctx context.Context, principal auth.Principal, role Role, room string) error {
+	if room == "" || h.authenticator == nil || h.participants == nil || principal.Role == auth.RoleService || principal.Role == auth.RoleOps {
+		return nil
+	}
+	p, ok := h.participants.Participants(ctx, room)
+	if !ok {
+		return ErrNotParticipant
+	}
+	assigned := p.RiderID
+	if role == RoleDriver {
+		assigned = p.DriverID
+	}
+	if principal.ID != assigned {
+		return ErrNotParticipant
+	}
+	return nil
+}
+
+// WithAuthenticator requires sockets to present a bearer token accepted by a.
+func WithAuthenticator(a Authenticator) Option {
+	return func(h *Hub) { h.authenticator = a }
+}
+
+// authorize resolves the caller and checks it may act as role.
+// Browsers cannot set headers on websocket upgrades, so the access_token query parameter is accepted too.
+func (h *Hub) authorize(r *http.Request, role Role) (auth.Principal, int, error) {
+	if h.authenticator == nil {
+		return auth.Principal{ID: "anonymous", Role: string(role)}, http.StatusOK, nil
+	}
+
+	// 1.- Read the token from the header first, falling back to the query string.
+	principal, err := h.authenticator.Authenticate(bearerFromRequest(r))
+	if err != nil {
+		return auth.Principal{}, http.StatusUnauthorized, err
+	}
+
+	// 2.- Service principals may join any room; everyone else only under their own role.
+	if principal.Role != auth.RoleService && principal.Role != string(role) {
+		return auth.Principal{}, http.StatusForbidden, ErrForbiddenRole
+	}
+	return principal, http.StatusOK, nil
+}
+
+func bearerFromRequest(r *http.Request) string {
+	if token := r.Header.Get("Authorization"); token != "" {
+		return token
+	}
+	if q := r.URL.Query().Get("access_token"); q != "" {
+		return "Bearer " + q
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+)
+
+// WithHeartbeat configures how often idle SSE streams receive a keep-alive comment.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(h *Hub) { h.heartbeat = interval }
+}
+
+// handleEventStream serves room traffic as Server-Sent Events for clients that cannot hold a websocket.
+func (h *Hub) handleEventStream(c *gin.Context) {
+	room := c.Param("id")
+
+	// 1.- Resolve the role to listen as and run the same checks as the socket route.
+	role := Role(c.Query("role"))
+	if role == "" {
+		role = RoleRider
+		if h.authenticator != nil {
+			if p, err := h.authenticator.Authenticate(bearerFromRequest(c.Request)); err == nil && p.Role == auth.RoleDriver {
+				role = RoleDriver
+			}
+		}
+	}
+	if role != RoleRider && role != RoleDriver {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be rider or driver"})
+		return
+	}
+	principal, status, err := h.authorize(c.Request, role)
+	if err == nil {
+		status, err = http.StatusForbidden, h.admit(c.Request.Context(), principal, role, room)
+	}
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 2.- Register a socketless client, resuming after Last-Event-ID when provided.
+	var resumeAfter uint64
+	if v := c.GetHeader("Last-Event-ID"); v != "" {
+		resumeAfter, _ = strconv.ParseUint(v, 10, 64)
+	}
+	client := &Client{hub: h, send: newSendQueue(), room: room, role: role, principal: principal, resumeAfter: resumeAfter}
+	select {
+	case h.register <- client:
+	case <-h.shutdown:
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+	defer func() {
+		select {
+		case h.unregister <- client:
+		case <-h.shutdown:
+		}
+	}()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// 3.- Stream queued messages, heartbeats, and stop when either side goes away.
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-client.send.done:
+			return
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-client.send.notify:
+			for _, msg := range client.send.drainMessages() {
+				event := sse.Event{Id: strconv.FormatUint(msg.ID, 10), Event: msg.Type, Data: msg.Payload}
+				if err := sse.Encode(c.Writer, event); err != nil {
+					return
+				}
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kage/backend/internal/auth"
+)
+
+type sseEvent struct {
+	id, event, data string
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && ev.event != "":
+			return ev
+		case strings.HasPrefix(line, "id:"):
+			ev.id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			ev.event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			ev.data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+}
+
+func openStream(t *testing.T, ctx context.Context, url, token, lastID string) *bufio.Reader {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req.Header.Set("Authorization", token)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 got %d", res.StatusCode)
+	}
+	return bufio.NewReader(res.Body)
+}
+
+func waitForOccupants(t *testing.T, h *Hub, room string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for h.count(room) != n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if h.count(room) != n {
+		t.Fatalf("expected %d occupants in %s got %d", n, room, h.count(room))
+	}
+}
+
+func TestEventStreamDeliversAndResumes(t *testing.T) {
+	// 1.- Serve the hub behind a real HTTP server with token authentication.
+	gin.SetMode(gin.TestMode)
+	validator := auth.NewValidator("top-secret")
+	roster := tripRoster{"t1": {RiderID: "rider-1", DriverID: "driver-1"}}
+	h := NewHub(nil, WithAuthenticator(validator), WithParticipants(roster), WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	router := gin.New()
+	h.RegisterRoutes(router)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	token := validator.Issue(auth.Principal{ID: "rider-1", Role: auth.RoleRider}, time.Minute)
+	url := srv.URL + "/trips/t1/events/stream"
+
+	// 2.- A subscriber receives room traffic tagged with sequence ids.
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := openStream(t, ctx, url, token, "")
+	waitForOccupants(t, h, "t1", 1)
+	h.Broadcast(Message{RoomID: "t1", Role: RoleRider, Type: "update", Payload: map[string]int{"n": 1}})
+	first := readEvent(t, stream)
+	if first.event != "update" || first.data != `{"n":1}` || first.id == "" {
+		t.Fatalf("unexpected first event %+v", first)
+	}
+	cancel()
+	waitForOccupants(t, h, "t1", 0)
+
+	// 3.- Messages sent while disconnected are replayed after Last-Event-ID.
+	h.Broadcast(Message{RoomID: "t1", Role: RoleRider, Type: "update", Payload: map[string]int{"n": 2}})
+	resumeCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	resumed := openStream(t, resumeCtx, url, token, first.id)
+	if ev := readEvent(t, resumed); ev.data != `{"n":2}` {
+		t.Fatalf("expected replayed event got %+v", ev)
+	}
+}
+
+func TestEventStreamRequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validator := auth.NewValidator("top-secret")
+	roster := tripRoster{"t1": {RiderID: "r1", DriverID: "d1"}}
+	h := NewHub(nil, WithAuthenticator(validator), WithParticipants(roster), WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	router := gin.New()
+	h.RegisterRoutes(router)
+
+	tests := []struct {
+		name   string
+		target string
+		token  string
+		status int
+	}{
+		{"missing token", "/trips/t1/events/stream", "", http.StatusUnauthorized},
+		{"wrong role", "/trips/t1/events/stream?role=driver", validator.Issue(auth.Principal{ID: "r1", Role: auth.RoleRider}, time.Minute), http.StatusForbidden},
+		{"foreign rider stream", "/trips/t1/events/stream", validator.Issue(auth.Principal{ID: "r2", Role: auth.RoleRider}, time.Minute), http.StatusForbidden},
+		{"foreign driver stream", "/trips/t1/events/stream?role=driver", validator.Issue(auth.Principal{ID: "d2", Role: auth.RoleDriver}, time.Minute), http.StatusForbidden},
+		{"foreign rider socket", "/ws/rider/t1", validator.Issue(auth.Principal{ID: "r2", Role: auth.RoleRider}, time.Minute), http.StatusForbidden},
+		{"rider joining as driver", "/ws/driver/t1", validator.Issue(auth.Principal{ID: "r1", Role: auth.RoleDriver}, time.Minute), http.StatusForbidden},
+		{"unassigned trip", "/ws/rider/t9", validator.Issue(auth.Principal{ID: "r1", Role: auth.RoleRider}, time.Minute), http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+			if res.Code != tc.status {
+				t.Fatalf("expected %d got %d", tc.status, res.Code)
+			}
+		})
+	}
+}
+
+func TestDirectFramesCarrySequenceIDs(t *testing.T) {
+	h := NewHub(nil, WithLocationFanout(0, 0))
+	defer close(h.shutdown)
+	client := &Client{hub: h, send: newSendQueue(), room: "t1", role: RoleRider}
+	h.addClient(client)
+
+	// 1.- An error frame enqueued directly and a room broadcast draw from the same sequence.
+	client.reject(errFrameRate)
+	h.Broadcast(Message{RoomID: "t1", Role: RoleRider, Type: "update", Payload: map[string]int{"n": 1}})
+	var msgs []Message
+	deadline := time.Now().Add(time.Second)
+	for len(msgs) < 2 && time.Now().Before(deadline) {
+		msgs = append(msgs, client.send.drainMessages()...)
+		time.Sleep(time.Millisecond)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected two frames got %d", len(msgs))
+	}
+	if msgs[0].ID == 0 || msgs[1].ID <= msgs[0].ID {
+		t.Fatalf("expected increasing non-zero ids, got %d then %d", msgs[0].ID, msgs[1].ID)
+	}
+}